@@ -0,0 +1,169 @@
+package airfields
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	_ "time/tzdata"
+)
+
+// airfields.csv is the offline registry, one airfield per row keyed by ICAO
+// identifier, with its FAA location identifier (or IATA code outside the US)
+// and any common names as semicolon separated aliases. It ships inside the
+// binary, along with the timezone database, so nothing here leaves the
+// process.
+//
+//go:embed airfields.csv
+var airfields_csv string
+
+type Airfield struct {
+	ICAO        string   `json:"icao"`
+	LocalID     string   `json:"local_id"`
+	Name        string   `json:"name"`
+	Latitude    float64  `json:"latitude"`
+	Longitude   float64  `json:"longitude"`
+	ElevationFt int      `json:"elevation_ft"`
+	Timezone    string   `json:"timezone"`
+	Aliases     []string `json:"aliases"`
+}
+
+var (
+	load_once   sync.Once
+	all         []Airfield
+	by_alias    map[string]Airfield
+	name_folder = strings.NewReplacer("-", " ", "'", "", ".", "")
+)
+
+// Lookup resolves an ICAO identifier, local identifier, name or alias to its
+// airfield, ignoring case and punctuation.
+func Lookup(identifier string) (Airfield, bool) {
+	load_once.Do(load)
+	airfield, ok := by_alias[fold(identifier)]
+	return airfield, ok
+}
+
+// Normalize returns the ICAO identifier for anything Lookup accepts. ok is
+// false, and identifier is returned unchanged, when it is not in the
+// registry.
+func Normalize(identifier string) (string, bool) {
+	airfield, ok := Lookup(identifier)
+	if !ok {
+		return identifier, false
+	}
+	return airfield.ICAO, true
+}
+
+// Search matches query against identifiers, names and aliases. Exact
+// identifier matches come first, then identifier prefixes, then names and
+// aliases containing the query; each group is ordered by ICAO.
+func Search(query string, limit int) []Airfield {
+	load_once.Do(load)
+	query = fold(query)
+	type ranked struct {
+		rank     int
+		airfield Airfield
+	}
+	matches := []ranked{}
+	for _, airfield := range all {
+		rank := -1
+		icao := fold(airfield.ICAO)
+		local_id := fold(airfield.LocalID)
+		switch {
+		case query == icao || (local_id != "" && query == local_id):
+			rank = 0
+		case strings.HasPrefix(icao, query) || (local_id != "" && strings.HasPrefix(local_id, query)):
+			rank = 1
+		case strings.Contains(fold(airfield.Name), query):
+			rank = 2
+		default:
+			for _, alias := range airfield.Aliases {
+				if strings.Contains(fold(alias), query) {
+					rank = 2
+					break
+				}
+			}
+		}
+		if rank >= 0 {
+			matches = append(matches, ranked{rank: rank, airfield: airfield})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].rank < matches[j].rank
+	})
+	airfields := make([]Airfield, 0, limit)
+	for _, match := range matches {
+		if len(airfields) >= limit {
+			break
+		}
+		airfields = append(airfields, match.airfield)
+	}
+	return airfields
+}
+
+func fold(value string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(name_folder.Replace(value)), " "))
+}
+
+func load() {
+	by_alias = map[string]Airfield{}
+	records, err := csv.NewReader(strings.NewReader(airfields_csv)).ReadAll()
+	if err != nil {
+		log.Printf("Failed to read airfield registry\n%s\n", err.Error())
+		return
+	}
+	for i, record := range records {
+		if i == 0 {
+			continue
+		}
+		latitude, lat_err := strconv.ParseFloat(record[3], 64)
+		longitude, lon_err := strconv.ParseFloat(record[4], 64)
+		elevation, elevation_err := strconv.Atoi(record[5])
+		if lat_err != nil || lon_err != nil || elevation_err != nil {
+			log.Printf("Skipping airfield row %d: bad coordinates or elevation\n", i+1)
+			continue
+		}
+		_, err := time.LoadLocation(record[6])
+		if err != nil {
+			log.Printf("Skipping airfield row %d: bad timezone %s\n", i+1, record[6])
+			continue
+		}
+		airfield := Airfield{
+			ICAO:        record[0],
+			LocalID:     record[1],
+			Name:        record[2],
+			Latitude:    latitude,
+			Longitude:   longitude,
+			ElevationFt: elevation,
+			Timezone:    record[6],
+			Aliases:     []string{},
+		}
+		if record[7] != "" {
+			airfield.Aliases = strings.Split(record[7], ";")
+		}
+		all = append(all, airfield)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].ICAO < all[j].ICAO
+	})
+
+	/* Names and aliases go in first so an identifier always wins a collision */
+	for _, airfield := range all {
+		by_alias[fold(airfield.Name)] = airfield
+		for _, alias := range airfield.Aliases {
+			by_alias[fold(alias)] = airfield
+		}
+	}
+	for _, airfield := range all {
+		if airfield.LocalID != "" {
+			by_alias[fold(airfield.LocalID)] = airfield
+		}
+	}
+	for _, airfield := range all {
+		by_alias[fold(airfield.ICAO)] = airfield
+	}
+}
@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
@@ -8,17 +9,45 @@ import (
 	"io"
 	"log"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
 	"github.com/thedanisaur/jfl_platform/types"
+	"github.com/thedanisaur/jfl_platform/util"
 )
 
 var lock = &sync.Mutex{}
 
 var database *sql.DB
 
+// ErrForeignChild marks a mission or aircrew id that is not a child of the log
+// being written. That is the caller's mistake, not an outage.
+var ErrForeignChild = errors.New("child does not belong to flight log")
+
+// ForeignChildError carries the request body path of the offending id.
+type ForeignChildError struct {
+	Path    string
+	Message string
+}
+
+func (e *ForeignChildError) Error() string {
+	return e.Message
+}
+
+func (e *ForeignChildError) Unwrap() error {
+	return ErrForeignChild
+}
+
+// queryer is what the read helpers need from either the pool or a
+// transaction, so the same query can also see a transaction's own writes.
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
 func AddNullableBool(col string, field types.NullableBool, set_clauses []string, arguments []interface{}) ([]string, []interface{}) {
 	if field.Set {
 		if field.Value == nil {
@@ -43,6 +72,30 @@ func AddNullableBytes(col string, field types.NullableBytes, set_clauses []strin
 	return set_clauses, arguments
 }
 
+func AddNullableFloat(col string, field NullableFloat, set_clauses []string, arguments []interface{}) ([]string, []interface{}) {
+	if field.Set {
+		if field.Value == nil {
+			set_clauses = append(set_clauses, fmt.Sprintf("%s = NULL", col))
+		} else {
+			set_clauses = append(set_clauses, fmt.Sprintf("%s = ?", col))
+			arguments = append(arguments, *field.Value)
+		}
+	}
+	return set_clauses, arguments
+}
+
+func AddNullableInt(col string, field NullableInt, set_clauses []string, arguments []interface{}) ([]string, []interface{}) {
+	if field.Set {
+		if field.Value == nil {
+			set_clauses = append(set_clauses, fmt.Sprintf("%s = NULL", col))
+		} else {
+			set_clauses = append(set_clauses, fmt.Sprintf("%s = ?", col))
+			arguments = append(arguments, *field.Value)
+		}
+	}
+	return set_clauses, arguments
+}
+
 func AddNullableString(col string, field types.NullableString, set_clauses []string, arguments []interface{}) ([]string, []interface{}) {
 	if field.Set {
 		if field.Value == nil {
@@ -79,6 +132,43 @@ func AddNullableUUID(col string, field types.NullableString, set_clauses []strin
 	return set_clauses, arguments
 }
 
+// ChildChanges reports how a child collection (missions, aircrew) was
+// reconciled against the rows stored for its flight log.
+type ChildChanges struct {
+	Created []uuid.UUID `json:"created"`
+	Updated []uuid.UUID `json:"updated"`
+	Deleted []uuid.UUID `json:"deleted"`
+}
+
+// BeginTransaction opens the unit of work shared by the Insert*, Update* and
+// Delete* functions. Callers defer Rollback and finish with CommitTransaction.
+func BeginTransaction(txid uuid.UUID) (*sql.Tx, error) {
+	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(BeginTransaction))
+	err_string := fmt.Sprintf("database error: %s\n", txid.String())
+	database, err := GetInstance()
+	if err != nil {
+		log.Printf("failed to connect to database\n%s\n", err.Error())
+		return nil, errors.New(err_string)
+	}
+	transaction, err := database.BeginTx(context.Background(), nil)
+	if err != nil {
+		log.Printf("Failed to initiate transaction\n%s\n", err.Error())
+		return nil, errors.New(err_string)
+	}
+	return transaction, nil
+}
+
+func CommitTransaction(txid uuid.UUID, transaction *sql.Tx) error {
+	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(CommitTransaction))
+	err_string := fmt.Sprintf("database error: %s\n", txid.String())
+	err := transaction.Commit()
+	if err != nil {
+		log.Printf("Failed to commit transaction\n%s\n", err.Error())
+		return errors.New(err_string)
+	}
+	return nil
+}
+
 // TODO move all db logic to db package
 func GetInstance() (*sql.DB, error) {
 	if database == nil {
@@ -113,6 +203,85 @@ func GetInstance() (*sql.DB, error) {
 	return database, nil
 }
 
+func deleteChildren(txid uuid.UUID, transaction *sql.Tx, table string, ids map[uuid.UUID]struct{}) ([]uuid.UUID, error) {
+	err_string := fmt.Sprintf("database error: %s\n", txid.String())
+	deleted := []uuid.UUID{}
+	for id := range ids {
+		query := fmt.Sprintf(`DELETE FROM %s WHERE id = UUID_TO_BIN(?)`, table)
+		_, err := transaction.Exec(query, id)
+		if err != nil {
+			log.Printf("failed %s delete: %s\n%s\n", table, id, err.Error())
+			return nil, errors.New(err_string)
+		}
+		deleted = append(deleted, id)
+	}
+	return deleted, nil
+}
+
+// incrementVersion bumps a row's version after a write and returns the new
+// value, read back under the row lock.
+func incrementVersion(txid uuid.UUID, transaction *sql.Tx, table string, id uuid.UUID) (int, error) {
+	err_string := fmt.Sprintf("database error: %s\n", txid.String())
+	query := fmt.Sprintf(`UPDATE %s SET version = version + 1 WHERE id = UUID_TO_BIN(?)`, table)
+	_, err := transaction.Exec(query, id)
+	if err != nil {
+		log.Printf("failed %s version increment: %s\n%s\n", table, id, err.Error())
+		return 0, errors.New(err_string)
+	}
+	return lockVersion(txid, transaction, table, id)
+}
+
+// lockVersion reads a row's version and holds the row lock until the
+// transaction ends, so a matching If-Match stays valid for the whole write.
+func lockVersion(txid uuid.UUID, transaction *sql.Tx, table string, id uuid.UUID) (int, error) {
+	query := fmt.Sprintf(`SELECT version FROM %s WHERE id = UUID_TO_BIN(?) FOR UPDATE`, table)
+	var version int
+	err := transaction.QueryRow(query, id).Scan(&version)
+	if err != nil {
+		log.Printf("Failed to retrieve %s version: %s\n%s\n", table, id, err.Error())
+		return 0, fmt.Errorf("failed to retrieve %s version: %s", table, id)
+	}
+	return version, nil
+}
+
+// inUUIDs builds the placeholder list and arguments for a
+// "column IN (...)" over binary UUID columns.
+func inUUIDs(ids []uuid.UUID) (string, []interface{}) {
+	placeholders := make([]string, 0, len(ids))
+	arguments := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		placeholders = append(placeholders, "UUID_TO_BIN(?)")
+		arguments = append(arguments, id)
+	}
+	return strings.Join(placeholders, ", "), arguments
+}
+
+// nullableTime binds the zero time as NULL, which is how templates store a
+// mission with no planned takeoff or land time.
+func nullableTime(value time.Time) interface{} {
+	if value.IsZero() {
+		return nil
+	}
+	return value
+}
+
+// patchRow applies the SET clauses built by the AddNullable* helpers to a
+// single row. A patch that touches no columns is a no-op.
+func patchRow(txid uuid.UUID, transaction *sql.Tx, table string, id uuid.UUID, set_clauses []string, arguments []interface{}) error {
+	if len(set_clauses) == 0 {
+		return nil
+	}
+	err_string := fmt.Sprintf("database error: %s\n", txid.String())
+	query := fmt.Sprintf(`UPDATE %s SET %s WHERE id = UUID_TO_BIN(?)`, table, strings.Join(set_clauses, ", "))
+	arguments = append(arguments, id)
+	_, err := transaction.Exec(query, arguments...)
+	if err != nil {
+		log.Printf("failed %s patch: %s\n%s\n", table, id, err.Error())
+		return errors.New(err_string)
+	}
+	return nil
+}
+
 func readDatabaseEnv() (*types.DbConfig, error) {
 	username, username_set := os.LookupEnv("MSDBUSERNAME")
 	password, password_set := os.LookupEnv("MSDBPASSWORD")
@@ -138,3 +307,26 @@ func readDatabaseEnv() (*types.DbConfig, error) {
 	}
 	return &db_conn, nil
 }
+
+func selectChildIDs(txid uuid.UUID, transaction *sql.Tx, table string, flight_log_id uuid.UUID) (map[uuid.UUID]struct{}, error) {
+	err_string := fmt.Sprintf("database error: %s\n", txid.String())
+	query := fmt.Sprintf(`SELECT BIN_TO_UUID(id) AS id FROM %s WHERE flight_log_id = UUID_TO_BIN(?) FOR UPDATE`, table)
+	rows, err := transaction.Query(query, flight_log_id)
+	if err != nil {
+		log.Printf("Failed to retrieve %s for flight log: %s\n%s\n", table, flight_log_id, err.Error())
+		return nil, errors.New(err_string)
+	}
+	defer rows.Close()
+
+	ids := make(map[uuid.UUID]struct{})
+	for rows.Next() {
+		var id uuid.UUID
+		err := rows.Scan(&id)
+		if err != nil {
+			log.Printf("Failed to parse %s id for flight log: %s\n%s\n", table, flight_log_id, err.Error())
+			return nil, errors.New(err_string)
+		}
+		ids[id] = struct{}{}
+	}
+	return ids, nil
+}
@@ -0,0 +1,190 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/thedanisaur/jfl_platform/util"
+)
+
+const aircraft_mission_totals = `
+	LEFT JOIN (
+		SELECT flight_log_id
+			, SUM(sorties) AS sorties
+			, SUM(total_landings) AS landings
+		FROM missions
+		GROUP BY flight_log_id
+	) mission_totals ON mission_totals.flight_log_id = flight_logs.id
+`
+
+// AircraftUtilization is an airframe's accumulated use across the flight
+// logs visible to the caller.
+type AircraftUtilization struct {
+	SerialNumber string     `json:"serial_number"`
+	MDS          string     `json:"mds"`
+	Flights      int        `json:"flights"`
+	Hours        float64    `json:"hours"`
+	Sorties      int        `json:"sorties"`
+	Landings     int        `json:"landings"`
+	FirstFlown   *time.Time `json:"first_flown"`
+	LastFlown    *time.Time `json:"last_flown"`
+}
+
+// AircraftHistoryEntry is one flight log on an airframe's timeline, with the
+// running totals up to and including it.
+type AircraftHistoryEntry struct {
+	FlightLogID        uuid.UUID `json:"flight_log_id"`
+	UserID             uuid.UUID `json:"user_id"`
+	FlightLogDate      time.Time `json:"flight_log_date"`
+	MDS                string    `json:"mds"`
+	UnitCharged        string    `json:"unit_charged"`
+	Hours              float64   `json:"hours"`
+	Sorties            int       `json:"sorties"`
+	Landings           int       `json:"landings"`
+	CumulativeHours    float64   `json:"cumulative_hours"`
+	CumulativeSorties  int       `json:"cumulative_sorties"`
+	CumulativeLandings int       `json:"cumulative_landings"`
+}
+
+// GetAircraft totals flight logs by serial_number. serial_number and mds
+// narrow the result when set; logs without a serial number are left out.
+func GetAircraft(txid uuid.UUID, where_clause string, where_args []interface{}, serial_number string, mds string) ([]AircraftUtilization, error) {
+	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(GetAircraft))
+	err_string := fmt.Sprintf("database error: %s\n", txid.String())
+	database, err := GetInstance()
+	if err != nil {
+		log.Printf("Failed to connect to DB\n%s\n", err.Error())
+		return nil, errors.New("failed to connect to DB")
+	}
+
+	conditions := []string{"flight_logs.serial_number IS NOT NULL", "flight_logs.serial_number <> ''"}
+	arguments := []interface{}{}
+	if serial_number != "" {
+		conditions = append(conditions, "flight_logs.serial_number = ?")
+		arguments = append(arguments, serial_number)
+	}
+	if mds != "" {
+		conditions = append(conditions, "flight_logs.mds = ?")
+		arguments = append(arguments, mds)
+	}
+	if where_clause != "" {
+		conditions = append(conditions, "("+where_clause+")")
+		arguments = append(arguments, where_args...)
+	}
+
+	/* A tail only ever has one MDS, MAX just picks it out of the group */
+	query := fmt.Sprintf(`
+		SELECT flight_logs.serial_number
+			, COALESCE(MAX(flight_logs.mds), '') AS mds
+			, COUNT(*) AS flights
+			, COALESCE(SUM(flight_logs.total_flight_decimal_time), 0) AS hours
+			, COALESCE(SUM(mission_totals.sorties), 0) AS sorties
+			, COALESCE(SUM(mission_totals.landings), 0) AS landings
+			, MIN(flight_logs.flight_log_date) AS first_flown
+			, MAX(flight_logs.flight_log_date) AS last_flown
+		FROM flight_logs
+		%s
+		WHERE %s
+		GROUP BY flight_logs.serial_number
+		ORDER BY flight_logs.serial_number
+	`, aircraft_mission_totals, strings.Join(conditions, " AND "))
+	rows, err := database.Query(query, arguments...)
+	if err != nil {
+		log.Printf("Failed to retrieve aircraft\n%s\n", err.Error())
+		return nil, errors.New(err_string)
+	}
+	defer rows.Close()
+
+	aircraft := make([]AircraftUtilization, 0)
+	for rows.Next() {
+		var airframe AircraftUtilization
+		err := rows.Scan(
+			&airframe.SerialNumber,
+			&airframe.MDS,
+			&airframe.Flights,
+			&airframe.Hours,
+			&airframe.Sorties,
+			&airframe.Landings,
+			&airframe.FirstFlown,
+			&airframe.LastFlown,
+		)
+		if err != nil {
+			log.Printf("Failed to parse aircraft\n%s\n", err.Error())
+			return nil, errors.New(err_string)
+		}
+		aircraft = append(aircraft, airframe)
+	}
+	return aircraft, nil
+}
+
+// GetAircraftHistory returns every visible flight log on serial_number,
+// oldest first, with running totals computed over the same rows.
+func GetAircraftHistory(txid uuid.UUID, where_clause string, where_args []interface{}, serial_number string) ([]AircraftHistoryEntry, error) {
+	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(GetAircraftHistory))
+	err_string := fmt.Sprintf("database error: %s\n", txid.String())
+	database, err := GetInstance()
+	if err != nil {
+		log.Printf("Failed to connect to DB\n%s\n", err.Error())
+		return nil, errors.New("failed to connect to DB")
+	}
+
+	conditions := []string{"flight_logs.serial_number = ?"}
+	arguments := []interface{}{serial_number}
+	if where_clause != "" {
+		conditions = append(conditions, "("+where_clause+")")
+		arguments = append(arguments, where_args...)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT BIN_TO_UUID(flight_logs.id) AS flight_log_id
+			, BIN_TO_UUID(flight_logs.user_id) AS user_id
+			, flight_logs.flight_log_date
+			, COALESCE(flight_logs.mds, '') AS mds
+			, COALESCE(flight_logs.unit_charged, '') AS unit_charged
+			, COALESCE(flight_logs.total_flight_decimal_time, 0) AS hours
+			, COALESCE(mission_totals.sorties, 0) AS sorties
+			, COALESCE(mission_totals.landings, 0) AS landings
+			, SUM(COALESCE(flight_logs.total_flight_decimal_time, 0)) OVER timeline AS cumulative_hours
+			, SUM(COALESCE(mission_totals.sorties, 0)) OVER timeline AS cumulative_sorties
+			, SUM(COALESCE(mission_totals.landings, 0)) OVER timeline AS cumulative_landings
+		FROM flight_logs
+		%s
+		WHERE %s
+		WINDOW timeline AS (ORDER BY flight_logs.flight_log_date, flight_logs.id)
+		ORDER BY flight_logs.flight_log_date, flight_logs.id
+	`, aircraft_mission_totals, strings.Join(conditions, " AND "))
+	rows, err := database.Query(query, arguments...)
+	if err != nil {
+		log.Printf("Failed to retrieve history for aircraft: %s\n%s\n", serial_number, err.Error())
+		return nil, errors.New(err_string)
+	}
+	defer rows.Close()
+
+	history := make([]AircraftHistoryEntry, 0)
+	for rows.Next() {
+		var entry AircraftHistoryEntry
+		err := rows.Scan(
+			&entry.FlightLogID,
+			&entry.UserID,
+			&entry.FlightLogDate,
+			&entry.MDS,
+			&entry.UnitCharged,
+			&entry.Hours,
+			&entry.Sorties,
+			&entry.Landings,
+			&entry.CumulativeHours,
+			&entry.CumulativeSorties,
+			&entry.CumulativeLandings,
+		)
+		if err != nil {
+			log.Printf("Failed to parse history for aircraft: %s\n%s\n", serial_number, err.Error())
+			return nil, errors.New(err_string)
+		}
+		history = append(history, entry)
+	}
+	return history, nil
+}
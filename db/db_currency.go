@@ -0,0 +1,187 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/thedanisaur/jfl_platform/util"
+)
+
+// currency_fields maps the field names currency rules may count or filter on
+// to SQL over aircrews joined with per-log mission sums. It doubles as the
+// whitelist that keeps rule configuration out of the SQL text.
+var currency_fields = map[string]string{
+	"aircrews.total_aircrew_sorties":      "aircrews.total_aircrew_sorties",
+	"aircrews.total_aircrew_duration":     "aircrews.total_aircrew_duration_decimal",
+	"aircrews.cond_night_time":            "aircrews.cond_night_time",
+	"aircrews.cond_instrument_time":       "aircrews.cond_instrument_time",
+	"aircrews.cond_sim_instrument_time":   "aircrews.cond_sim_instrument_time",
+	"aircrews.cond_nvg_time":              "aircrews.cond_nvg_time",
+	"aircrews.cond_combat_time":           "aircrews.cond_combat_time",
+	"aircrews.cond_combat_sortie":         "aircrews.cond_combat_sortie",
+	"aircrews.cond_combat_support_sortie": "aircrews.cond_combat_support_sortie",
+	"missions.total_landings":             "mission_totals.total_landings",
+	"missions.full_stops":                 "mission_totals.full_stops",
+	"missions.touch_and_gos":              "mission_totals.touch_and_gos",
+	"missions.sorties":                    "mission_totals.sorties",
+}
+
+const currency_from = `
+	FROM aircrews
+	JOIN flight_logs ON flight_logs.id = aircrews.flight_log_id
+	LEFT JOIN (
+		SELECT flight_log_id
+			, SUM(total_landings) AS total_landings
+			, SUM(full_stops) AS full_stops
+			, SUM(touch_and_gos) AS touch_and_gos
+			, SUM(sorties) AS sorties
+		FROM missions
+		GROUP BY flight_log_id
+	) mission_totals ON mission_totals.flight_log_id = flight_logs.id
+`
+
+// CurrencyEvent is the amount of a counted field credited on one day.
+type CurrencyEvent struct {
+	Date  time.Time `json:"date"`
+	Count float64   `json:"count"`
+}
+
+type OverdueCrewMember struct {
+	UserID      uuid.UUID  `json:"user_id"`
+	Count       float64    `json:"count"`
+	LastEventOn *time.Time `json:"last_event_on"`
+}
+
+// CurrencyFieldValid reports whether a rule may reference field.
+func CurrencyFieldValid(field string) bool {
+	_, ok := currency_fields[field]
+	return ok
+}
+
+// GetCurrencyEvents returns, newest first, each day since since on which
+// user_id was credited with source, counting only logs where when is
+// positive (when may be empty).
+func GetCurrencyEvents(txid uuid.UUID, user_id uuid.UUID, where_clause string, where_args []interface{}, source string, when string, since time.Time) ([]CurrencyEvent, error) {
+	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(GetCurrencyEvents))
+	err_string := fmt.Sprintf("database error: %s\n", txid.String())
+	database, err := GetInstance()
+	if err != nil {
+		log.Printf("Failed to connect to DB\n%s\n", err.Error())
+		return nil, errors.New("failed to connect to DB")
+	}
+	source_sql, conditions, err := currencySQL(source, when)
+	if err != nil {
+		return nil, err
+	}
+	conditions = append(conditions, "aircrews.user_id = UUID_TO_BIN(?)", "flight_logs.flight_log_date >= ?")
+	arguments := []interface{}{user_id, since}
+	if where_clause != "" {
+		conditions = append(conditions, "("+where_clause+")")
+		arguments = append(arguments, where_args...)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT DATE(flight_logs.flight_log_date) AS event_date
+			, COALESCE(SUM(%s), 0) AS event_count
+		%s
+		WHERE %s
+		GROUP BY event_date
+		HAVING event_count > 0
+		ORDER BY event_date DESC
+	`, source_sql, currency_from, strings.Join(conditions, " AND "))
+	rows, err := database.Query(query, arguments...)
+	if err != nil {
+		log.Printf("Failed to retrieve currency events for user: %s\n%s\n", user_id, err.Error())
+		return nil, errors.New(err_string)
+	}
+	defer rows.Close()
+
+	events := make([]CurrencyEvent, 0)
+	for rows.Next() {
+		var event CurrencyEvent
+		err := rows.Scan(&event.Date, &event.Count)
+		if err != nil {
+			log.Printf("Failed to parse currency event for user: %s\n%s\n", user_id, err.Error())
+			return nil, errors.New(err_string)
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// GetOverdueCurrency lists every crew member visible through the policy
+// WHERE clause whose credited source since since falls short of required.
+// Crew members are those on any visible aircrews row, so someone who has not
+// flown at all in the window still shows up with a zero count.
+func GetOverdueCurrency(txid uuid.UUID, where_clause string, where_args []interface{}, source string, when string, since time.Time, required float64) ([]OverdueCrewMember, error) {
+	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(GetOverdueCurrency))
+	err_string := fmt.Sprintf("database error: %s\n", txid.String())
+	database, err := GetInstance()
+	if err != nil {
+		log.Printf("Failed to connect to DB\n%s\n", err.Error())
+		return nil, errors.New("failed to connect to DB")
+	}
+	source_sql, when_conditions, err := currencySQL(source, when)
+	if err != nil {
+		return nil, err
+	}
+	counted := append([]string{"flight_logs.flight_log_date >= ?"}, when_conditions...)
+	counted_sql := strings.Join(counted, " AND ")
+	/* counted_sql appears twice in the SELECT list, so since binds twice */
+	arguments := []interface{}{since, since}
+	where := "1 = 1"
+	if where_clause != "" {
+		where = "(" + where_clause + ")"
+		arguments = append(arguments, where_args...)
+	}
+	arguments = append(arguments, required)
+
+	query := fmt.Sprintf(`
+		SELECT BIN_TO_UUID(aircrews.user_id) AS user_id
+			, COALESCE(SUM(CASE WHEN %s THEN %s ELSE 0 END), 0) AS event_count
+			, MAX(CASE WHEN %s AND %s > 0 THEN flight_logs.flight_log_date END) AS last_event_on
+		%s
+		WHERE %s
+		GROUP BY aircrews.user_id
+		HAVING event_count < ?
+		ORDER BY event_count, user_id
+	`, counted_sql, source_sql, counted_sql, source_sql, currency_from, where)
+	rows, err := database.Query(query, arguments...)
+	if err != nil {
+		log.Printf("Failed to retrieve overdue currency for %s\n%s\n", source, err.Error())
+		return nil, errors.New(err_string)
+	}
+	defer rows.Close()
+
+	overdue := make([]OverdueCrewMember, 0)
+	for rows.Next() {
+		var crew_member OverdueCrewMember
+		err := rows.Scan(&crew_member.UserID, &crew_member.Count, &crew_member.LastEventOn)
+		if err != nil {
+			log.Printf("Failed to parse overdue currency for %s\n%s\n", source, err.Error())
+			return nil, errors.New(err_string)
+		}
+		overdue = append(overdue, crew_member)
+	}
+	return overdue, nil
+}
+
+func currencySQL(source string, when string) (string, []string, error) {
+	source_sql, ok := currency_fields[source]
+	if !ok {
+		return "", nil, fmt.Errorf("invalid currency source: %s", source)
+	}
+	conditions := []string{}
+	if when != "" {
+		when_sql, ok := currency_fields[when]
+		if !ok {
+			return "", nil, fmt.Errorf("invalid currency condition: %s", when)
+		}
+		conditions = append(conditions, when_sql+" > 0")
+	}
+	return source_sql, conditions, nil
+}
@@ -0,0 +1,177 @@
+package db
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/thedanisaur/jfl_platform/types"
+)
+
+// NullableFloat and NullableInt fill the gaps in the platform Nullable* types
+// so numeric columns can take part in merge patches. Set is true whenever the
+// key was present in the body, Value is nil when it was an explicit null.
+type NullableFloat struct {
+	Set   bool
+	Value *float64
+}
+
+type NullableInt struct {
+	Set   bool
+	Value *int
+}
+
+func (field *NullableFloat) UnmarshalJSON(data []byte) error {
+	field.Set = true
+	if string(data) == "null" {
+		field.Value = nil
+		return nil
+	}
+	var value float64
+	err := json.Unmarshal(data, &value)
+	if err != nil {
+		return err
+	}
+	field.Value = &value
+	return nil
+}
+
+func (field *NullableInt) UnmarshalJSON(data []byte) error {
+	field.Set = true
+	if string(data) == "null" {
+		field.Value = nil
+		return nil
+	}
+	var value int
+	err := json.Unmarshal(data, &value)
+	if err != nil {
+		return err
+	}
+	field.Value = &value
+	return nil
+}
+
+// FlightLogPatchDTO is an RFC 7396 merge patch for a flight log. Missions and
+// aircrew are patched element by element, matched on id; rows that are not
+// mentioned are left alone.
+type FlightLogPatchDTO struct {
+	MDS                    types.NullableString `json:"mds"`
+	FlightLogDate          types.NullableTime   `json:"flight_log_date"`
+	SerialNumber           types.NullableString `json:"serial_number"`
+	UnitCharged            types.NullableString `json:"unit_charged"`
+	HarmLocation           types.NullableString `json:"harm_location"`
+	FlightAuthorization    types.NullableString `json:"flight_authorization"`
+	IssuingUnit            types.NullableString `json:"issuing_unit"`
+	IsTrainingFlight       types.NullableBool   `json:"is_training_flight"`
+	IsTrainingOnly         types.NullableBool   `json:"is_training_only"`
+	TotalFlightDecimalTime NullableFloat        `json:"total_flight_decimal_time"`
+	Type                   types.NullableString `json:"type"`
+	Remarks                types.NullableString `json:"remarks"`
+	Missions               []MissionPatchDTO    `json:"missions"`
+	Aircrew                []AircrewPatchDTO    `json:"aircrew"`
+}
+
+type MissionPatchDTO struct {
+	ID               uuid.UUID            `json:"id"`
+	MissionNumber    NullableInt          `json:"mission_number"`
+	MissionSymbol    types.NullableString `json:"mission_symbol"`
+	MissionFrom      types.NullableString `json:"mission_from"`
+	MissionTo        types.NullableString `json:"mission_to"`
+	TakeoffTime      types.NullableTime   `json:"takeoff_time"`
+	LandTime         types.NullableTime   `json:"land_time"`
+	TotalTimeDecimal NullableFloat        `json:"total_time_decimal"`
+	TotalTimeDisplay types.NullableString `json:"total_time_display"`
+	TouchAndGos      NullableInt          `json:"touch_and_gos"`
+	FullStops        NullableInt          `json:"full_stops"`
+	TotalLandings    NullableInt          `json:"total_landings"`
+	Sorties          NullableInt          `json:"sorties"`
+}
+
+type AircrewPatchDTO struct {
+	ID                          uuid.UUID            `json:"id"`
+	UserID                      types.NullableString `json:"user_id"`
+	FlyingOrigin                types.NullableString `json:"flying_origin"`
+	FlightAuthCode              types.NullableString `json:"flight_auth_code"`
+	TimePrimary                 NullableFloat        `json:"time_primary"`
+	TimeSecondary               NullableFloat        `json:"time_secondary"`
+	TimeInstructor              NullableFloat        `json:"time_instructor"`
+	TimeEvaluator               NullableFloat        `json:"time_evaluator"`
+	TimeOther                   NullableFloat        `json:"time_other"`
+	TotalAircrewDurationDecimal NullableFloat        `json:"total_aircrew_duration_decimal"`
+	TotalAircrewSorties         NullableInt          `json:"total_aircrew_sorties"`
+	CondNightTime               NullableFloat        `json:"cond_night_time"`
+	CondInstrumentTime          NullableFloat        `json:"cond_instrument_time"`
+	CondSimInstrumentTime       NullableFloat        `json:"cond_sim_instrument_time"`
+	CondNvgTime                 NullableFloat        `json:"cond_nvg_time"`
+	CondCombatTime              NullableFloat        `json:"cond_combat_time"`
+	CondCombatSortie            NullableInt          `json:"cond_combat_sortie"`
+	CondCombatSupportTime       NullableFloat        `json:"cond_combat_support_time"`
+	CondCombatSupportSortie     NullableInt          `json:"cond_combat_support_sortie"`
+	AircrewRoleType             types.NullableString `json:"aircrew_role_type"`
+}
+
+type TemplateFlightLogPatchDTO struct {
+	Name types.NullableString `json:"name"`
+	FlightLogPatchDTO
+}
+
+// FlightLogSignatureDTO is one entry in a flight log's signing history. The
+// active signature for a role is the one the matching *_signature_id column
+// points at; superseded entries carry InvalidatedOn.
+type FlightLogSignatureDTO struct {
+	ID               uuid.UUID  `json:"id"`
+	FlightLogID      uuid.UUID  `json:"flight_log_id"`
+	Role             string     `json:"role"`
+	UserID           uuid.UUID  `json:"user_id"`
+	RoleName         string     `json:"role_name"`
+	ContentHash      string     `json:"content_hash"`
+	FlightLogVersion int        `json:"flight_log_version"`
+	SignedOn         time.Time  `json:"signed_on"`
+	InvalidatedOn    *time.Time `json:"invalidated_on"`
+}
+
+// FlightLogSourceTemplateDTO records the template, and the template version,
+// a flight log was instantiated from.
+type FlightLogSourceTemplateDTO struct {
+	TemplateID uuid.UUID `json:"template_id"`
+	Version    int       `json:"version"`
+}
+
+// TemplateRevisionDTO is one immutable version of a template. UserID is who
+// made the change, null for the state captured from before versioning.
+// Template is only filled in when a single version is read; its sharing scope
+// is empty for revisions recorded before sharing was kept in them.
+type TemplateRevisionDTO struct {
+	TemplateID uuid.UUID                   `json:"template_id"`
+	Version    int                         `json:"version"`
+	UserID     *uuid.UUID                  `json:"user_id"`
+	CreatedOn  time.Time                   `json:"created_on"`
+	Template   *SharedTemplateFlightLogDTO `json:"template,omitempty"`
+}
+
+// TemplateSharingDTO is who can see a template: only its owner (personal),
+// the named unit or wing, or everyone (global). Owner is empty for personal
+// and global templates.
+type TemplateSharingDTO struct {
+	Scope string `json:"scope"`
+	Owner string `json:"owner"`
+}
+
+// SharedTemplateFlightLogDTO is a template with its sharing scope, as the
+// template read endpoints return it.
+type SharedTemplateFlightLogDTO struct {
+	types.TemplateFlightLogDTO
+	Sharing TemplateSharingDTO `json:"sharing"`
+}
+
+// FlightLogTransitionDTO is one entry in a flight log's lifecycle history.
+type FlightLogTransitionDTO struct {
+	ID          uuid.UUID `json:"id"`
+	FlightLogID uuid.UUID `json:"flight_log_id"`
+	FromStatus  string    `json:"from_status"`
+	ToStatus    string    `json:"to_status"`
+	UserID      uuid.UUID `json:"user_id"`
+	RoleName    string    `json:"role_name"`
+	Reason      string    `json:"reason"`
+	CreatedOn   time.Time `json:"created_on"`
+}
@@ -1,8 +1,7 @@
 package db
 
 import (
-	"context"
-	"encoding/json"
+	"database/sql"
 	"errors"
 	"fmt"
 	"log"
@@ -14,23 +13,17 @@ import (
 	"github.com/google/uuid"
 )
 
-func DeleteFlightlog(txid uuid.UUID, user_id uuid.UUID, flight_log_id uuid.UUID) (uuid.UUID, error) {
+func DeleteFlightlog(txid uuid.UUID, transaction *sql.Tx, user_id uuid.UUID, flight_log_id uuid.UUID) (uuid.UUID, error) {
 	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(DeleteFlightlog))
-	database, err := GetInstance()
-	if err != nil {
-		log.Printf("Failed to connect to DB\n%s\n", err.Error())
-		return uuid.Nil, errors.New("failed to connect to DB")
-	}
-	transaction, err := database.BeginTx(context.Background(), nil)
+
+	err := requireFlightLogEditable(txid, transaction, flight_log_id)
 	if err != nil {
-		log.Printf("Failed to initiate transaction\n%s\n", err.Error())
-		return uuid.Nil, errors.New("failed to connect to DB")
+		return uuid.Nil, err
 	}
-	defer transaction.Rollback()
 
 	// Delete flight log's comment records
 	comments_query := `DELETE FROM flight_log_comments WHERE flight_log_id = UUID_TO_BIN(?)`
-	comments_result, err := database.Exec(comments_query, flight_log_id)
+	comments_result, err := transaction.Exec(comments_query, flight_log_id)
 	if err != nil {
 		log.Printf("Failed to delete flight log comments: %s for user: %s\n%s\n", flight_log_id, user_id, err.Error())
 		return uuid.Nil, errors.New("failed to delete flight log comments")
@@ -42,7 +35,7 @@ func DeleteFlightlog(txid uuid.UUID, user_id uuid.UUID, flight_log_id uuid.UUID)
 
 	// Delete flight log's aircrew records
 	aircrews_query := `DELETE FROM aircrews WHERE flight_log_id = UUID_TO_BIN(?)`
-	aircrews_result, err := database.Exec(aircrews_query, flight_log_id)
+	aircrews_result, err := transaction.Exec(aircrews_query, flight_log_id)
 	if err != nil {
 		log.Printf("Failed to delete aircrews: %s for user: %s\n%s\n", flight_log_id, user_id, err.Error())
 		return uuid.Nil, errors.New("failed to delete aircrews")
@@ -54,7 +47,7 @@ func DeleteFlightlog(txid uuid.UUID, user_id uuid.UUID, flight_log_id uuid.UUID)
 
 	// Delete flight log's mission records
 	missions_query := `DELETE FROM missions WHERE flight_log_id = UUID_TO_BIN(?)`
-	missions_result, err := database.Exec(missions_query, flight_log_id)
+	missions_result, err := transaction.Exec(missions_query, flight_log_id)
 	if err != nil {
 		log.Printf("Failed to delete missions: %s for user: %s\n%s\n", flight_log_id, user_id, err.Error())
 		return uuid.Nil, errors.New("failed to delete missions")
@@ -64,9 +57,21 @@ func DeleteFlightlog(txid uuid.UUID, user_id uuid.UUID, flight_log_id uuid.UUID)
 		return uuid.Nil, errors.New("failed to delete missions")
 	}
 
+	// Delete flight log's signature records
+	signatures_query := `DELETE FROM flight_log_signatures WHERE flight_log_id = UUID_TO_BIN(?)`
+	signatures_result, err := transaction.Exec(signatures_query, flight_log_id)
+	if err != nil {
+		log.Printf("Failed to delete flight log signatures: %s for user: %s\n%s\n", flight_log_id, user_id, err.Error())
+		return uuid.Nil, errors.New("failed to delete flight log signatures")
+	}
+	_, err = signatures_result.RowsAffected()
+	if err != nil {
+		return uuid.Nil, errors.New("failed to delete flight log signatures")
+	}
+
 	// Delete flight log
 	flight_log_query := `DELETE FROM flight_logs WHERE id = UUID_TO_BIN(?)`
-	flight_log_result, err := database.Exec(flight_log_query, flight_log_id)
+	flight_log_result, err := transaction.Exec(flight_log_query, flight_log_id)
 	if err != nil {
 		log.Printf("Failed to delete flight log: %s for user: %s\n%s\n", flight_log_id, user_id, err.Error())
 		return uuid.Nil, errors.New("failed to delete flight log")
@@ -153,6 +158,86 @@ func GetAirCrews(txid uuid.UUID, flight_log_id uuid.UUID) ([]types.FlightLogAirc
 	return aircrews, nil
 }
 
+// GetAirCrewsForFlightLogs loads the aircrew of every listed flight log in a
+// single query, keyed by flight log id.
+func GetAirCrewsForFlightLogs(txid uuid.UUID, flight_log_ids []uuid.UUID) (map[uuid.UUID][]types.FlightLogAircrewDTO, error) {
+	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(GetAirCrewsForFlightLogs))
+	aircrews := make(map[uuid.UUID][]types.FlightLogAircrewDTO)
+	if len(flight_log_ids) == 0 {
+		return aircrews, nil
+	}
+	database, err := GetInstance()
+	if err != nil {
+		log.Printf("Failed to connect to DB\n%s\n", err.Error())
+		return nil, errors.New("failed to connect to DB")
+	}
+	placeholders, arguments := inUUIDs(flight_log_ids)
+	query := `
+		SELECT BIN_TO_UUID(id) AS id
+			, BIN_TO_UUID(flight_log_id) AS flight_log_id
+			, user_id
+			, flying_origin
+			, flight_auth_code
+			, time_primary
+			, time_secondary
+			, time_instructor
+			, time_evaluator
+			, time_other
+			, total_aircrew_duration_decimal
+			, total_aircrew_sorties
+			, cond_night_time
+			, cond_instrument_time
+			, cond_sim_instrument_time
+			, cond_nvg_time
+			, cond_combat_time
+			, cond_combat_sortie
+			, cond_combat_support_time
+			, cond_combat_support_sortie
+			, aircrew_role_type
+		FROM aircrews
+		WHERE flight_log_id IN (` + placeholders + `)
+	`
+	rows, err := database.Query(query, arguments...)
+	if err != nil {
+		log.Printf("Failed to retrieve aircrew members for %d flight logs\n%s\n", len(flight_log_ids), err.Error())
+		return nil, errors.New("failed to retrieve aircrew members")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var aircrew types.FlightLogAircrewDTO
+		err := rows.Scan(
+			&aircrew.ID,
+			&aircrew.FlightLogID,
+			&aircrew.UserID,
+			&aircrew.FlyingOrigin,
+			&aircrew.FlightAuthCode,
+			&aircrew.TimePrimary,
+			&aircrew.TimeSecondary,
+			&aircrew.TimeInstructor,
+			&aircrew.TimeEvaluator,
+			&aircrew.TimeOther,
+			&aircrew.TotalAircrewDurationDecimal,
+			&aircrew.TotalAircrewSorties,
+			&aircrew.CondNightTime,
+			&aircrew.CondInstrumentTime,
+			&aircrew.CondSimInstrumentTime,
+			&aircrew.CondNvgTime,
+			&aircrew.CondCombatTime,
+			&aircrew.CondCombatSortie,
+			&aircrew.CondCombatSupportTime,
+			&aircrew.CondCombatSupportSortie,
+			&aircrew.AircrewRoleType,
+		)
+		if err != nil {
+			log.Printf("Failed to parse aircrew member\n%s\n", err.Error())
+			return nil, errors.New("failed to parse aircrew member")
+		}
+		aircrews[aircrew.FlightLogID] = append(aircrews[aircrew.FlightLogID], aircrew)
+	}
+	return aircrews, nil
+}
+
 func GetFlightLogComments(txid uuid.UUID, flight_log_id uuid.UUID) ([]types.FlightLogCommentDTO, error) {
 	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(GetFlightLogComments))
 	database, err := GetInstance()
@@ -199,6 +284,104 @@ func GetFlightLogComments(txid uuid.UUID, flight_log_id uuid.UUID) ([]types.Flig
 	return comments, nil
 }
 
+func GetFlightLogCommentsForFlightLogs(txid uuid.UUID, flight_log_ids []uuid.UUID) (map[uuid.UUID][]types.FlightLogCommentDTO, error) {
+	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(GetFlightLogCommentsForFlightLogs))
+	comments := make(map[uuid.UUID][]types.FlightLogCommentDTO)
+	if len(flight_log_ids) == 0 {
+		return comments, nil
+	}
+	database, err := GetInstance()
+	if err != nil {
+		log.Printf("Failed to connect to DB\n%s\n", err.Error())
+		return nil, errors.New("failed to connect to DB")
+	}
+	placeholders, arguments := inUUIDs(flight_log_ids)
+	query := `
+		SELECT BIN_TO_UUID(id) AS id
+			, BIN_TO_UUID(flight_log_id) AS flight_log_id
+			, user_id
+			, role_name
+			, comment
+			, created_on
+			, updated_on
+		FROM flight_log_comments
+		WHERE flight_log_id IN (` + placeholders + `)
+	`
+	rows, err := database.Query(query, arguments...)
+	if err != nil {
+		log.Printf("Failed to retrieve comments for %d flight logs\n%s\n", len(flight_log_ids), err.Error())
+		return nil, errors.New("failed to retrieve comments")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var comment types.FlightLogCommentDTO
+		err := rows.Scan(
+			&comment.ID,
+			&comment.FlightLogID,
+			&comment.UserID,
+			&comment.RoleName,
+			&comment.Comment,
+			&comment.CreatedOn,
+			&comment.UpdatedOn,
+		)
+		if err != nil {
+			log.Printf("Failed to parse comment\n%s\n", err.Error())
+			return nil, errors.New("failed to parse comment")
+		}
+		comments[comment.FlightLogID] = append(comments[comment.FlightLogID], comment)
+	}
+	return comments, nil
+}
+
+func GetFlightLogSourceTemplate(txid uuid.UUID, flight_log_id uuid.UUID) (*FlightLogSourceTemplateDTO, error) {
+	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(GetFlightLogSourceTemplate))
+	database, err := GetInstance()
+	if err != nil {
+		log.Printf("Failed to connect to DB\n%s\n", err.Error())
+		return nil, errors.New("failed to connect to DB")
+	}
+	query := `
+		SELECT BIN_TO_UUID(source_template_id) AS source_template_id
+			, source_template_version
+		FROM flight_logs
+		WHERE id = UUID_TO_BIN(?)
+	`
+	var template_id sql.NullString
+	var version sql.NullInt64
+	err = database.QueryRow(query, flight_log_id).Scan(&template_id, &version)
+	if err != nil {
+		log.Printf("Failed to retrieve flight log source template: %s\n%s\n", flight_log_id, err.Error())
+		return nil, errors.New("failed to retrieve flight log")
+	}
+	if !template_id.Valid {
+		return nil, nil
+	}
+	id, err := uuid.Parse(template_id.String)
+	if err != nil {
+		log.Printf("Failed to parse flight log source template: %s\n%s\n", flight_log_id, err.Error())
+		return nil, errors.New("failed to retrieve flight log")
+	}
+	return &FlightLogSourceTemplateDTO{TemplateID: id, Version: int(version.Int64)}, nil
+}
+
+func GetFlightLogVersion(txid uuid.UUID, user_id uuid.UUID, flight_log_id uuid.UUID) (int, error) {
+	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(GetFlightLogVersion))
+	database, err := GetInstance()
+	if err != nil {
+		log.Printf("Failed to connect to DB\n%s\n", err.Error())
+		return 0, errors.New("failed to connect to DB")
+	}
+	query := `SELECT version FROM flight_logs WHERE id = UUID_TO_BIN(?) AND user_id = UUID_TO_BIN(?)`
+	var version int
+	err = database.QueryRow(query, flight_log_id, user_id).Scan(&version)
+	if err != nil {
+		log.Printf("Failed to retrieve flight log version: %s for user: %s\n%s\n", flight_log_id, user_id, err.Error())
+		return 0, errors.New("failed to retrieve flight log")
+	}
+	return version, nil
+}
+
 func GetFlightlog(txid uuid.UUID, user_id uuid.UUID, flight_log_id uuid.UUID) (types.FlightLogDTO, error) {
 	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(GetFlightlog))
 	database, err := GetInstance()
@@ -259,160 +442,100 @@ func GetFlightlog(txid uuid.UUID, user_id uuid.UUID, flight_log_id uuid.UUID) (t
 	return flight_log_dto, nil
 }
 
-func GetFlightlogs(txid uuid.UUID, user_id uuid.UUID) ([]types.FlightLogDTO, error) {
+func GetFlightlogs(txid uuid.UUID, user_id uuid.UUID, where_clause string, where_args []interface{}, query FlightLogQuery) (FlightLogPage, error) {
 	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(GetFlightlogs))
-	database, err := GetInstance()
-	if err != nil {
-		log.Printf("Failed to connect to DB\n%s\n", err.Error())
-		return nil, errors.New("failed to connect to DB")
-	}
-	query := `
-		SELECT BIN_TO_UUID(id) AS id
-			, BIN_TO_UUID(user_id) AS user_id
-			, mds
-			, flight_log_date
-			, serial_number
-			, unit_charged
-			, harm_location
-			, flight_authorization
-			, issuing_unit
-			, is_training_flight
-			, is_training_only
-			, total_flight_decimal_time
-			, scheduler_signature_id
-			, sarm_signature_id
-			, instructor_signature_id
-			, student_signature_id
-			, training_officer_signature_id
-			, type
-			, remarks
-		FROM flight_logs
-		WHERE user_id = UUID_TO_BIN(?)
-	`
-	rows, err := database.Query(query, user_id)
+	scope_clause := strings.Join([]string{"flight_logs.user_id = UUID_TO_BIN(?) AND (", where_clause, ")"}, " ")
+	scope_args := append([]interface{}{user_id}, where_args...)
+	page, err := queryFlightLogPage(txid, scope_clause, scope_args, query)
 	if err != nil {
 		log.Printf("Failed to retrieve flight logs for user: %s\n%s\n", user_id, err.Error())
-		return nil, errors.New("failed to retrieve flight logs")
+		return FlightLogPage{}, err
 	}
-	defer rows.Close()
+	return page, nil
+}
 
-	flight_logs := make([]types.FlightLogDTO, 0)
-	for rows.Next() {
-		var flight_log types.FlightLogDTO
-		err := rows.Scan(
-			&flight_log.ID,
-			&flight_log.UserID,
-			&flight_log.MDS,
-			&flight_log.FlightLogDate,
-			&flight_log.SerialNumber,
-			&flight_log.UnitCharged,
-			&flight_log.HarmLocation,
-			&flight_log.FlightAuthorization,
-			&flight_log.IssuingUnit,
-			&flight_log.IsTrainingFlight,
-			&flight_log.IsTrainingOnly,
-			&flight_log.TotalFlightDecimalTime,
-			&flight_log.SchedulerSignatureID,
-			&flight_log.SarmSignatureID,
-			&flight_log.InstructorSignatureID,
-			&flight_log.StudentSignatureID,
-			&flight_log.TrainingOfficerSignatureID,
-			&flight_log.Type,
-			&flight_log.Remarks,
-		)
-		if err != nil {
-			log.Printf("Failed to parse a flight log for user: %s \n%s\n", user_id, err.Error())
-			return nil, fmt.Errorf("failed to parse a flight log for user: %s", user_id)
-		}
-		flight_logs = append(flight_logs, flight_log)
+func GetFlightlogsAll(txid uuid.UUID, user_id uuid.UUID, where_clause string, where_args []interface{}, query FlightLogQuery) (FlightLogPage, error) {
+	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(GetFlightlogsAll))
+	page, err := queryFlightLogPage(txid, where_clause, where_args, query)
+	if err != nil {
+		log.Printf("Failed to retrieve flight logs for user: %s\n%s\n", user_id, err.Error())
+		return FlightLogPage{}, err
 	}
-	return flight_logs, nil
+	return page, nil
 }
 
-func GetFlightlogsAll(txid uuid.UUID, user_id uuid.UUID, where_clause string, where_args []interface{}) ([]types.FlightLogDTO, error) {
-	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(GetFlightlogsAll))
+func GetMissions(txid uuid.UUID, flight_log_id uuid.UUID) ([]types.FlightLogMissionDTO, error) {
+	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(GetMissions))
 	database, err := GetInstance()
 	if err != nil {
 		log.Printf("Failed to connect to DB\n%s\n", err.Error())
 		return nil, errors.New("failed to connect to DB")
 	}
-	flight_log_query := `
-		SELECT BIN_TO_UUID(flight_logs.id) AS "flight_log_id"
-			, BIN_TO_UUID(flight_logs.user_id) AS "user_id"
-			, flight_logs.mds
-			, flight_logs.flight_log_date
-			, flight_logs.serial_number
-			, flight_logs.unit_charged
-			, flight_logs.harm_location
-			, flight_logs.flight_authorization
-			, flight_logs.issuing_unit
-			, flight_logs.is_training_flight
-			, flight_logs.is_training_only
-			, flight_logs.total_flight_decimal_time
-			, flight_logs.scheduler_signature_id
-			, flight_logs.sarm_signature_id
-			, flight_logs.instructor_signature_id
-			, flight_logs.student_signature_id
-			, flight_logs.training_officer_signature_id
-			, flight_logs.type
-			, flight_logs.remarks
-		FROM flight_logs
+	query := `
+		SELECT BIN_TO_UUID(id) AS id
+			, BIN_TO_UUID(flight_log_id) AS flight_log_id
+			, mission_number
+			, mission_symbol
+			, mission_from
+			, mission_to
+			, takeoff_time
+			, land_time
+			, total_time_decimal
+			, total_time_display
+			, touch_and_gos
+			, full_stops
+			, total_landings
+			, sorties
+		FROM missions
+		WHERE flight_log_id = UUID_TO_BIN(?)
 	`
-	// where_clause = strings.ReplaceAll(where_clause, "?", "UUID_TO_BIN(?)")
-	flight_log_query_str := strings.Join([]string{flight_log_query, "WHERE", where_clause}, " ")
-
-	/* TODO [drd] remove this logging */
-	log.Printf("Query string: %s\n", flight_log_query_str)
-	b, _ := json.Marshal(where_args)
-	log.Printf("Arguments: %s\n", b)
-
-	rows, err := database.Query(flight_log_query_str, where_args...)
+	rows, err := database.Query(query, flight_log_id)
 	if err != nil {
-		log.Printf("Failed to retrieve flight logs for user: %s\n%s\n", user_id, err.Error())
-		return nil, errors.New("failed to retrieve flight logs")
+		log.Printf("Failed to retrieve missions for flight log: %s \n%s\n", flight_log_id, err.Error())
+		return nil, fmt.Errorf("failed to retrieve missions for flight log: %s", flight_log_id)
 	}
 	defer rows.Close()
 
-	flight_logs := make([]types.FlightLogDTO, 0)
+	missions := make([]types.FlightLogMissionDTO, 0)
 	for rows.Next() {
-		var flight_log types.FlightLogDTO
+		var mission types.FlightLogMissionDTO
 		err := rows.Scan(
-			&flight_log.ID,
-			&flight_log.UserID,
-			&flight_log.MDS,
-			&flight_log.FlightLogDate,
-			&flight_log.SerialNumber,
-			&flight_log.UnitCharged,
-			&flight_log.HarmLocation,
-			&flight_log.FlightAuthorization,
-			&flight_log.IssuingUnit,
-			&flight_log.IsTrainingFlight,
-			&flight_log.IsTrainingOnly,
-			&flight_log.TotalFlightDecimalTime,
-			&flight_log.SchedulerSignatureID,
-			&flight_log.SarmSignatureID,
-			&flight_log.InstructorSignatureID,
-			&flight_log.StudentSignatureID,
-			&flight_log.TrainingOfficerSignatureID,
-			&flight_log.Type,
-			&flight_log.Remarks,
+			&mission.ID,
+			&mission.FlightLogID,
+			&mission.MissionNumber,
+			&mission.MissionSymbol,
+			&mission.MissionFrom,
+			&mission.MissionTo,
+			&mission.TakeoffTime,
+			&mission.LandTime,
+			&mission.TotalTimeDecimal,
+			&mission.TotalTimeDisplay,
+			&mission.TouchAndGos,
+			&mission.FullStops,
+			&mission.TotalLandings,
+			&mission.Sorties,
 		)
 		if err != nil {
-			log.Printf("Failed to parse a flight log for user: %s \n%s\n", user_id, err.Error())
-			return nil, fmt.Errorf("failed to parse a flight log for user: %s", user_id)
+			log.Printf("Failed to parse a mission leg for flight log: %s \n%s\n", flight_log_id, err.Error())
+			return nil, fmt.Errorf("failed to parse a mission leg for flight log: %s", flight_log_id)
 		}
-		flight_logs = append(flight_logs, flight_log)
+		missions = append(missions, mission)
 	}
-	return flight_logs, nil
+	return missions, nil
 }
 
-func GetMissions(txid uuid.UUID, flight_log_id uuid.UUID) ([]types.FlightLogMissionDTO, error) {
-	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(GetMissions))
+func GetMissionsForFlightLogs(txid uuid.UUID, flight_log_ids []uuid.UUID) (map[uuid.UUID][]types.FlightLogMissionDTO, error) {
+	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(GetMissionsForFlightLogs))
+	missions := make(map[uuid.UUID][]types.FlightLogMissionDTO)
+	if len(flight_log_ids) == 0 {
+		return missions, nil
+	}
 	database, err := GetInstance()
 	if err != nil {
 		log.Printf("Failed to connect to DB\n%s\n", err.Error())
 		return nil, errors.New("failed to connect to DB")
 	}
+	placeholders, arguments := inUUIDs(flight_log_ids)
 	query := `
 		SELECT BIN_TO_UUID(id) AS id
 			, BIN_TO_UUID(flight_log_id) AS flight_log_id
@@ -429,16 +552,15 @@ func GetMissions(txid uuid.UUID, flight_log_id uuid.UUID) ([]types.FlightLogMiss
 			, total_landings
 			, sorties
 		FROM missions
-		WHERE flight_log_id = UUID_TO_BIN(?)
+		WHERE flight_log_id IN (` + placeholders + `)
 	`
-	rows, err := database.Query(query, flight_log_id)
+	rows, err := database.Query(query, arguments...)
 	if err != nil {
-		log.Printf("Failed to retrieve missions for flight log: %s \n%s\n", flight_log_id, err.Error())
-		return nil, fmt.Errorf("failed to retrieve missions for flight log: %s", flight_log_id)
+		log.Printf("Failed to retrieve missions for %d flight logs\n%s\n", len(flight_log_ids), err.Error())
+		return nil, errors.New("failed to retrieve missions")
 	}
 	defer rows.Close()
 
-	missions := make([]types.FlightLogMissionDTO, 0)
 	for rows.Next() {
 		var mission types.FlightLogMissionDTO
 		err := rows.Scan(
@@ -458,22 +580,22 @@ func GetMissions(txid uuid.UUID, flight_log_id uuid.UUID) ([]types.FlightLogMiss
 			&mission.Sorties,
 		)
 		if err != nil {
-			log.Printf("Failed to parse a mission leg for flight log: %s \n%s\n", flight_log_id, err.Error())
-			return nil, fmt.Errorf("failed to parse a mission leg for flight log: %s", flight_log_id)
+			log.Printf("Failed to parse a mission leg\n%s\n", err.Error())
+			return nil, errors.New("failed to parse a mission leg")
 		}
-		missions = append(missions, mission)
+		missions[mission.FlightLogID] = append(missions[mission.FlightLogID], mission)
 	}
 	return missions, nil
 }
 
-func InsertAircrews(txid uuid.UUID, flight_log types.FlightLogDTO) ([]uuid.UUID, error) {
+func IncrementFlightLogVersion(txid uuid.UUID, transaction *sql.Tx, flight_log_id uuid.UUID) (int, error) {
+	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(IncrementFlightLogVersion))
+	return incrementVersion(txid, transaction, "flight_logs", flight_log_id)
+}
+
+func InsertAircrews(txid uuid.UUID, transaction *sql.Tx, flight_log types.FlightLogDTO) ([]uuid.UUID, error) {
 	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(InsertAircrews))
 	err_string := fmt.Sprintf("database error: %s\n", txid.String())
-	database, err := GetInstance()
-	if err != nil {
-		log.Printf("failed to connect to database\n%s\n", err.Error())
-		return nil, errors.New(err_string)
-	}
 	ids := []uuid.UUID{}
 	for _, aircrew := range flight_log.Aircrew {
 		query := `
@@ -527,7 +649,7 @@ func InsertAircrews(txid uuid.UUID, flight_log types.FlightLogDTO) ([]uuid.UUID,
 			)
 		`
 		id := uuid.New()
-		_, err = database.Exec(
+		_, err := transaction.Exec(
 			query,
 			id,
 			flight_log.ID,
@@ -560,14 +682,9 @@ func InsertAircrews(txid uuid.UUID, flight_log types.FlightLogDTO) ([]uuid.UUID,
 	return ids, nil
 }
 
-func InsertFlightLog(txid uuid.UUID, request_user_id uuid.UUID, flight_log types.FlightLogDTO) (uuid.UUID, error) {
+func InsertFlightLog(txid uuid.UUID, transaction *sql.Tx, request_user_id uuid.UUID, flight_log types.FlightLogDTO) (uuid.UUID, error) {
 	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(InsertFlightLog))
 	err_string := fmt.Sprintf("database error: %s\n", txid.String())
-	database, err := GetInstance()
-	if err != nil {
-		log.Printf("failed to connect to database\n%s\n", err.Error())
-		return uuid.Nil, errors.New(err_string)
-	}
 	query := `
 		INSERT INTO flight_logs
 		(
@@ -583,11 +700,6 @@ func InsertFlightLog(txid uuid.UUID, request_user_id uuid.UUID, flight_log types
 			, is_training_flight
 			, is_training_only
 			, total_flight_decimal_time
-			, scheduler_signature_id
-			, sarm_signature_id
-			, instructor_signature_id
-			, student_signature_id
-			, training_officer_signature_id
 			, type
 			, remarks
 		)
@@ -605,17 +717,12 @@ func InsertFlightLog(txid uuid.UUID, request_user_id uuid.UUID, flight_log types
 			?, -- is_training_flight
 			?, -- is_training_only
 			?, -- total_flight_decimal_time
-			UUID_TO_BIN(?), -- scheduler_signature_id
-			UUID_TO_BIN(?), -- sarm_signature_id
-			UUID_TO_BIN(?), -- instructor_signature_id
-			UUID_TO_BIN(?), -- student_signature_id
-			UUID_TO_BIN(?), -- training_officer_signature_id
 			?, -- type
 			? -- remarks
 		)
 	`
 	id := uuid.New()
-	_, err = database.Exec(
+	_, err := transaction.Exec(
 		query,
 		id,
 		request_user_id,
@@ -629,11 +736,6 @@ func InsertFlightLog(txid uuid.UUID, request_user_id uuid.UUID, flight_log types
 		flight_log.IsTrainingFlight,
 		flight_log.IsTrainingOnly,
 		flight_log.TotalFlightDecimalTime,
-		flight_log.SchedulerSignatureID,
-		flight_log.SarmSignatureID,
-		flight_log.InstructorSignatureID,
-		flight_log.StudentSignatureID,
-		flight_log.TrainingOfficerSignatureID,
 		flight_log.Type,
 		flight_log.Remarks,
 	)
@@ -644,14 +746,9 @@ func InsertFlightLog(txid uuid.UUID, request_user_id uuid.UUID, flight_log types
 	return id, nil
 }
 
-func InsertFlightLogComment(txid uuid.UUID, request_user_id uuid.UUID, flight_log_comment types.FlightLogCommentDTO) (uuid.UUID, error) {
+func InsertFlightLogComment(txid uuid.UUID, transaction *sql.Tx, request_user_id uuid.UUID, flight_log_comment types.FlightLogCommentDTO) (uuid.UUID, error) {
 	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(InsertFlightLogComment))
 	err_string := fmt.Sprintf("database error: %s\n", txid.String())
-	database, err := GetInstance()
-	if err != nil {
-		log.Printf("failed to connect to database\n%s\n", err.Error())
-		return uuid.Nil, errors.New(err_string)
-	}
 	query := `
 		INSERT INTO flight_log_comments
 		(
@@ -671,7 +768,7 @@ func InsertFlightLogComment(txid uuid.UUID, request_user_id uuid.UUID, flight_lo
 		)
 	`
 	id := uuid.New()
-	_, err = database.Exec(
+	_, err := transaction.Exec(
 		query,
 		id,
 		flight_log_comment.FlightLogID,
@@ -686,14 +783,9 @@ func InsertFlightLogComment(txid uuid.UUID, request_user_id uuid.UUID, flight_lo
 	return id, nil
 }
 
-func InsertFlightLogComments(txid uuid.UUID, request_user_id uuid.UUID, flight_log types.FlightLogDTO) ([]uuid.UUID, error) {
+func InsertFlightLogComments(txid uuid.UUID, transaction *sql.Tx, request_user_id uuid.UUID, flight_log types.FlightLogDTO) ([]uuid.UUID, error) {
 	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(InsertFlightLogComments))
 	err_string := fmt.Sprintf("database error: %s\n", txid.String())
-	database, err := GetInstance()
-	if err != nil {
-		log.Printf("failed to connect to database\n%s\n", err.Error())
-		return nil, errors.New(err_string)
-	}
 	ids := []uuid.UUID{}
 	for _, comment := range flight_log.Comments {
 		query := `
@@ -715,7 +807,7 @@ func InsertFlightLogComments(txid uuid.UUID, request_user_id uuid.UUID, flight_l
 			)
 		`
 		id := uuid.New()
-		_, err = database.Exec(
+		_, err := transaction.Exec(
 			query,
 			id,
 			flight_log.ID,
@@ -732,14 +824,9 @@ func InsertFlightLogComments(txid uuid.UUID, request_user_id uuid.UUID, flight_l
 	return ids, nil
 }
 
-func InsertMissions(txid uuid.UUID, flight_log types.FlightLogDTO) ([]uuid.UUID, error) {
+func InsertMissions(txid uuid.UUID, transaction *sql.Tx, flight_log types.FlightLogDTO) ([]uuid.UUID, error) {
 	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(InsertMissions))
 	err_string := fmt.Sprintf("database error: %s\n", txid.String())
-	database, err := GetInstance()
-	if err != nil {
-		log.Printf("failed to connect to database\n%s\n", err.Error())
-		return nil, errors.New(err_string)
-	}
 	ids := []uuid.UUID{}
 	for _, mission := range flight_log.Missions {
 		query := `
@@ -779,7 +866,7 @@ func InsertMissions(txid uuid.UUID, flight_log types.FlightLogDTO) ([]uuid.UUID,
 			)
 		`
 		id := uuid.New()
-		_, err = database.Exec(
+		_, err := transaction.Exec(
 			query,
 			id,
 			flight_log.ID,
@@ -805,18 +892,205 @@ func InsertMissions(txid uuid.UUID, flight_log types.FlightLogDTO) ([]uuid.UUID,
 	return ids, nil
 }
 
-func UpdateAircrews(txid uuid.UUID, flight_log types.FlightLogDTO) ([]uuid.UUID, error) {
+func LockFlightLogVersion(txid uuid.UUID, transaction *sql.Tx, flight_log_id uuid.UUID) (int, error) {
+	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(LockFlightLogVersion))
+	return lockVersion(txid, transaction, "flight_logs", flight_log_id)
+}
+
+// LockMissions reads a flight log's missions inside transaction, so computed
+// times can be derived from rows that were just patched.
+func LockMissions(txid uuid.UUID, transaction *sql.Tx, flight_log_id uuid.UUID) ([]types.FlightLogMissionDTO, error) {
+	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(LockMissions))
+	query := `
+		SELECT BIN_TO_UUID(id) AS id
+			, BIN_TO_UUID(flight_log_id) AS flight_log_id
+			, mission_number
+			, mission_symbol
+			, mission_from
+			, mission_to
+			, takeoff_time
+			, land_time
+			, total_time_decimal
+			, total_time_display
+			, touch_and_gos
+			, full_stops
+			, total_landings
+			, sorties
+		FROM missions
+		WHERE flight_log_id = UUID_TO_BIN(?)
+		FOR UPDATE
+	`
+	rows, err := transaction.Query(query, flight_log_id)
+	if err != nil {
+		log.Printf("Failed to retrieve missions for flight log: %s \n%s\n", flight_log_id, err.Error())
+		return nil, fmt.Errorf("failed to retrieve missions for flight log: %s", flight_log_id)
+	}
+	defer rows.Close()
+
+	missions := make([]types.FlightLogMissionDTO, 0)
+	for rows.Next() {
+		var mission types.FlightLogMissionDTO
+		err := rows.Scan(
+			&mission.ID,
+			&mission.FlightLogID,
+			&mission.MissionNumber,
+			&mission.MissionSymbol,
+			&mission.MissionFrom,
+			&mission.MissionTo,
+			&mission.TakeoffTime,
+			&mission.LandTime,
+			&mission.TotalTimeDecimal,
+			&mission.TotalTimeDisplay,
+			&mission.TouchAndGos,
+			&mission.FullStops,
+			&mission.TotalLandings,
+			&mission.Sorties,
+		)
+		if err != nil {
+			log.Printf("Failed to parse a mission leg for flight log: %s \n%s\n", flight_log_id, err.Error())
+			return nil, fmt.Errorf("failed to parse a mission leg for flight log: %s", flight_log_id)
+		}
+		missions = append(missions, mission)
+	}
+	return missions, nil
+}
+
+func PatchAircrews(txid uuid.UUID, transaction *sql.Tx, flight_log_id uuid.UUID, patch FlightLogPatchDTO) ([]uuid.UUID, error) {
+	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(PatchAircrews))
+
+	err := requireFlightLogEditable(txid, transaction, flight_log_id)
+	if err != nil {
+		return nil, err
+	}
+
+	existing_ids, err := selectChildIDs(txid, transaction, "aircrews", flight_log_id)
+	if err != nil {
+		return nil, err
+	}
+	ids := []uuid.UUID{}
+	for i, aircrew := range patch.Aircrew {
+		if _, ok := existing_ids[aircrew.ID]; !ok {
+			log.Printf("aircrew: %s does not belong to flight log: %s\n", aircrew.ID, flight_log_id)
+			return nil, &ForeignChildError{Path: fmt.Sprintf("aircrew[%d].id", i), Message: fmt.Sprintf("aircrew: %s does not belong to flight log: %s", aircrew.ID, flight_log_id)}
+		}
+		set_clauses, arguments := aircrewSetClauses(aircrew)
+		err = patchRow(txid, transaction, "aircrews", aircrew.ID, set_clauses, arguments)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, aircrew.ID)
+	}
+	return ids, nil
+}
+
+func PatchFlightLog(txid uuid.UUID, transaction *sql.Tx, user_id uuid.UUID, flight_log_id uuid.UUID, patch FlightLogPatchDTO) (uuid.UUID, error) {
+	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(PatchFlightLog))
+
+	err := requireFlightLogEditable(txid, transaction, flight_log_id)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	query := `SELECT BIN_TO_UUID(id) AS id FROM flight_logs WHERE id = UUID_TO_BIN(?) AND user_id = UUID_TO_BIN(?) FOR UPDATE`
+	var id uuid.UUID
+	err = transaction.QueryRow(query, flight_log_id, user_id).Scan(&id)
+	if err != nil {
+		log.Printf("Failed to retrieve flight log: %s for user: %s\n%s\n", flight_log_id, user_id, err.Error())
+		return uuid.Nil, errors.New("failed to retrieve flight log")
+	}
+	set_clauses, arguments := flightLogSetClauses(patch)
+	err = patchRow(txid, transaction, "flight_logs", id, set_clauses, arguments)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return id, nil
+}
+
+func PatchMissions(txid uuid.UUID, transaction *sql.Tx, flight_log_id uuid.UUID, patch FlightLogPatchDTO) ([]uuid.UUID, error) {
+	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(PatchMissions))
+
+	err := requireFlightLogEditable(txid, transaction, flight_log_id)
+	if err != nil {
+		return nil, err
+	}
+
+	existing_ids, err := selectChildIDs(txid, transaction, "missions", flight_log_id)
+	if err != nil {
+		return nil, err
+	}
+	ids := []uuid.UUID{}
+	for i, mission := range patch.Missions {
+		if _, ok := existing_ids[mission.ID]; !ok {
+			log.Printf("mission: %s does not belong to flight log: %s\n", mission.ID, flight_log_id)
+			return nil, &ForeignChildError{Path: fmt.Sprintf("missions[%d].id", i), Message: fmt.Sprintf("mission: %s does not belong to flight log: %s", mission.ID, flight_log_id)}
+		}
+		set_clauses, arguments := missionSetClauses(mission)
+		err = patchRow(txid, transaction, "missions", mission.ID, set_clauses, arguments)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, mission.ID)
+	}
+	return ids, nil
+}
+
+func SetFlightLogSourceTemplate(txid uuid.UUID, transaction *sql.Tx, flight_log_id uuid.UUID, source FlightLogSourceTemplateDTO) error {
+	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(SetFlightLogSourceTemplate))
+	err_string := fmt.Sprintf("database error: %s\n", txid.String())
+	query := `
+		UPDATE flight_logs
+		SET source_template_id = UUID_TO_BIN(?)
+			, source_template_version = ?
+		WHERE id = UUID_TO_BIN(?)
+	`
+	_, err := transaction.Exec(query, source.TemplateID, source.Version, flight_log_id)
+	if err != nil {
+		log.Printf("failed flight log source template update\n%s\n", err.Error())
+		return errors.New(err_string)
+	}
+	return nil
+}
+
+func UpdateAircrews(txid uuid.UUID, transaction *sql.Tx, flight_log types.FlightLogDTO) (ChildChanges, error) {
 	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(UpdateAircrews))
 	err_string := fmt.Sprintf("database error: %s\n", txid.String())
 
-	database, err := GetInstance()
+	err := requireFlightLogEditable(txid, transaction, flight_log.ID)
 	if err != nil {
-		log.Printf("failed to connect to database\n%s\n", err.Error())
-		return nil, errors.New(err_string)
+		return ChildChanges{}, err
 	}
 
-	ids := []uuid.UUID{}
-	for _, aircrew := range flight_log.Aircrew {
+	existing_ids, err := selectChildIDs(txid, transaction, "aircrews", flight_log.ID)
+	if err != nil {
+		return ChildChanges{}, err
+	}
+	created := []types.FlightLogAircrewDTO{}
+	updated := []types.FlightLogAircrewDTO{}
+	for i, aircrew := range flight_log.Aircrew {
+		if aircrew.ID == uuid.Nil {
+			created = append(created, aircrew)
+			continue
+		}
+		if _, ok := existing_ids[aircrew.ID]; !ok {
+			log.Printf("aircrew: %s does not belong to flight log: %s\n", aircrew.ID, flight_log.ID)
+			return ChildChanges{}, &ForeignChildError{Path: fmt.Sprintf("aircrew[%d].id", i), Message: fmt.Sprintf("aircrew: %s does not belong to flight log: %s", aircrew.ID, flight_log.ID)}
+		}
+		delete(existing_ids, aircrew.ID)
+		updated = append(updated, aircrew)
+	}
+
+	changes := ChildChanges{Updated: []uuid.UUID{}}
+	// Entries without an ID are new
+	changes.Created, err = InsertAircrews(txid, transaction, types.FlightLogDTO{ID: flight_log.ID, Aircrew: created})
+	if err != nil {
+		return ChildChanges{}, err
+	}
+	// Anything stored that the caller no longer sent was removed
+	changes.Deleted, err = deleteChildren(txid, transaction, "aircrews", existing_ids)
+	if err != nil {
+		return ChildChanges{}, err
+	}
+	for _, aircrew := range updated {
 		query := `
 			UPDATE aircrews
 			SET
@@ -842,7 +1116,7 @@ func UpdateAircrews(txid uuid.UUID, flight_log types.FlightLogDTO) ([]uuid.UUID,
 				, aircrew_role_type = ?
 			WHERE id = UUID_TO_BIN(?)
 		`
-		_, err := database.Exec(
+		_, err := transaction.Exec(
 			query,
 			flight_log.ID,
 			aircrew.UserID,
@@ -869,22 +1143,69 @@ func UpdateAircrews(txid uuid.UUID, flight_log types.FlightLogDTO) ([]uuid.UUID,
 		)
 		if err != nil {
 			log.Printf("failed aircrew update\n%s\n", err.Error())
-			return nil, errors.New(err_string)
+			return ChildChanges{}, errors.New(err_string)
 		}
-		ids = append(ids, aircrew.ID)
+		changes.Updated = append(changes.Updated, aircrew.ID)
 	}
-	return ids, nil
+	return changes, nil
 }
 
-func UpdateFlightLog(txid uuid.UUID, flight_log types.FlightLogDTO) (uuid.UUID, error) {
+// UpdateDerivedTimes writes the computed columns of a flight log back after
+// they have been derived from its missions.
+func UpdateDerivedTimes(txid uuid.UUID, transaction *sql.Tx, flight_log types.FlightLogDTO, total_aircrew_sorties int) error {
+	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(UpdateDerivedTimes))
+	err_string := fmt.Sprintf("database error: %s\n", txid.String())
+
+	mission_query := `
+		UPDATE missions
+		SET
+			total_time_decimal = ?
+			, total_time_display = ?
+			, total_landings = ?
+		WHERE id = UUID_TO_BIN(?)
+	`
+	for _, mission := range flight_log.Missions {
+		_, err := transaction.Exec(
+			mission_query,
+			mission.TotalTimeDecimal,
+			mission.TotalTimeDisplay,
+			mission.TotalLandings,
+			// WHERE clause
+			mission.ID,
+		)
+		if err != nil {
+			log.Printf("failed mission derived time update: %s\n%s\n", mission.ID, err.Error())
+			return errors.New(err_string)
+		}
+	}
+	if len(flight_log.Missions) == 0 {
+		return nil
+	}
+
+	flight_log_query := `UPDATE flight_logs SET total_flight_decimal_time = ? WHERE id = UUID_TO_BIN(?)`
+	_, err := transaction.Exec(flight_log_query, flight_log.TotalFlightDecimalTime, flight_log.ID)
+	if err != nil {
+		log.Printf("failed flight log derived time update: %s\n%s\n", flight_log.ID, err.Error())
+		return errors.New(err_string)
+	}
+	aircrews_query := `UPDATE aircrews SET total_aircrew_sorties = ? WHERE flight_log_id = UUID_TO_BIN(?)`
+	_, err = transaction.Exec(aircrews_query, total_aircrew_sorties, flight_log.ID)
+	if err != nil {
+		log.Printf("failed aircrew derived sorties update: %s\n%s\n", flight_log.ID, err.Error())
+		return errors.New(err_string)
+	}
+	return nil
+}
+
+func UpdateFlightLog(txid uuid.UUID, transaction *sql.Tx, flight_log types.FlightLogDTO) (uuid.UUID, error) {
 	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(UpdateFlightLog))
 	err_string := fmt.Sprintf("database error: %s\n", txid.String())
 
-	database, err := GetInstance()
+	err := requireFlightLogEditable(txid, transaction, flight_log.ID)
 	if err != nil {
-		log.Printf("failed to connect to database\n%s\n", err.Error())
-		return uuid.Nil, errors.New(err_string)
+		return uuid.Nil, err
 	}
+
 	query := `
 		UPDATE flight_logs
 		SET
@@ -898,16 +1219,11 @@ func UpdateFlightLog(txid uuid.UUID, flight_log types.FlightLogDTO) (uuid.UUID,
 			, is_training_flight = ?
 			, is_training_only = ?
 			, total_flight_decimal_time = ?
-			, scheduler_signature_id = UUID_TO_BIN(?)
-			, sarm_signature_id = UUID_TO_BIN(?)
-			, instructor_signature_id = UUID_TO_BIN(?)
-			, student_signature_id = UUID_TO_BIN(?)
-			, training_officer_signature_id = UUID_TO_BIN(?)
 			, type = ?
 			, remarks = ?
 		WHERE id = UUID_TO_BIN(?)
 	`
-	_, err = database.Exec(
+	_, err = transaction.Exec(
 		query,
 		flight_log.MDS,
 		flight_log.FlightLogDate,
@@ -919,11 +1235,6 @@ func UpdateFlightLog(txid uuid.UUID, flight_log types.FlightLogDTO) (uuid.UUID,
 		flight_log.IsTrainingFlight,
 		flight_log.IsTrainingOnly,
 		flight_log.TotalFlightDecimalTime,
-		flight_log.SchedulerSignatureID,
-		flight_log.SarmSignatureID,
-		flight_log.InstructorSignatureID,
-		flight_log.StudentSignatureID,
-		flight_log.TrainingOfficerSignatureID,
 		flight_log.Type,
 		flight_log.Remarks,
 		// WHERE clause
@@ -936,18 +1247,46 @@ func UpdateFlightLog(txid uuid.UUID, flight_log types.FlightLogDTO) (uuid.UUID,
 	return flight_log.ID, nil
 }
 
-func UpdateMissions(txid uuid.UUID, flight_log types.FlightLogDTO) ([]uuid.UUID, error) {
+func UpdateMissions(txid uuid.UUID, transaction *sql.Tx, flight_log types.FlightLogDTO) (ChildChanges, error) {
 	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(UpdateMissions))
 	err_string := fmt.Sprintf("database error: %s\n", txid.String())
 
-	database, err := GetInstance()
+	err := requireFlightLogEditable(txid, transaction, flight_log.ID)
 	if err != nil {
-		log.Printf("failed to connect to database\n%s\n", err.Error())
-		return nil, errors.New(err_string)
+		return ChildChanges{}, err
 	}
 
-	ids := []uuid.UUID{}
-	for _, mission := range flight_log.Missions {
+	existing_ids, err := selectChildIDs(txid, transaction, "missions", flight_log.ID)
+	if err != nil {
+		return ChildChanges{}, err
+	}
+	created := []types.FlightLogMissionDTO{}
+	updated := []types.FlightLogMissionDTO{}
+	for i, mission := range flight_log.Missions {
+		if mission.ID == uuid.Nil {
+			created = append(created, mission)
+			continue
+		}
+		if _, ok := existing_ids[mission.ID]; !ok {
+			log.Printf("mission: %s does not belong to flight log: %s\n", mission.ID, flight_log.ID)
+			return ChildChanges{}, &ForeignChildError{Path: fmt.Sprintf("missions[%d].id", i), Message: fmt.Sprintf("mission: %s does not belong to flight log: %s", mission.ID, flight_log.ID)}
+		}
+		delete(existing_ids, mission.ID)
+		updated = append(updated, mission)
+	}
+
+	changes := ChildChanges{Updated: []uuid.UUID{}}
+	// Entries without an ID are new
+	changes.Created, err = InsertMissions(txid, transaction, types.FlightLogDTO{ID: flight_log.ID, Missions: created})
+	if err != nil {
+		return ChildChanges{}, err
+	}
+	// Anything stored that the caller no longer sent was removed
+	changes.Deleted, err = deleteChildren(txid, transaction, "missions", existing_ids)
+	if err != nil {
+		return ChildChanges{}, err
+	}
+	for _, mission := range updated {
 		query := `
 			UPDATE missions
 			SET
@@ -966,7 +1305,7 @@ func UpdateMissions(txid uuid.UUID, flight_log types.FlightLogDTO) ([]uuid.UUID,
 				, sorties = ?
 			WHERE id = UUID_TO_BIN(?)
 		`
-		_, err := database.Exec(
+		_, err := transaction.Exec(
 			query,
 			flight_log.ID,
 			mission.MissionNumber,
@@ -986,9 +1325,70 @@ func UpdateMissions(txid uuid.UUID, flight_log types.FlightLogDTO) ([]uuid.UUID,
 		)
 		if err != nil {
 			log.Printf("failed mission update\n%s\n", err.Error())
-			return nil, errors.New(err_string)
+			return ChildChanges{}, errors.New(err_string)
 		}
-		ids = append(ids, mission.ID)
+		changes.Updated = append(changes.Updated, mission.ID)
 	}
-	return ids, nil
+	return changes, nil
+}
+
+func aircrewSetClauses(aircrew AircrewPatchDTO) ([]string, []interface{}) {
+	set_clauses := []string{}
+	arguments := []interface{}{}
+	set_clauses, arguments = AddNullableUUID("user_id", aircrew.UserID, set_clauses, arguments)
+	set_clauses, arguments = AddNullableString("flying_origin", aircrew.FlyingOrigin, set_clauses, arguments)
+	set_clauses, arguments = AddNullableString("flight_auth_code", aircrew.FlightAuthCode, set_clauses, arguments)
+	set_clauses, arguments = AddNullableFloat("time_primary", aircrew.TimePrimary, set_clauses, arguments)
+	set_clauses, arguments = AddNullableFloat("time_secondary", aircrew.TimeSecondary, set_clauses, arguments)
+	set_clauses, arguments = AddNullableFloat("time_instructor", aircrew.TimeInstructor, set_clauses, arguments)
+	set_clauses, arguments = AddNullableFloat("time_evaluator", aircrew.TimeEvaluator, set_clauses, arguments)
+	set_clauses, arguments = AddNullableFloat("time_other", aircrew.TimeOther, set_clauses, arguments)
+	set_clauses, arguments = AddNullableFloat("total_aircrew_duration_decimal", aircrew.TotalAircrewDurationDecimal, set_clauses, arguments)
+	set_clauses, arguments = AddNullableInt("total_aircrew_sorties", aircrew.TotalAircrewSorties, set_clauses, arguments)
+	set_clauses, arguments = AddNullableFloat("cond_night_time", aircrew.CondNightTime, set_clauses, arguments)
+	set_clauses, arguments = AddNullableFloat("cond_instrument_time", aircrew.CondInstrumentTime, set_clauses, arguments)
+	set_clauses, arguments = AddNullableFloat("cond_sim_instrument_time", aircrew.CondSimInstrumentTime, set_clauses, arguments)
+	set_clauses, arguments = AddNullableFloat("cond_nvg_time", aircrew.CondNvgTime, set_clauses, arguments)
+	set_clauses, arguments = AddNullableFloat("cond_combat_time", aircrew.CondCombatTime, set_clauses, arguments)
+	set_clauses, arguments = AddNullableInt("cond_combat_sortie", aircrew.CondCombatSortie, set_clauses, arguments)
+	set_clauses, arguments = AddNullableFloat("cond_combat_support_time", aircrew.CondCombatSupportTime, set_clauses, arguments)
+	set_clauses, arguments = AddNullableInt("cond_combat_support_sortie", aircrew.CondCombatSupportSortie, set_clauses, arguments)
+	set_clauses, arguments = AddNullableString("aircrew_role_type", aircrew.AircrewRoleType, set_clauses, arguments)
+	return set_clauses, arguments
+}
+
+func flightLogSetClauses(patch FlightLogPatchDTO) ([]string, []interface{}) {
+	set_clauses := []string{}
+	arguments := []interface{}{}
+	set_clauses, arguments = AddNullableString("mds", patch.MDS, set_clauses, arguments)
+	set_clauses, arguments = AddNullableTime("flight_log_date", patch.FlightLogDate, set_clauses, arguments)
+	set_clauses, arguments = AddNullableString("serial_number", patch.SerialNumber, set_clauses, arguments)
+	set_clauses, arguments = AddNullableString("unit_charged", patch.UnitCharged, set_clauses, arguments)
+	set_clauses, arguments = AddNullableString("harm_location", patch.HarmLocation, set_clauses, arguments)
+	set_clauses, arguments = AddNullableString("flight_authorization", patch.FlightAuthorization, set_clauses, arguments)
+	set_clauses, arguments = AddNullableString("issuing_unit", patch.IssuingUnit, set_clauses, arguments)
+	set_clauses, arguments = AddNullableBool("is_training_flight", patch.IsTrainingFlight, set_clauses, arguments)
+	set_clauses, arguments = AddNullableBool("is_training_only", patch.IsTrainingOnly, set_clauses, arguments)
+	set_clauses, arguments = AddNullableFloat("total_flight_decimal_time", patch.TotalFlightDecimalTime, set_clauses, arguments)
+	set_clauses, arguments = AddNullableString("type", patch.Type, set_clauses, arguments)
+	set_clauses, arguments = AddNullableString("remarks", patch.Remarks, set_clauses, arguments)
+	return set_clauses, arguments
+}
+
+func missionSetClauses(mission MissionPatchDTO) ([]string, []interface{}) {
+	set_clauses := []string{}
+	arguments := []interface{}{}
+	set_clauses, arguments = AddNullableInt("mission_number", mission.MissionNumber, set_clauses, arguments)
+	set_clauses, arguments = AddNullableString("mission_symbol", mission.MissionSymbol, set_clauses, arguments)
+	set_clauses, arguments = AddNullableString("mission_from", mission.MissionFrom, set_clauses, arguments)
+	set_clauses, arguments = AddNullableString("mission_to", mission.MissionTo, set_clauses, arguments)
+	set_clauses, arguments = AddNullableTime("takeoff_time", mission.TakeoffTime, set_clauses, arguments)
+	set_clauses, arguments = AddNullableTime("land_time", mission.LandTime, set_clauses, arguments)
+	set_clauses, arguments = AddNullableFloat("total_time_decimal", mission.TotalTimeDecimal, set_clauses, arguments)
+	set_clauses, arguments = AddNullableString("total_time_display", mission.TotalTimeDisplay, set_clauses, arguments)
+	set_clauses, arguments = AddNullableInt("touch_and_gos", mission.TouchAndGos, set_clauses, arguments)
+	set_clauses, arguments = AddNullableInt("full_stops", mission.FullStops, set_clauses, arguments)
+	set_clauses, arguments = AddNullableInt("total_landings", mission.TotalLandings, set_clauses, arguments)
+	set_clauses, arguments = AddNullableInt("sorties", mission.Sorties, set_clauses, arguments)
+	return set_clauses, arguments
 }
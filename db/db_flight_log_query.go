@@ -0,0 +1,348 @@
+package db
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/thedanisaur/jfl_platform/types"
+)
+
+const (
+	DefaultFlightLogLimit = 50
+	MaxFlightLogLimit     = 500
+)
+
+// Only the columns listed here may appear in sort or cursor expressions, the
+// request never reaches the SQL string directly. Nullable text columns sort
+// through COALESCE so a NULL orders and compares as the "" a cursor stores
+// for it.
+type sortColumn struct {
+	column string
+	kind   string
+	value  func(flight_log types.FlightLogDTO) interface{}
+}
+
+var flight_log_sort_columns = map[string]sortColumn{
+	"flight_log_date": {
+		column: "flight_logs.flight_log_date",
+		kind:   "time",
+		value:  func(flight_log types.FlightLogDTO) interface{} { return flight_log.FlightLogDate },
+	},
+	"total_flight_decimal_time": {
+		column: "flight_logs.total_flight_decimal_time",
+		kind:   "float",
+		value:  func(flight_log types.FlightLogDTO) interface{} { return flight_log.TotalFlightDecimalTime },
+	},
+	"mds": {
+		column: "COALESCE(flight_logs.mds, '')",
+		kind:   "string",
+		value:  func(flight_log types.FlightLogDTO) interface{} { return flight_log.MDS },
+	},
+	"serial_number": {
+		column: "COALESCE(flight_logs.serial_number, '')",
+		kind:   "string",
+		value:  func(flight_log types.FlightLogDTO) interface{} { return flight_log.SerialNumber },
+	},
+	"unit_charged": {
+		column: "COALESCE(flight_logs.unit_charged, '')",
+		kind:   "string",
+		value:  func(flight_log types.FlightLogDTO) interface{} { return flight_log.UnitCharged },
+	},
+	"type": {
+		column: "COALESCE(flight_logs.type, '')",
+		kind:   "string",
+		value:  func(flight_log types.FlightLogDTO) interface{} { return flight_log.Type },
+	},
+}
+
+type SortField struct {
+	Name       string
+	Descending bool
+}
+
+type FlightLogCursor struct {
+	Values []interface{}
+	ID     uuid.UUID
+}
+
+type FlightLogQuery struct {
+	Limit            int
+	Sort             []SortField
+	After            *FlightLogCursor
+	DateFrom         *time.Time
+	DateTo           *time.Time
+	MDS              string
+	SerialNumber     string
+	UnitCharged      string
+	IsTrainingFlight *bool
+	Type             string
+}
+
+type FlightLogPage struct {
+	FlightLogs []types.FlightLogDTO `json:"flight_logs"`
+	NextCursor string               `json:"next_cursor"`
+	TotalCount int                  `json:"total_count"`
+}
+
+// cursorPayload is what an opaque cursor decodes to. The sort expression is
+// carried along so a cursor cannot be replayed against a different ordering.
+type cursorPayload struct {
+	Sort   string            `json:"s"`
+	Values []json.RawMessage `json:"v"`
+	ID     uuid.UUID         `json:"id"`
+}
+
+// ParseFlightLogSort turns "flight_log_date,-total_flight_decimal_time" into
+// sort fields. An empty expression sorts newest first.
+func ParseFlightLogSort(sort string) ([]SortField, error) {
+	if strings.TrimSpace(sort) == "" {
+		return []SortField{{Name: "flight_log_date", Descending: true}}, nil
+	}
+	fields := []SortField{}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(sort, ",") {
+		part = strings.TrimSpace(part)
+		field := SortField{Name: strings.TrimPrefix(part, "-"), Descending: strings.HasPrefix(part, "-")}
+		if _, ok := flight_log_sort_columns[field.Name]; !ok {
+			return nil, fmt.Errorf("invalid sort field: %s", field.Name)
+		}
+		if seen[field.Name] {
+			return nil, fmt.Errorf("duplicate sort field: %s", field.Name)
+		}
+		seen[field.Name] = true
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+func DecodeFlightLogCursor(cursor string, sort []SortField) (*FlightLogCursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	bytes, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	var payload cursorPayload
+	err = json.Unmarshal(bytes, &payload)
+	if err != nil || payload.Sort != sortExpression(sort) || len(payload.Values) != len(sort) {
+		return nil, errors.New("invalid cursor")
+	}
+	decoded := FlightLogCursor{ID: payload.ID}
+	for index, field := range sort {
+		var value interface{}
+		switch flight_log_sort_columns[field.Name].kind {
+		case "time":
+			var t time.Time
+			err = json.Unmarshal(payload.Values[index], &t)
+			value = t
+		case "float":
+			var f float64
+			err = json.Unmarshal(payload.Values[index], &f)
+			value = f
+		default:
+			var s string
+			err = json.Unmarshal(payload.Values[index], &s)
+			value = s
+		}
+		if err != nil {
+			return nil, errors.New("invalid cursor")
+		}
+		decoded.Values = append(decoded.Values, value)
+	}
+	return &decoded, nil
+}
+
+func encodeFlightLogCursor(flight_log types.FlightLogDTO, sort []SortField) (string, error) {
+	payload := cursorPayload{Sort: sortExpression(sort), ID: flight_log.ID}
+	for _, field := range sort {
+		value, err := json.Marshal(flight_log_sort_columns[field.Name].value(flight_log))
+		if err != nil {
+			return "", err
+		}
+		payload.Values = append(payload.Values, value)
+	}
+	bytes, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+func sortExpression(sort []SortField) string {
+	parts := []string{}
+	for _, field := range sort {
+		if field.Descending {
+			parts = append(parts, "-"+field.Name)
+		} else {
+			parts = append(parts, field.Name)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// queryFlightLogPage runs a page of flight logs inside the given scope. The
+// scope is the policy WHERE clause (plus any owner filter) and is applied to
+// both the page and the total count.
+func queryFlightLogPage(txid uuid.UUID, scope_clause string, scope_args []interface{}, query FlightLogQuery) (FlightLogPage, error) {
+	err_string := fmt.Sprintf("database error: %s\n", txid.String())
+	database, err := GetInstance()
+	if err != nil {
+		log.Printf("failed to connect to database\n%s\n", err.Error())
+		return FlightLogPage{}, errors.New(err_string)
+	}
+
+	where_clauses := []string{"(" + scope_clause + ")"}
+	arguments := append([]interface{}{}, scope_args...)
+	if query.DateFrom != nil {
+		where_clauses = append(where_clauses, "flight_logs.flight_log_date >= ?")
+		arguments = append(arguments, *query.DateFrom)
+	}
+	if query.DateTo != nil {
+		where_clauses = append(where_clauses, "flight_logs.flight_log_date <= ?")
+		arguments = append(arguments, *query.DateTo)
+	}
+	if query.MDS != "" {
+		where_clauses = append(where_clauses, "flight_logs.mds = ?")
+		arguments = append(arguments, query.MDS)
+	}
+	if query.SerialNumber != "" {
+		where_clauses = append(where_clauses, "flight_logs.serial_number = ?")
+		arguments = append(arguments, query.SerialNumber)
+	}
+	if query.UnitCharged != "" {
+		where_clauses = append(where_clauses, "flight_logs.unit_charged = ?")
+		arguments = append(arguments, query.UnitCharged)
+	}
+	if query.IsTrainingFlight != nil {
+		where_clauses = append(where_clauses, "flight_logs.is_training_flight = ?")
+		arguments = append(arguments, *query.IsTrainingFlight)
+	}
+	if query.Type != "" {
+		where_clauses = append(where_clauses, "flight_logs.type = ?")
+		arguments = append(arguments, query.Type)
+	}
+
+	/* Total ignores the cursor so it stays stable while paging */
+	count_query := "SELECT COUNT(*) FROM flight_logs WHERE " + strings.Join(where_clauses, " AND ")
+	var page FlightLogPage
+	err = database.QueryRow(count_query, arguments...).Scan(&page.TotalCount)
+	if err != nil {
+		log.Printf("Failed to count flight logs\n%s\n", err.Error())
+		return FlightLogPage{}, errors.New("failed to retrieve flight logs")
+	}
+
+	/* Keyset predicate: rows strictly after the cursor in sort order, id breaks ties */
+	if query.After != nil {
+		or_clauses := []string{}
+		equal_clauses := []string{}
+		equal_args := []interface{}{}
+		for index, field := range query.Sort {
+			column := flight_log_sort_columns[field.Name].column
+			operator := ">"
+			if field.Descending {
+				operator = "<"
+			}
+			clause := append(append([]string{}, equal_clauses...), fmt.Sprintf("%s %s ?", column, operator))
+			or_clauses = append(or_clauses, "("+strings.Join(clause, " AND ")+")")
+			arguments = append(arguments, equal_args...)
+			arguments = append(arguments, query.After.Values[index])
+			equal_clauses = append(equal_clauses, fmt.Sprintf("%s = ?", column))
+			equal_args = append(equal_args, query.After.Values[index])
+		}
+		clause := append(append([]string{}, equal_clauses...), "flight_logs.id > UUID_TO_BIN(?)")
+		or_clauses = append(or_clauses, "("+strings.Join(clause, " AND ")+")")
+		arguments = append(arguments, equal_args...)
+		arguments = append(arguments, query.After.ID)
+		where_clauses = append(where_clauses, "("+strings.Join(or_clauses, " OR ")+")")
+	}
+
+	order_clauses := []string{}
+	for _, field := range query.Sort {
+		direction := "ASC"
+		if field.Descending {
+			direction = "DESC"
+		}
+		order_clauses = append(order_clauses, flight_log_sort_columns[field.Name].column+" "+direction)
+	}
+	order_clauses = append(order_clauses, "flight_logs.id ASC")
+
+	/* Fetch one extra row to learn whether another page exists */
+	flight_log_query := fmt.Sprintf(`
+		SELECT BIN_TO_UUID(flight_logs.id) AS "flight_log_id"
+			, BIN_TO_UUID(flight_logs.user_id) AS "user_id"
+			, flight_logs.mds
+			, flight_logs.flight_log_date
+			, flight_logs.serial_number
+			, flight_logs.unit_charged
+			, flight_logs.harm_location
+			, flight_logs.flight_authorization
+			, flight_logs.issuing_unit
+			, flight_logs.is_training_flight
+			, flight_logs.is_training_only
+			, flight_logs.total_flight_decimal_time
+			, flight_logs.scheduler_signature_id
+			, flight_logs.sarm_signature_id
+			, flight_logs.instructor_signature_id
+			, flight_logs.student_signature_id
+			, flight_logs.training_officer_signature_id
+			, flight_logs.type
+			, flight_logs.remarks
+		FROM flight_logs
+		WHERE %s
+		ORDER BY %s
+		LIMIT %d
+	`, strings.Join(where_clauses, " AND "), strings.Join(order_clauses, ", "), query.Limit+1)
+	rows, err := database.Query(flight_log_query, arguments...)
+	if err != nil {
+		log.Printf("Failed to retrieve flight logs\n%s\n", err.Error())
+		return FlightLogPage{}, errors.New("failed to retrieve flight logs")
+	}
+	defer rows.Close()
+
+	page.FlightLogs = make([]types.FlightLogDTO, 0)
+	for rows.Next() {
+		var flight_log types.FlightLogDTO
+		err := rows.Scan(
+			&flight_log.ID,
+			&flight_log.UserID,
+			&flight_log.MDS,
+			&flight_log.FlightLogDate,
+			&flight_log.SerialNumber,
+			&flight_log.UnitCharged,
+			&flight_log.HarmLocation,
+			&flight_log.FlightAuthorization,
+			&flight_log.IssuingUnit,
+			&flight_log.IsTrainingFlight,
+			&flight_log.IsTrainingOnly,
+			&flight_log.TotalFlightDecimalTime,
+			&flight_log.SchedulerSignatureID,
+			&flight_log.SarmSignatureID,
+			&flight_log.InstructorSignatureID,
+			&flight_log.StudentSignatureID,
+			&flight_log.TrainingOfficerSignatureID,
+			&flight_log.Type,
+			&flight_log.Remarks,
+		)
+		if err != nil {
+			log.Printf("Failed to parse a flight log\n%s\n", err.Error())
+			return FlightLogPage{}, errors.New("failed to parse a flight log")
+		}
+		page.FlightLogs = append(page.FlightLogs, flight_log)
+	}
+	if len(page.FlightLogs) > query.Limit {
+		page.FlightLogs = page.FlightLogs[:query.Limit]
+		page.NextCursor, err = encodeFlightLogCursor(page.FlightLogs[query.Limit-1], query.Sort)
+		if err != nil {
+			log.Printf("Failed to encode flight log cursor\n%s\n", err.Error())
+			return FlightLogPage{}, errors.New(err_string)
+		}
+	}
+	return page, nil
+}
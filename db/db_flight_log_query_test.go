@@ -0,0 +1,85 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/thedanisaur/jfl_platform/types"
+)
+
+func TestFlightLogCursorRoundTrip(t *testing.T) {
+	sort, err := ParseFlightLogSort("-flight_log_date,total_flight_decimal_time,mds")
+	if err != nil {
+		t.Fatalf("ParseFlightLogSort: %s", err)
+	}
+	flight_log := types.FlightLogDTO{
+		ID:                     uuid.New(),
+		FlightLogDate:          time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+		TotalFlightDecimalTime: 1.5,
+		MDS:                    "T-38C",
+	}
+	cursor, err := encodeFlightLogCursor(flight_log, sort)
+	if err != nil {
+		t.Fatalf("encodeFlightLogCursor: %s", err)
+	}
+	decoded, err := DecodeFlightLogCursor(cursor, sort)
+	if err != nil {
+		t.Fatalf("DecodeFlightLogCursor: %s", err)
+	}
+	if decoded.ID != flight_log.ID {
+		t.Errorf("ID = %s, want %s", decoded.ID, flight_log.ID)
+	}
+	if date, ok := decoded.Values[0].(time.Time); !ok || !date.Equal(flight_log.FlightLogDate) {
+		t.Errorf("flight_log_date = %v, want %s", decoded.Values[0], flight_log.FlightLogDate)
+	}
+	if decoded.Values[1] != 1.5 {
+		t.Errorf("total_flight_decimal_time = %v, want 1.5", decoded.Values[1])
+	}
+	if decoded.Values[2] != "T-38C" {
+		t.Errorf("mds = %v, want T-38C", decoded.Values[2])
+	}
+}
+
+func TestDecodeFlightLogCursorRejects(t *testing.T) {
+	sort, _ := ParseFlightLogSort("mds")
+	cursor, err := encodeFlightLogCursor(types.FlightLogDTO{ID: uuid.New(), MDS: "T-38C"}, sort)
+	if err != nil {
+		t.Fatalf("encodeFlightLogCursor: %s", err)
+	}
+	other_sort, _ := ParseFlightLogSort("-mds")
+	cases := map[string]struct {
+		cursor string
+		sort   []SortField
+	}{
+		"not base64":     {"!!!", sort},
+		"not json":       {"bm90IGpzb24", sort},
+		"different sort": {cursor, other_sort},
+	}
+	for name, test := range cases {
+		if _, err := DecodeFlightLogCursor(test.cursor, test.sort); err == nil {
+			t.Errorf("%s: cursor was accepted", name)
+		}
+	}
+	if decoded, err := DecodeFlightLogCursor("", sort); decoded != nil || err != nil {
+		t.Errorf("empty cursor = %v, %v, want nil, nil", decoded, err)
+	}
+}
+
+func TestParseFlightLogSort(t *testing.T) {
+	cases := []struct {
+		sort  string
+		valid bool
+	}{
+		{"", true},
+		{"mds,-serial_number", true},
+		{"remarks", false},
+		{"mds,-mds", false},
+	}
+	for _, test := range cases {
+		_, err := ParseFlightLogSort(test.sort)
+		if (err == nil) != test.valid {
+			t.Errorf("ParseFlightLogSort(%q) error = %v, want valid %v", test.sort, err, test.valid)
+		}
+	}
+}
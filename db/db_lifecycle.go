@@ -0,0 +1,169 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/thedanisaur/jfl_platform/types"
+	"github.com/thedanisaur/jfl_platform/util"
+)
+
+// Flight log lifecycle states. Only draft logs accept edits; the rest are
+// locked until a transition returns them to draft.
+const (
+	FlightLogStatusDraft     = "draft"
+	FlightLogStatusSubmitted = "submitted"
+	FlightLogStatusSigned    = "signed"
+	FlightLogStatusClosed    = "closed"
+)
+
+var ErrFlightLogLocked = errors.New("flight log is locked")
+
+func GetFlightLogStatus(txid uuid.UUID, user_id uuid.UUID, flight_log_id uuid.UUID) (string, error) {
+	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(GetFlightLogStatus))
+	database, err := GetInstance()
+	if err != nil {
+		log.Printf("Failed to connect to DB\n%s\n", err.Error())
+		return "", errors.New("failed to connect to DB")
+	}
+	query := `SELECT status FROM flight_logs WHERE id = UUID_TO_BIN(?) AND user_id = UUID_TO_BIN(?)`
+	var status string
+	err = database.QueryRow(query, flight_log_id, user_id).Scan(&status)
+	if err != nil {
+		log.Printf("Failed to retrieve flight log status: %s\n%s\n", flight_log_id, err.Error())
+		return "", fmt.Errorf("failed to retrieve flight log status: %s", flight_log_id)
+	}
+	return status, nil
+}
+
+func GetFlightLogTransitions(txid uuid.UUID, flight_log_id uuid.UUID) ([]FlightLogTransitionDTO, error) {
+	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(GetFlightLogTransitions))
+	database, err := GetInstance()
+	if err != nil {
+		log.Printf("Failed to connect to DB\n%s\n", err.Error())
+		return nil, errors.New("failed to connect to DB")
+	}
+	query := `
+		SELECT BIN_TO_UUID(id) AS id
+			, BIN_TO_UUID(flight_log_id) AS flight_log_id
+			, from_status
+			, to_status
+			, BIN_TO_UUID(user_id) AS user_id
+			, role_name
+			, reason
+			, created_on
+		FROM flight_log_transitions
+		WHERE flight_log_id = UUID_TO_BIN(?)
+		ORDER BY created_on, id
+	`
+	rows, err := database.Query(query, flight_log_id)
+	if err != nil {
+		log.Printf("Failed to retrieve transitions for flight log: %s \n%s\n", flight_log_id, err.Error())
+		return nil, fmt.Errorf("failed to retrieve transitions for flight log: %s", flight_log_id)
+	}
+	defer rows.Close()
+
+	transitions := make([]FlightLogTransitionDTO, 0)
+	for rows.Next() {
+		var transition FlightLogTransitionDTO
+		err := rows.Scan(
+			&transition.ID,
+			&transition.FlightLogID,
+			&transition.FromStatus,
+			&transition.ToStatus,
+			&transition.UserID,
+			&transition.RoleName,
+			&transition.Reason,
+			&transition.CreatedOn,
+		)
+		if err != nil {
+			log.Printf("Failed to parse transition for flight log: %s \n%s\n", flight_log_id, err.Error())
+			return nil, fmt.Errorf("failed to parse transition for flight log: %s", flight_log_id)
+		}
+		transitions = append(transitions, transition)
+	}
+	return transitions, nil
+}
+
+// InsertFlightLogTransition moves a flight log to to_status and appends the
+// move to its history. The caller is expected to have validated the
+// transition against the status returned by LockFlightLogStatus.
+func InsertFlightLogTransition(txid uuid.UUID, transaction *sql.Tx, flight_log_id uuid.UUID, from_status string, to_status string, user types.UserClaims, reason string) (uuid.UUID, error) {
+	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(InsertFlightLogTransition))
+	err_string := fmt.Sprintf("database error: %s\n", txid.String())
+
+	query := `
+		INSERT INTO flight_log_transitions
+		(
+			id
+			, flight_log_id
+			, from_status
+			, to_status
+			, user_id
+			, role_name
+			, reason
+		)
+		VALUES
+		(
+			UUID_TO_BIN(?), -- id
+			UUID_TO_BIN(?), -- flight_log_id
+			?, -- from_status
+			?, -- to_status
+			UUID_TO_BIN(?), -- user_id
+			?, -- role_name
+			? -- reason
+		)
+	`
+	id := uuid.New()
+	_, err := transaction.Exec(
+		query,
+		id,
+		flight_log_id,
+		from_status,
+		to_status,
+		user.UserID,
+		user.RoleName,
+		reason,
+	)
+	if err != nil {
+		log.Printf("failed flight log transition insert: %s\n%s\n", flight_log_id, err.Error())
+		return uuid.Nil, errors.New(err_string)
+	}
+
+	status_query := `UPDATE flight_logs SET status = ? WHERE id = UUID_TO_BIN(?)`
+	_, err = transaction.Exec(status_query, to_status, flight_log_id)
+	if err != nil {
+		log.Printf("failed flight log status update: %s\n%s\n", flight_log_id, err.Error())
+		return uuid.Nil, errors.New(err_string)
+	}
+	return id, nil
+}
+
+func LockFlightLogStatus(txid uuid.UUID, transaction *sql.Tx, flight_log_id uuid.UUID) (string, error) {
+	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(LockFlightLogStatus))
+	query := `SELECT status FROM flight_logs WHERE id = UUID_TO_BIN(?) FOR UPDATE`
+	var status string
+	err := transaction.QueryRow(query, flight_log_id).Scan(&status)
+	if err != nil {
+		log.Printf("Failed to retrieve flight log status: %s\n%s\n", flight_log_id, err.Error())
+		return "", fmt.Errorf("failed to retrieve flight log status: %s", flight_log_id)
+	}
+	return status, nil
+}
+
+// requireFlightLogEditable guards every write to a flight log and its
+// children, returning ErrFlightLogLocked unless the log is a draft.
+func requireFlightLogEditable(txid uuid.UUID, transaction *sql.Tx, flight_log_id uuid.UUID) error {
+	status, err := LockFlightLogStatus(txid, transaction, flight_log_id)
+	if err != nil {
+		return err
+	}
+	if status != FlightLogStatusDraft {
+		log.Printf("flight log: %s is %s\n", flight_log_id, status)
+		return fmt.Errorf("%w: %s", ErrFlightLogLocked, status)
+	}
+	return nil
+}
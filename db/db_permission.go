@@ -10,6 +10,18 @@ import (
 	"github.com/thedanisaur/jfl_platform/util"
 )
 
+// AuthorizeFlightLog evaluates a policy WHERE clause from auth.EvaluateRead
+// against a single flight log owned by user_id.
+func AuthorizeFlightLog(txid uuid.UUID, user_id uuid.UUID, flight_log_id uuid.UUID, where_clause string, where_args []interface{}) (bool, error) {
+	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(AuthorizeFlightLog))
+	return authorizeRow(txid, "flight_logs", user_id, flight_log_id, where_clause, where_args)
+}
+
+func AuthorizeTemplateFlightLog(txid uuid.UUID, user_id uuid.UUID, template_id uuid.UUID, where_clause string, where_args []interface{}) (bool, error) {
+	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(AuthorizeTemplateFlightLog))
+	return authorizeRow(txid, "template_flight_logs", user_id, template_id, where_clause, where_args)
+}
+
 func LoadPermissions(txid uuid.UUID, role_name string, resource string, operation string) ([]types.PermissionDTO, error) {
 	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(LoadPermissions))
 	err_string := fmt.Sprintf("database error: %s\n", txid.String())
@@ -56,3 +68,28 @@ func LoadPermissions(txid uuid.UUID, role_name string, resource string, operatio
 	}
 	return permissions, nil
 }
+
+func authorizeRow(txid uuid.UUID, table string, user_id uuid.UUID, id uuid.UUID, where_clause string, where_args []interface{}) (bool, error) {
+	err_string := fmt.Sprintf("database error: %s\n", txid.String())
+	database, err := GetInstance()
+	if err != nil {
+		log.Printf("failed to connect to database\n%s\n", err.Error())
+		return false, errors.New(err_string)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT COUNT(*)
+		FROM %[1]s
+		WHERE %[1]s.id = UUID_TO_BIN(?)
+		  AND %[1]s.user_id = UUID_TO_BIN(?)
+		  AND (%[2]s)
+	`, table, where_clause)
+	arguments := append([]interface{}{id, user_id}, where_args...)
+	var count int
+	err = database.QueryRow(query, arguments...).Scan(&count)
+	if err != nil {
+		log.Printf("Failed to authorize %s: %s for user: %s\n%s\n", table, id, user_id, err.Error())
+		return false, errors.New(err_string)
+	}
+	return count > 0, nil
+}
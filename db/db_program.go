@@ -0,0 +1,94 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/thedanisaur/jfl_platform/util"
+)
+
+// FlyingHourMonth is the aircraft time a unit charged to one MDS in one
+// calendar month. Hours are flight_logs.total_flight_decimal_time, so each log
+// counts once however many crew flew it.
+type FlyingHourMonth struct {
+	UnitCharged string    `json:"unit_charged"`
+	MDS         string    `json:"mds"`
+	Month       time.Time `json:"month"`
+	Flights     int       `json:"flights"`
+	Hours       float64   `json:"hours"`
+	Sorties     int       `json:"sorties"`
+}
+
+// GetFlyingHourRollup sums flight logs between date_from (inclusive) and
+// date_to (exclusive) by unit charged, MDS and month. unit_charged and mds
+// narrow the result when set. The policy WHERE clause is applied as-is.
+func GetFlyingHourRollup(txid uuid.UUID, where_clause string, where_args []interface{}, date_from time.Time, date_to time.Time, unit_charged string, mds string) ([]FlyingHourMonth, error) {
+	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(GetFlyingHourRollup))
+	err_string := fmt.Sprintf("database error: %s\n", txid.String())
+	database, err := GetInstance()
+	if err != nil {
+		log.Printf("Failed to connect to DB\n%s\n", err.Error())
+		return nil, errors.New("failed to connect to DB")
+	}
+
+	conditions := []string{"flight_logs.flight_log_date >= ?", "flight_logs.flight_log_date < ?"}
+	arguments := []interface{}{date_from, date_to}
+	if unit_charged != "" {
+		conditions = append(conditions, "flight_logs.unit_charged = ?")
+		arguments = append(arguments, unit_charged)
+	}
+	if mds != "" {
+		conditions = append(conditions, "flight_logs.mds = ?")
+		arguments = append(arguments, mds)
+	}
+	if where_clause != "" {
+		conditions = append(conditions, "("+where_clause+")")
+		arguments = append(arguments, where_args...)
+	}
+
+	query := `
+		SELECT COALESCE(flight_logs.unit_charged, '') AS unit_charged
+			, COALESCE(flight_logs.mds, '') AS mds
+			, DATE_FORMAT(flight_logs.flight_log_date, '%Y-%m-01') AS month
+			, COUNT(*) AS flights
+			, COALESCE(SUM(flight_logs.total_flight_decimal_time), 0) AS hours
+			, COALESCE(SUM(mission_sorties.sorties), 0) AS sorties
+		FROM flight_logs
+		LEFT JOIN (
+			SELECT flight_log_id, SUM(sorties) AS sorties
+			FROM missions
+			GROUP BY flight_log_id
+		) mission_sorties ON mission_sorties.flight_log_id = flight_logs.id
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		GROUP BY unit_charged, mds, month
+		ORDER BY unit_charged, mds, month
+	`
+	rows, err := database.Query(query, arguments...)
+	if err != nil {
+		log.Printf("Failed to retrieve flying hour rollup\n%s\n", err.Error())
+		return nil, errors.New(err_string)
+	}
+	defer rows.Close()
+
+	months := make([]FlyingHourMonth, 0)
+	for rows.Next() {
+		var month FlyingHourMonth
+		var month_start string
+		err := rows.Scan(&month.UnitCharged, &month.MDS, &month_start, &month.Flights, &month.Hours, &month.Sorties)
+		if err != nil {
+			log.Printf("Failed to parse flying hour rollup\n%s\n", err.Error())
+			return nil, errors.New(err_string)
+		}
+		month.Month, err = time.Parse("2006-01-02", month_start)
+		if err != nil {
+			log.Printf("Failed to parse flying hour month: %s\n%s\n", month_start, err.Error())
+			return nil, errors.New(err_string)
+		}
+		months = append(months, month)
+	}
+	return months, nil
+}
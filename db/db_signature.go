@@ -0,0 +1,203 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/thedanisaur/jfl_platform/types"
+	"github.com/thedanisaur/jfl_platform/util"
+)
+
+// signature_columns maps a signing role to the flight_logs column holding its
+// active signature. It doubles as the whitelist for role names in SQL.
+var signature_columns = map[string]string{
+	"scheduler":        "scheduler_signature_id",
+	"instructor":       "instructor_signature_id",
+	"student":          "student_signature_id",
+	"training_officer": "training_officer_signature_id",
+	"sarm":             "sarm_signature_id",
+}
+
+func GetFlightLogSignatures(txid uuid.UUID, flight_log_id uuid.UUID) ([]FlightLogSignatureDTO, error) {
+	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(GetFlightLogSignatures))
+	database, err := GetInstance()
+	if err != nil {
+		log.Printf("Failed to connect to DB\n%s\n", err.Error())
+		return nil, errors.New("failed to connect to DB")
+	}
+	query := `
+		SELECT BIN_TO_UUID(id) AS id
+			, BIN_TO_UUID(flight_log_id) AS flight_log_id
+			, role
+			, BIN_TO_UUID(user_id) AS user_id
+			, role_name
+			, content_hash
+			, flight_log_version
+			, signed_on
+			, invalidated_on
+		FROM flight_log_signatures
+		WHERE flight_log_id = UUID_TO_BIN(?)
+		ORDER BY signed_on, id
+	`
+	rows, err := database.Query(query, flight_log_id)
+	if err != nil {
+		log.Printf("Failed to retrieve signatures for flight log: %s \n%s\n", flight_log_id, err.Error())
+		return nil, fmt.Errorf("failed to retrieve signatures for flight log: %s", flight_log_id)
+	}
+	defer rows.Close()
+
+	signatures := make([]FlightLogSignatureDTO, 0)
+	for rows.Next() {
+		var signature FlightLogSignatureDTO
+		err := rows.Scan(
+			&signature.ID,
+			&signature.FlightLogID,
+			&signature.Role,
+			&signature.UserID,
+			&signature.RoleName,
+			&signature.ContentHash,
+			&signature.FlightLogVersion,
+			&signature.SignedOn,
+			&signature.InvalidatedOn,
+		)
+		if err != nil {
+			log.Printf("Failed to parse signature for flight log: %s \n%s\n", flight_log_id, err.Error())
+			return nil, fmt.Errorf("failed to parse signature for flight log: %s", flight_log_id)
+		}
+		signatures = append(signatures, signature)
+	}
+	return signatures, nil
+}
+
+// InsertFlightLogSignature records a signature and points the role's column on
+// flight_logs at it. Any previous signature for the role must already have
+// been invalidated by the caller.
+func InsertFlightLogSignature(txid uuid.UUID, transaction *sql.Tx, flight_log_id uuid.UUID, role string, signer types.UserClaims, content_hash string, version int) (uuid.UUID, error) {
+	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(InsertFlightLogSignature))
+	err_string := fmt.Sprintf("database error: %s\n", txid.String())
+
+	column, ok := signature_columns[role]
+	if !ok {
+		return uuid.Nil, fmt.Errorf("invalid signature role: %s", role)
+	}
+
+	query := `
+		INSERT INTO flight_log_signatures
+		(
+			id
+			, flight_log_id
+			, role
+			, user_id
+			, role_name
+			, content_hash
+			, flight_log_version
+		)
+		VALUES
+		(
+			UUID_TO_BIN(?), -- id
+			UUID_TO_BIN(?), -- flight_log_id
+			?, -- role
+			UUID_TO_BIN(?), -- user_id
+			?, -- role_name
+			?, -- content_hash
+			? -- flight_log_version
+		)
+	`
+	id := uuid.New()
+	_, err := transaction.Exec(
+		query,
+		id,
+		flight_log_id,
+		role,
+		signer.UserID,
+		signer.RoleName,
+		content_hash,
+		version,
+	)
+	if err != nil {
+		log.Printf("failed flight log signature insert: %s\n%s\n", flight_log_id, err.Error())
+		return uuid.Nil, errors.New(err_string)
+	}
+
+	flight_log_query := fmt.Sprintf(`UPDATE flight_logs SET %s = UUID_TO_BIN(?) WHERE id = UUID_TO_BIN(?)`, column)
+	_, err = transaction.Exec(flight_log_query, id, flight_log_id)
+	if err != nil {
+		log.Printf("failed flight log signature update: %s\n%s\n", flight_log_id, err.Error())
+		return uuid.Nil, errors.New(err_string)
+	}
+	return id, nil
+}
+
+// InvalidateFlightLogSignatures stamps the active signatures for roles as
+// invalidated and clears their columns on flight_logs. It returns the ids of
+// the signatures that were invalidated.
+func InvalidateFlightLogSignatures(txid uuid.UUID, transaction *sql.Tx, flight_log_id uuid.UUID, roles []string) ([]uuid.UUID, error) {
+	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(InvalidateFlightLogSignatures))
+	err_string := fmt.Sprintf("database error: %s\n", txid.String())
+
+	invalidated := []uuid.UUID{}
+	if len(roles) == 0 {
+		return invalidated, nil
+	}
+	set_clauses := make([]string, 0, len(roles))
+	placeholders := make([]string, 0, len(roles))
+	role_arguments := make([]interface{}, 0, len(roles))
+	for _, role := range roles {
+		column, ok := signature_columns[role]
+		if !ok {
+			return nil, fmt.Errorf("invalid signature role: %s", role)
+		}
+		set_clauses = append(set_clauses, fmt.Sprintf("%s = NULL", column))
+		placeholders = append(placeholders, "?")
+		role_arguments = append(role_arguments, role)
+	}
+
+	select_query := fmt.Sprintf(`
+		SELECT BIN_TO_UUID(id) AS id
+		FROM flight_log_signatures
+		WHERE flight_log_id = UUID_TO_BIN(?)
+		  AND invalidated_on IS NULL
+		  AND role IN (%s)
+		FOR UPDATE
+	`, strings.Join(placeholders, ", "))
+	arguments := append([]interface{}{flight_log_id}, role_arguments...)
+	rows, err := transaction.Query(select_query, arguments...)
+	if err != nil {
+		log.Printf("failed flight log signature select: %s\n%s\n", flight_log_id, err.Error())
+		return nil, errors.New(err_string)
+	}
+	for rows.Next() {
+		var id uuid.UUID
+		err = rows.Scan(&id)
+		if err != nil {
+			rows.Close()
+			log.Printf("failed flight log signature scan: %s\n%s\n", flight_log_id, err.Error())
+			return nil, errors.New(err_string)
+		}
+		invalidated = append(invalidated, id)
+	}
+	rows.Close()
+	if len(invalidated) == 0 {
+		return invalidated, nil
+	}
+
+	in_clause, in_arguments := inUUIDs(invalidated)
+	update_query := fmt.Sprintf(`UPDATE flight_log_signatures SET invalidated_on = NOW() WHERE id IN (%s)`, in_clause)
+	_, err = transaction.Exec(update_query, in_arguments...)
+	if err != nil {
+		log.Printf("failed flight log signature invalidate: %s\n%s\n", flight_log_id, err.Error())
+		return nil, errors.New(err_string)
+	}
+
+	flight_log_query := fmt.Sprintf(`UPDATE flight_logs SET %s WHERE id = UUID_TO_BIN(?)`, strings.Join(set_clauses, ", "))
+	_, err = transaction.Exec(flight_log_query, flight_log_id)
+	if err != nil {
+		log.Printf("failed flight log signature clear: %s\n%s\n", flight_log_id, err.Error())
+		return nil, errors.New(err_string)
+	}
+	return invalidated, nil
+}
@@ -1,10 +1,11 @@
 package db
 
 import (
-	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"log"
+	"strings"
 
 	"github.com/thedanisaur/jfl_platform/types"
 	"github.com/thedanisaur/jfl_platform/util"
@@ -12,23 +13,12 @@ import (
 	"github.com/google/uuid"
 )
 
-func DeleteTemplateFlightlog(txid uuid.UUID, user_id uuid.UUID, template_id uuid.UUID) (uuid.UUID, error) {
+func DeleteTemplateFlightlog(txid uuid.UUID, transaction *sql.Tx, user_id uuid.UUID, template_id uuid.UUID) (uuid.UUID, error) {
 	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(DeleteTemplateFlightlog))
-	database, err := GetInstance()
-	if err != nil {
-		log.Printf("Failed to connect to DB\n%s\n", err.Error())
-		return uuid.Nil, errors.New("failed to connect to DB")
-	}
-	transaction, err := database.BeginTx(context.Background(), nil)
-	if err != nil {
-		log.Printf("Failed to initiate transaction\n%s\n", err.Error())
-		return uuid.Nil, errors.New("failed to connect to DB")
-	}
-	defer transaction.Rollback()
 
 	// Delete template flight log's aircrew records
 	aircrews_query := `DELETE FROM template_aircrews WHERE flight_log_id = UUID_TO_BIN(?)`
-	aircrews_result, err := database.Exec(aircrews_query, template_id)
+	aircrews_result, err := transaction.Exec(aircrews_query, template_id)
 	if err != nil {
 		log.Printf("Failed to delete template aircrews: %s for user: %s\n%s\n", template_id, user_id, err.Error())
 		return uuid.Nil, errors.New("failed to delete template aircrews")
@@ -40,7 +30,7 @@ func DeleteTemplateFlightlog(txid uuid.UUID, user_id uuid.UUID, template_id uuid
 
 	// Delete template flight log's mission records
 	missions_query := `DELETE FROM template_missions WHERE flight_log_id = UUID_TO_BIN(?)`
-	missions_result, err := database.Exec(missions_query, template_id)
+	missions_result, err := transaction.Exec(missions_query, template_id)
 	if err != nil {
 		log.Printf("Failed to delete template missions: %s for user: %s\n%s\n", template_id, user_id, err.Error())
 		return uuid.Nil, errors.New("failed to delete template missions")
@@ -50,9 +40,17 @@ func DeleteTemplateFlightlog(txid uuid.UUID, user_id uuid.UUID, template_id uuid
 		return uuid.Nil, errors.New("failed to delete template missions")
 	}
 
+	// Delete template flight log's revisions, which are unreachable once the template is gone
+	revisions_query := `DELETE FROM template_flight_log_revisions WHERE template_id = UUID_TO_BIN(?)`
+	_, err = transaction.Exec(revisions_query, template_id)
+	if err != nil {
+		log.Printf("Failed to delete template flight log revisions: %s for user: %s\n%s\n", template_id, user_id, err.Error())
+		return uuid.Nil, errors.New("failed to delete template flight log revisions")
+	}
+
 	// Delete template flight log
 	template_query := `DELETE FROM template_flight_logs WHERE id = UUID_TO_BIN(?)`
-	template_result, err := database.Exec(template_query, template_id)
+	template_result, err := transaction.Exec(template_query, template_id)
 	if err != nil {
 		log.Printf("Failed to delete template flight log: %s for user: %s\n%s\n", template_id, user_id, err.Error())
 		return uuid.Nil, errors.New("failed to delete template flight log")
@@ -72,71 +70,7 @@ func GetTemplateAirCrews(txid uuid.UUID, template_id uuid.UUID) ([]types.FlightL
 		log.Printf("Failed to connect to DB\n%s\n", err.Error())
 		return nil, errors.New("failed to connect to DB")
 	}
-	query := `
-		SELECT BIN_TO_UUID(id) AS id
-			, BIN_TO_UUID(flight_log_id) AS flight_log_id
-			, user_id
-			, flying_origin
-			, flight_auth_code
-			, time_primary
-			, time_secondary
-			, time_instructor
-			, time_evaluator
-			, time_other
-			, total_aircrew_duration_decimal
-			, total_aircrew_sorties
-			, cond_night_time
-			, cond_instrument_time
-			, cond_sim_instrument_time
-			, cond_nvg_time
-			, cond_combat_time
-			, cond_combat_sortie
-			, cond_combat_support_time
-			, cond_combat_support_sortie
-			, aircrew_role_type
-		FROM template_aircrews
-		WHERE flight_log_id = UUID_TO_BIN(?)
-	`
-	rows, err := database.Query(query, template_id)
-	if err != nil {
-		log.Printf("Failed to retrieve template aircrew members for template flight log: %s \n%s\n", template_id, err.Error())
-		return nil, fmt.Errorf("failed to retrieve template aircrew members for template flight log: %s", template_id)
-	}
-	defer rows.Close()
-
-	aircrews := make([]types.FlightLogAircrewDTO, 0)
-	for rows.Next() {
-		var aircrew types.FlightLogAircrewDTO
-		err := rows.Scan(
-			&aircrew.ID,
-			&aircrew.FlightLogID,
-			&aircrew.UserID,
-			&aircrew.FlyingOrigin,
-			&aircrew.FlightAuthCode,
-			&aircrew.TimePrimary,
-			&aircrew.TimeSecondary,
-			&aircrew.TimeInstructor,
-			&aircrew.TimeEvaluator,
-			&aircrew.TimeOther,
-			&aircrew.TotalAircrewDurationDecimal,
-			&aircrew.TotalAircrewSorties,
-			&aircrew.CondNightTime,
-			&aircrew.CondInstrumentTime,
-			&aircrew.CondSimInstrumentTime,
-			&aircrew.CondNvgTime,
-			&aircrew.CondCombatTime,
-			&aircrew.CondCombatSortie,
-			&aircrew.CondCombatSupportTime,
-			&aircrew.CondCombatSupportSortie,
-			&aircrew.AircrewRoleType,
-		)
-		if err != nil {
-			log.Printf("Failed to parse template aircrew member for template flight log: %s \n%s\n", template_id, err.Error())
-			return nil, fmt.Errorf("failed to parse template aircrew member for template flight log: %s", template_id)
-		}
-		aircrews = append(aircrews, aircrew)
-	}
-	return aircrews, nil
+	return selectTemplateAirCrews(database, template_id)
 }
 
 func GetTemplateFlightlog(txid uuid.UUID, user_id uuid.UUID, template_id uuid.UUID) (types.TemplateFlightLogDTO, error) {
@@ -146,97 +80,50 @@ func GetTemplateFlightlog(txid uuid.UUID, user_id uuid.UUID, template_id uuid.UU
 		log.Printf("Failed to connect to DB\n%s\n", err.Error())
 		return types.TemplateFlightLogDTO{}, errors.New("failed to connect to DB")
 	}
-	query := `
-		SELECT BIN_TO_UUID(id) AS id
-			, name
-			, BIN_TO_UUID(user_id) AS user_id
-			, mds
-			, flight_log_date
-			, serial_number
-			, unit_charged
-			, harm_location
-			, flight_authorization
-			, issuing_unit
-			, is_training_flight
-			, is_training_only
-			, total_flight_decimal_time
-			, scheduler_signature_id
-			, sarm_signature_id
-			, instructor_signature_id
-			, student_signature_id
-			, training_officer_signature_id
-			, type
-			, remarks
-		FROM template_flight_logs
-		WHERE id = UUID_TO_BIN(?) AND user_id = UUID_TO_BIN(?)
-	`
-	row := database.QueryRow(query, template_id, user_id)
-	var template_flight_log_dto types.TemplateFlightLogDTO
-	err = row.Scan(
-		&template_flight_log_dto.ID,
-		&template_flight_log_dto.Name,
-		&template_flight_log_dto.UserID,
-		&template_flight_log_dto.MDS,
-		&template_flight_log_dto.FlightLogDate,
-		&template_flight_log_dto.SerialNumber,
-		&template_flight_log_dto.UnitCharged,
-		&template_flight_log_dto.HarmLocation,
-		&template_flight_log_dto.FlightAuthorization,
-		&template_flight_log_dto.IssuingUnit,
-		&template_flight_log_dto.IsTrainingFlight,
-		&template_flight_log_dto.IsTrainingOnly,
-		&template_flight_log_dto.TotalFlightDecimalTime,
-		&template_flight_log_dto.SchedulerSignatureID,
-		&template_flight_log_dto.SarmSignatureID,
-		&template_flight_log_dto.InstructorSignatureID,
-		&template_flight_log_dto.StudentSignatureID,
-		&template_flight_log_dto.TrainingOfficerSignatureID,
-		&template_flight_log_dto.Type,
-		&template_flight_log_dto.Remarks,
-	)
+	return selectTemplateFlightlog(database, user_id, template_id)
+}
+
+func GetTemplateFlightlogs(txid uuid.UUID, user_id uuid.UUID, where_clause string, where_args []interface{}) ([]SharedTemplateFlightLogDTO, error) {
+	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(GetTemplateFlightlogs))
+	where_clause = fmt.Sprintf("template_flight_logs.user_id = UUID_TO_BIN(?) AND (%s)", where_clause)
+	arguments := append([]interface{}{user_id}, where_args...)
+	template_flight_logs, err := querySharedTemplateFlightLogs(txid, where_clause, arguments)
 	if err != nil {
-		log.Printf("Failed to retrieve template flight log: %s for user: %s\n%s\n", template_id, user_id, err.Error())
-		return types.TemplateFlightLogDTO{}, errors.New("failed to retrieve template flight log")
+		log.Printf("Failed to retrieve template flight logs for user: %s\n%s\n", user_id, err.Error())
+		return nil, err
 	}
-	return template_flight_log_dto, nil
+	return template_flight_logs, nil
 }
 
-func GetTemplateFlightlogs(txid uuid.UUID, user_id uuid.UUID) ([]types.TemplateFlightLogDTO, error) {
-	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(GetTemplateFlightlogs))
+// GetTemplateFlightlogsAll lists every template the policy clause admits,
+// whoever owns it. Personal templates are only ever listed for their owner.
+func GetTemplateFlightlogsAll(txid uuid.UUID, user_id uuid.UUID, where_clause string, where_args []interface{}) ([]SharedTemplateFlightLogDTO, error) {
+	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(GetTemplateFlightlogsAll))
+	where_clause = fmt.Sprintf("(template_flight_logs.scope <> ? OR template_flight_logs.user_id = UUID_TO_BIN(?)) AND (%s)", where_clause)
+	arguments := append([]interface{}{TemplateScopePersonal, user_id}, where_args...)
+	template_flight_logs, err := querySharedTemplateFlightLogs(txid, where_clause, arguments)
+	if err != nil {
+		log.Printf("Failed to retrieve template flight logs visible to user: %s\n%s\n", user_id, err.Error())
+		return nil, err
+	}
+	return template_flight_logs, nil
+}
+
+func GetTemplateFlightLogVersion(txid uuid.UUID, user_id uuid.UUID, template_id uuid.UUID) (int, error) {
+	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(GetTemplateFlightLogVersion))
 	database, err := GetInstance()
 	if err != nil {
 		log.Printf("Failed to connect to DB\n%s\n", err.Error())
-		return nil, errors.New("failed to connect to DB")
+		return 0, errors.New("failed to connect to DB")
 	}
-	query := `
-		SELECT BIN_TO_UUID(id) AS id
-			, name
-			, BIN_TO_UUID(user_id) AS user_id
-		FROM template_flight_logs
-		WHERE user_id = UUID_TO_BIN(?)
-	`
-	rows, err := database.Query(query, user_id)
+	query := `SELECT version FROM template_flight_logs WHERE id = UUID_TO_BIN(?) AND user_id = UUID_TO_BIN(?)`
+	var version int
+	err = database.QueryRow(query, template_id, user_id).Scan(&version)
 	if err != nil {
-		log.Printf("Failed to retrieve template flight logs for user: %s\n%s\n", user_id, err.Error())
-		return nil, errors.New("failed to retrieve template flight logs")
-	}
-	defer rows.Close()
-
-	template_flight_logs := make([]types.TemplateFlightLogDTO, 0)
-	for rows.Next() {
-		var template_flight_log types.TemplateFlightLogDTO
-		err := rows.Scan(
-			&template_flight_log.ID,
-			&template_flight_log.Name,
-			&template_flight_log.UserID,
-		)
-		if err != nil {
-			log.Printf("Failed to parse a template flight log for user: %s \n%s\n", user_id, err.Error())
-			return nil, fmt.Errorf("failed to parse a template flight log for user: %s", user_id)
-		}
-		template_flight_logs = append(template_flight_logs, template_flight_log)
+		log.Printf("Failed to retrieve template flight log version: %s for user: %s\n%s\n", template_id, user_id, err.Error())
+		return 0, errors.New("failed to retrieve template flight log")
 	}
-	return template_flight_logs, nil
+	return version, nil
 }
 
 func GetTemplateMissions(txid uuid.UUID, template_id uuid.UUID) ([]types.FlightLogMissionDTO, error) {
@@ -246,67 +133,17 @@ func GetTemplateMissions(txid uuid.UUID, template_id uuid.UUID) ([]types.FlightL
 		log.Printf("Failed to connect to DB\n%s\n", err.Error())
 		return nil, errors.New("failed to connect to DB")
 	}
-	query := `
-		SELECT BIN_TO_UUID(id) AS id
-			, BIN_TO_UUID(flight_log_id) AS flight_log_id
-			, mission_number
-			, mission_symbol
-			, mission_from
-			, mission_to
-			, takeoff_time
-			, land_time
-			, total_time_decimal
-			, total_time_display
-			, touch_and_gos
-			, full_stops
-			, total_landings
-			, sorties
-		FROM template_missions
-		WHERE flight_log_id = UUID_TO_BIN(?)
-	`
-	rows, err := database.Query(query, template_id)
-	if err != nil {
-		log.Printf("Failed to retrieve template missions for template flight log: %s \n%s\n", template_id, err.Error())
-		return nil, fmt.Errorf("failed to retrieve template missions for template flight log: %s", template_id)
-	}
-	defer rows.Close()
+	return selectTemplateMissions(database, template_id)
+}
 
-	template_missions := make([]types.FlightLogMissionDTO, 0)
-	for rows.Next() {
-		var template_mission types.FlightLogMissionDTO
-		err := rows.Scan(
-			&template_mission.ID,
-			&template_mission.FlightLogID,
-			&template_mission.MissionNumber,
-			&template_mission.MissionSymbol,
-			&template_mission.MissionFrom,
-			&template_mission.MissionTo,
-			&template_mission.TakeoffTime,
-			&template_mission.LandTime,
-			&template_mission.TotalTimeDecimal,
-			&template_mission.TotalTimeDisplay,
-			&template_mission.TouchAndGos,
-			&template_mission.FullStops,
-			&template_mission.TotalLandings,
-			&template_mission.Sorties,
-		)
-		if err != nil {
-			log.Printf("Failed to parse a template mission leg for template flight log: %s \n%s\n", template_id, err.Error())
-			return nil, fmt.Errorf("failed to parse a template mission leg for template flight log: %s", template_id)
-		}
-		template_missions = append(template_missions, template_mission)
-	}
-	return template_missions, nil
+func IncrementTemplateFlightLogVersion(txid uuid.UUID, transaction *sql.Tx, template_id uuid.UUID) (int, error) {
+	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(IncrementTemplateFlightLogVersion))
+	return incrementVersion(txid, transaction, "template_flight_logs", template_id)
 }
 
-func InsertTemplateAircrews(txid uuid.UUID, flight_log types.TemplateFlightLogDTO) ([]uuid.UUID, error) {
+func InsertTemplateAircrews(txid uuid.UUID, transaction *sql.Tx, flight_log types.TemplateFlightLogDTO) ([]uuid.UUID, error) {
 	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(InsertTemplateAircrews))
 	err_string := fmt.Sprintf("database error: %s\n", txid.String())
-	database, err := GetInstance()
-	if err != nil {
-		log.Printf("failed to connect to database\n%s\n", err.Error())
-		return nil, errors.New(err_string)
-	}
 	ids := []uuid.UUID{}
 	for _, aircrew := range flight_log.Aircrew {
 		query := `
@@ -360,7 +197,7 @@ func InsertTemplateAircrews(txid uuid.UUID, flight_log types.TemplateFlightLogDT
 			)
 		`
 		id := uuid.New()
-		_, err = database.Exec(
+		_, err := transaction.Exec(
 			query,
 			id,
 			flight_log.ID,
@@ -393,14 +230,9 @@ func InsertTemplateAircrews(txid uuid.UUID, flight_log types.TemplateFlightLogDT
 	return ids, nil
 }
 
-func InsertTemplateFlightLog(txid uuid.UUID, request_user_id uuid.UUID, template_flight_log types.TemplateFlightLogDTO) (uuid.UUID, error) {
+func InsertTemplateFlightLog(txid uuid.UUID, transaction *sql.Tx, request_user_id uuid.UUID, template_flight_log types.TemplateFlightLogDTO) (uuid.UUID, error) {
 	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(InsertTemplateFlightLog))
 	err_string := fmt.Sprintf("database error: %s\n", txid.String())
-	database, err := GetInstance()
-	if err != nil {
-		log.Printf("failed to connect to database\n%s\n", err.Error())
-		return uuid.Nil, errors.New(err_string)
-	}
 	query := `
 		INSERT INTO template_flight_logs
 		(
@@ -450,7 +282,7 @@ func InsertTemplateFlightLog(txid uuid.UUID, request_user_id uuid.UUID, template
 		)
 	`
 	id := uuid.New()
-	_, err = database.Exec(
+	_, err := transaction.Exec(
 		query,
 		id,
 		template_flight_log.Name,
@@ -480,14 +312,9 @@ func InsertTemplateFlightLog(txid uuid.UUID, request_user_id uuid.UUID, template
 	return id, nil
 }
 
-func InsertTemplateMissions(txid uuid.UUID, flight_log types.TemplateFlightLogDTO) ([]uuid.UUID, error) {
+func InsertTemplateMissions(txid uuid.UUID, transaction *sql.Tx, flight_log types.TemplateFlightLogDTO) ([]uuid.UUID, error) {
 	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(InsertTemplateMissions))
 	err_string := fmt.Sprintf("database error: %s\n", txid.String())
-	database, err := GetInstance()
-	if err != nil {
-		log.Printf("failed to connect to database\n%s\n", err.Error())
-		return nil, errors.New(err_string)
-	}
 	ids := []uuid.UUID{}
 	for _, mission := range flight_log.Missions {
 		query := `
@@ -527,7 +354,7 @@ func InsertTemplateMissions(txid uuid.UUID, flight_log types.TemplateFlightLogDT
 			)
 		`
 		id := uuid.New()
-		_, err = database.Exec(
+		_, err := transaction.Exec(
 			query,
 			id,
 			flight_log.ID,
@@ -535,8 +362,8 @@ func InsertTemplateMissions(txid uuid.UUID, flight_log types.TemplateFlightLogDT
 			mission.MissionSymbol,
 			mission.MissionFrom,
 			mission.MissionTo,
-			mission.TakeoffTime,
-			mission.LandTime,
+			nullableTime(mission.TakeoffTime),
+			nullableTime(mission.LandTime),
 			mission.TotalTimeDecimal,
 			mission.TotalTimeDisplay,
 			mission.TouchAndGos,
@@ -553,18 +380,111 @@ func InsertTemplateMissions(txid uuid.UUID, flight_log types.TemplateFlightLogDT
 	return ids, nil
 }
 
-func UpdateTemplateAircrews(txid uuid.UUID, flight_log types.TemplateFlightLogDTO) ([]uuid.UUID, error) {
+func LockTemplateFlightLogVersion(txid uuid.UUID, transaction *sql.Tx, template_id uuid.UUID) (int, error) {
+	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(LockTemplateFlightLogVersion))
+	return lockVersion(txid, transaction, "template_flight_logs", template_id)
+}
+
+func PatchTemplateAircrews(txid uuid.UUID, transaction *sql.Tx, template_id uuid.UUID, patch TemplateFlightLogPatchDTO) ([]uuid.UUID, error) {
+	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(PatchTemplateAircrews))
+
+	existing_ids, err := selectChildIDs(txid, transaction, "template_aircrews", template_id)
+	if err != nil {
+		return nil, err
+	}
+	ids := []uuid.UUID{}
+	for i, aircrew := range patch.Aircrew {
+		if _, ok := existing_ids[aircrew.ID]; !ok {
+			log.Printf("template aircrew: %s does not belong to template flight log: %s\n", aircrew.ID, template_id)
+			return nil, &ForeignChildError{Path: fmt.Sprintf("aircrew[%d].id", i), Message: fmt.Sprintf("template aircrew: %s does not belong to template flight log: %s", aircrew.ID, template_id)}
+		}
+		set_clauses, arguments := aircrewSetClauses(aircrew)
+		err = patchRow(txid, transaction, "template_aircrews", aircrew.ID, set_clauses, arguments)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, aircrew.ID)
+	}
+	return ids, nil
+}
+
+func PatchTemplateFlightLog(txid uuid.UUID, transaction *sql.Tx, user_id uuid.UUID, template_id uuid.UUID, patch TemplateFlightLogPatchDTO) (uuid.UUID, error) {
+	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(PatchTemplateFlightLog))
+
+	query := `SELECT BIN_TO_UUID(id) AS id FROM template_flight_logs WHERE id = UUID_TO_BIN(?) AND user_id = UUID_TO_BIN(?) FOR UPDATE`
+	var id uuid.UUID
+	err := transaction.QueryRow(query, template_id, user_id).Scan(&id)
+	if err != nil {
+		log.Printf("Failed to retrieve template flight log: %s for user: %s\n%s\n", template_id, user_id, err.Error())
+		return uuid.Nil, errors.New("failed to retrieve template flight log")
+	}
+	set_clauses, arguments := flightLogSetClauses(patch.FlightLogPatchDTO)
+	set_clauses, arguments = AddNullableString("name", patch.Name, set_clauses, arguments)
+	err = patchRow(txid, transaction, "template_flight_logs", id, set_clauses, arguments)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return id, nil
+}
+
+func PatchTemplateMissions(txid uuid.UUID, transaction *sql.Tx, template_id uuid.UUID, patch TemplateFlightLogPatchDTO) ([]uuid.UUID, error) {
+	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(PatchTemplateMissions))
+
+	existing_ids, err := selectChildIDs(txid, transaction, "template_missions", template_id)
+	if err != nil {
+		return nil, err
+	}
+	ids := []uuid.UUID{}
+	for i, mission := range patch.Missions {
+		if _, ok := existing_ids[mission.ID]; !ok {
+			log.Printf("template mission: %s does not belong to template flight log: %s\n", mission.ID, template_id)
+			return nil, &ForeignChildError{Path: fmt.Sprintf("missions[%d].id", i), Message: fmt.Sprintf("template mission: %s does not belong to template flight log: %s", mission.ID, template_id)}
+		}
+		set_clauses, arguments := missionSetClauses(mission)
+		err = patchRow(txid, transaction, "template_missions", mission.ID, set_clauses, arguments)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, mission.ID)
+	}
+	return ids, nil
+}
+
+func UpdateTemplateAircrews(txid uuid.UUID, transaction *sql.Tx, flight_log types.TemplateFlightLogDTO) (ChildChanges, error) {
 	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(UpdateTemplateAircrews))
 	err_string := fmt.Sprintf("database error: %s\n", txid.String())
 
-	database, err := GetInstance()
+	existing_ids, err := selectChildIDs(txid, transaction, "template_aircrews", flight_log.ID)
 	if err != nil {
-		log.Printf("failed to connect to database\n%s\n", err.Error())
-		return nil, errors.New(err_string)
+		return ChildChanges{}, err
+	}
+	created := []types.FlightLogAircrewDTO{}
+	updated := []types.FlightLogAircrewDTO{}
+	for i, aircrew := range flight_log.Aircrew {
+		if aircrew.ID == uuid.Nil {
+			created = append(created, aircrew)
+			continue
+		}
+		if _, ok := existing_ids[aircrew.ID]; !ok {
+			log.Printf("template aircrew: %s does not belong to flight log: %s\n", aircrew.ID, flight_log.ID)
+			return ChildChanges{}, &ForeignChildError{Path: fmt.Sprintf("aircrew[%d].id", i), Message: fmt.Sprintf("template aircrew: %s does not belong to flight log: %s", aircrew.ID, flight_log.ID)}
+		}
+		delete(existing_ids, aircrew.ID)
+		updated = append(updated, aircrew)
 	}
 
-	ids := []uuid.UUID{}
-	for _, aircrew := range flight_log.Aircrew {
+	changes := ChildChanges{Updated: []uuid.UUID{}}
+	// Entries without an ID are new
+	changes.Created, err = InsertTemplateAircrews(txid, transaction, types.TemplateFlightLogDTO{ID: flight_log.ID, Aircrew: created})
+	if err != nil {
+		return ChildChanges{}, err
+	}
+	// Anything stored that the caller no longer sent was removed
+	changes.Deleted, err = deleteChildren(txid, transaction, "template_aircrews", existing_ids)
+	if err != nil {
+		return ChildChanges{}, err
+	}
+	for _, aircrew := range updated {
 		query := `
 			UPDATE template_aircrews
 			SET
@@ -590,7 +510,7 @@ func UpdateTemplateAircrews(txid uuid.UUID, flight_log types.TemplateFlightLogDT
 				, aircrew_role_type = ?
 			WHERE id = UUID_TO_BIN(?)
 		`
-		_, err := database.Exec(
+		_, err := transaction.Exec(
 			query,
 			flight_log.ID,
 			aircrew.UserID,
@@ -617,22 +537,17 @@ func UpdateTemplateAircrews(txid uuid.UUID, flight_log types.TemplateFlightLogDT
 		)
 		if err != nil {
 			log.Printf("failed template aircrew update\n%s\n", err.Error())
-			return nil, errors.New(err_string)
+			return ChildChanges{}, errors.New(err_string)
 		}
-		ids = append(ids, aircrew.ID)
+		changes.Updated = append(changes.Updated, aircrew.ID)
 	}
-	return ids, nil
+	return changes, nil
 }
 
-func UpdateTemplateFlightLog(txid uuid.UUID, template_flight_log types.TemplateFlightLogDTO) (uuid.UUID, error) {
+func UpdateTemplateFlightLog(txid uuid.UUID, transaction *sql.Tx, template_flight_log types.TemplateFlightLogDTO) (uuid.UUID, error) {
 	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(UpdateTemplateFlightLog))
 	err_string := fmt.Sprintf("database error: %s\n", txid.String())
 
-	database, err := GetInstance()
-	if err != nil {
-		log.Printf("failed to connect to database\n%s\n", err.Error())
-		return uuid.Nil, errors.New(err_string)
-	}
 	query := `
 		UPDATE template_flight_logs
 		SET
@@ -656,7 +571,7 @@ func UpdateTemplateFlightLog(txid uuid.UUID, template_flight_log types.TemplateF
 			, remarks = ?
 		WHERE id = UUID_TO_BIN(?)
 	`
-	_, err = database.Exec(
+	_, err := transaction.Exec(
 		query,
 		template_flight_log.Name,
 		template_flight_log.MDS,
@@ -686,18 +601,41 @@ func UpdateTemplateFlightLog(txid uuid.UUID, template_flight_log types.TemplateF
 	return template_flight_log.ID, nil
 }
 
-func UpdateTemplateMissions(txid uuid.UUID, flight_log types.TemplateFlightLogDTO) ([]uuid.UUID, error) {
+func UpdateTemplateMissions(txid uuid.UUID, transaction *sql.Tx, flight_log types.TemplateFlightLogDTO) (ChildChanges, error) {
 	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(UpdateTemplateMissions))
 	err_string := fmt.Sprintf("database error: %s\n", txid.String())
 
-	database, err := GetInstance()
+	existing_ids, err := selectChildIDs(txid, transaction, "template_missions", flight_log.ID)
 	if err != nil {
-		log.Printf("failed to connect to database\n%s\n", err.Error())
-		return nil, errors.New(err_string)
+		return ChildChanges{}, err
+	}
+	created := []types.FlightLogMissionDTO{}
+	updated := []types.FlightLogMissionDTO{}
+	for i, mission := range flight_log.Missions {
+		if mission.ID == uuid.Nil {
+			created = append(created, mission)
+			continue
+		}
+		if _, ok := existing_ids[mission.ID]; !ok {
+			log.Printf("template mission: %s does not belong to flight log: %s\n", mission.ID, flight_log.ID)
+			return ChildChanges{}, &ForeignChildError{Path: fmt.Sprintf("missions[%d].id", i), Message: fmt.Sprintf("template mission: %s does not belong to flight log: %s", mission.ID, flight_log.ID)}
+		}
+		delete(existing_ids, mission.ID)
+		updated = append(updated, mission)
 	}
 
-	ids := []uuid.UUID{}
-	for _, mission := range flight_log.Missions {
+	changes := ChildChanges{Updated: []uuid.UUID{}}
+	// Entries without an ID are new
+	changes.Created, err = InsertTemplateMissions(txid, transaction, types.TemplateFlightLogDTO{ID: flight_log.ID, Missions: created})
+	if err != nil {
+		return ChildChanges{}, err
+	}
+	// Anything stored that the caller no longer sent was removed
+	changes.Deleted, err = deleteChildren(txid, transaction, "template_missions", existing_ids)
+	if err != nil {
+		return ChildChanges{}, err
+	}
+	for _, mission := range updated {
 		query := `
 			UPDATE template_missions
 			SET
@@ -716,15 +654,15 @@ func UpdateTemplateMissions(txid uuid.UUID, flight_log types.TemplateFlightLogDT
 				, sorties = ?
 			WHERE id = UUID_TO_BIN(?)
 		`
-		_, err := database.Exec(
+		_, err := transaction.Exec(
 			query,
 			flight_log.ID,
 			mission.MissionNumber,
 			mission.MissionSymbol,
 			mission.MissionFrom,
 			mission.MissionTo,
-			mission.TakeoffTime,
-			mission.LandTime,
+			nullableTime(mission.TakeoffTime),
+			nullableTime(mission.LandTime),
 			mission.TotalTimeDecimal,
 			mission.TotalTimeDisplay,
 			mission.TouchAndGos,
@@ -736,9 +674,232 @@ func UpdateTemplateMissions(txid uuid.UUID, flight_log types.TemplateFlightLogDT
 		)
 		if err != nil {
 			log.Printf("failed template mission update\n%s\n", err.Error())
-			return nil, errors.New(err_string)
+			return ChildChanges{}, errors.New(err_string)
 		}
-		ids = append(ids, mission.ID)
+		changes.Updated = append(changes.Updated, mission.ID)
 	}
-	return ids, nil
+	return changes, nil
+}
+
+func querySharedTemplateFlightLogs(txid uuid.UUID, where_clause string, where_args []interface{}) ([]SharedTemplateFlightLogDTO, error) {
+	database, err := GetInstance()
+	if err != nil {
+		log.Printf("Failed to connect to DB\n%s\n", err.Error())
+		return nil, errors.New("failed to connect to DB")
+	}
+	query := `
+		SELECT BIN_TO_UUID(id) AS id
+			, name
+			, BIN_TO_UUID(user_id) AS user_id
+			, scope
+			, scope_owner
+		FROM template_flight_logs
+		WHERE
+	`
+	query = strings.Join([]string{query, where_clause, "ORDER BY name"}, " ")
+	rows, err := database.Query(query, where_args...)
+	if err != nil {
+		log.Printf("Failed to query template flight logs\n%s\n", err.Error())
+		return nil, errors.New("failed to retrieve template flight logs")
+	}
+	defer rows.Close()
+
+	template_flight_logs := make([]SharedTemplateFlightLogDTO, 0)
+	for rows.Next() {
+		var template_flight_log SharedTemplateFlightLogDTO
+		err := rows.Scan(
+			&template_flight_log.ID,
+			&template_flight_log.Name,
+			&template_flight_log.UserID,
+			&template_flight_log.Sharing.Scope,
+			&template_flight_log.Sharing.Owner,
+		)
+		if err != nil {
+			log.Printf("Failed to parse a template flight log\n%s\n", err.Error())
+			return nil, errors.New("failed to parse a template flight log")
+		}
+		template_flight_logs = append(template_flight_logs, template_flight_log)
+	}
+	return template_flight_logs, nil
+}
+
+func selectTemplateAirCrews(q queryer, template_id uuid.UUID) ([]types.FlightLogAircrewDTO, error) {
+	query := `
+		SELECT BIN_TO_UUID(id) AS id
+			, BIN_TO_UUID(flight_log_id) AS flight_log_id
+			, user_id
+			, flying_origin
+			, flight_auth_code
+			, time_primary
+			, time_secondary
+			, time_instructor
+			, time_evaluator
+			, time_other
+			, total_aircrew_duration_decimal
+			, total_aircrew_sorties
+			, cond_night_time
+			, cond_instrument_time
+			, cond_sim_instrument_time
+			, cond_nvg_time
+			, cond_combat_time
+			, cond_combat_sortie
+			, cond_combat_support_time
+			, cond_combat_support_sortie
+			, aircrew_role_type
+		FROM template_aircrews
+		WHERE flight_log_id = UUID_TO_BIN(?)
+	`
+	rows, err := q.Query(query, template_id)
+	if err != nil {
+		log.Printf("Failed to retrieve template aircrew members for template flight log: %s \n%s\n", template_id, err.Error())
+		return nil, fmt.Errorf("failed to retrieve template aircrew members for template flight log: %s", template_id)
+	}
+	defer rows.Close()
+
+	aircrews := make([]types.FlightLogAircrewDTO, 0)
+	for rows.Next() {
+		var aircrew types.FlightLogAircrewDTO
+		err := rows.Scan(
+			&aircrew.ID,
+			&aircrew.FlightLogID,
+			&aircrew.UserID,
+			&aircrew.FlyingOrigin,
+			&aircrew.FlightAuthCode,
+			&aircrew.TimePrimary,
+			&aircrew.TimeSecondary,
+			&aircrew.TimeInstructor,
+			&aircrew.TimeEvaluator,
+			&aircrew.TimeOther,
+			&aircrew.TotalAircrewDurationDecimal,
+			&aircrew.TotalAircrewSorties,
+			&aircrew.CondNightTime,
+			&aircrew.CondInstrumentTime,
+			&aircrew.CondSimInstrumentTime,
+			&aircrew.CondNvgTime,
+			&aircrew.CondCombatTime,
+			&aircrew.CondCombatSortie,
+			&aircrew.CondCombatSupportTime,
+			&aircrew.CondCombatSupportSortie,
+			&aircrew.AircrewRoleType,
+		)
+		if err != nil {
+			log.Printf("Failed to parse template aircrew member for template flight log: %s \n%s\n", template_id, err.Error())
+			return nil, fmt.Errorf("failed to parse template aircrew member for template flight log: %s", template_id)
+		}
+		aircrews = append(aircrews, aircrew)
+	}
+	return aircrews, nil
+}
+
+func selectTemplateFlightlog(q queryer, user_id uuid.UUID, template_id uuid.UUID) (types.TemplateFlightLogDTO, error) {
+	query := `
+		SELECT BIN_TO_UUID(id) AS id
+			, name
+			, BIN_TO_UUID(user_id) AS user_id
+			, mds
+			, flight_log_date
+			, serial_number
+			, unit_charged
+			, harm_location
+			, flight_authorization
+			, issuing_unit
+			, is_training_flight
+			, is_training_only
+			, total_flight_decimal_time
+			, scheduler_signature_id
+			, sarm_signature_id
+			, instructor_signature_id
+			, student_signature_id
+			, training_officer_signature_id
+			, type
+			, remarks
+		FROM template_flight_logs
+		WHERE id = UUID_TO_BIN(?) AND user_id = UUID_TO_BIN(?)
+	`
+	row := q.QueryRow(query, template_id, user_id)
+	var template_flight_log_dto types.TemplateFlightLogDTO
+	err := row.Scan(
+		&template_flight_log_dto.ID,
+		&template_flight_log_dto.Name,
+		&template_flight_log_dto.UserID,
+		&template_flight_log_dto.MDS,
+		&template_flight_log_dto.FlightLogDate,
+		&template_flight_log_dto.SerialNumber,
+		&template_flight_log_dto.UnitCharged,
+		&template_flight_log_dto.HarmLocation,
+		&template_flight_log_dto.FlightAuthorization,
+		&template_flight_log_dto.IssuingUnit,
+		&template_flight_log_dto.IsTrainingFlight,
+		&template_flight_log_dto.IsTrainingOnly,
+		&template_flight_log_dto.TotalFlightDecimalTime,
+		&template_flight_log_dto.SchedulerSignatureID,
+		&template_flight_log_dto.SarmSignatureID,
+		&template_flight_log_dto.InstructorSignatureID,
+		&template_flight_log_dto.StudentSignatureID,
+		&template_flight_log_dto.TrainingOfficerSignatureID,
+		&template_flight_log_dto.Type,
+		&template_flight_log_dto.Remarks,
+	)
+	if err != nil {
+		log.Printf("Failed to retrieve template flight log: %s for user: %s\n%s\n", template_id, user_id, err.Error())
+		return types.TemplateFlightLogDTO{}, errors.New("failed to retrieve template flight log")
+	}
+	return template_flight_log_dto, nil
+}
+
+func selectTemplateMissions(q queryer, template_id uuid.UUID) ([]types.FlightLogMissionDTO, error) {
+	query := `
+		SELECT BIN_TO_UUID(id) AS id
+			, BIN_TO_UUID(flight_log_id) AS flight_log_id
+			, mission_number
+			, mission_symbol
+			, mission_from
+			, mission_to
+			, takeoff_time
+			, land_time
+			, total_time_decimal
+			, total_time_display
+			, touch_and_gos
+			, full_stops
+			, total_landings
+			, sorties
+		FROM template_missions
+		WHERE flight_log_id = UUID_TO_BIN(?)
+	`
+	rows, err := q.Query(query, template_id)
+	if err != nil {
+		log.Printf("Failed to retrieve template missions for template flight log: %s \n%s\n", template_id, err.Error())
+		return nil, fmt.Errorf("failed to retrieve template missions for template flight log: %s", template_id)
+	}
+	defer rows.Close()
+
+	template_missions := make([]types.FlightLogMissionDTO, 0)
+	for rows.Next() {
+		var template_mission types.FlightLogMissionDTO
+		var takeoff_time, land_time sql.NullTime
+		err := rows.Scan(
+			&template_mission.ID,
+			&template_mission.FlightLogID,
+			&template_mission.MissionNumber,
+			&template_mission.MissionSymbol,
+			&template_mission.MissionFrom,
+			&template_mission.MissionTo,
+			&takeoff_time,
+			&land_time,
+			&template_mission.TotalTimeDecimal,
+			&template_mission.TotalTimeDisplay,
+			&template_mission.TouchAndGos,
+			&template_mission.FullStops,
+			&template_mission.TotalLandings,
+			&template_mission.Sorties,
+		)
+		if err != nil {
+			log.Printf("Failed to parse a template mission leg for template flight log: %s \n%s\n", template_id, err.Error())
+			return nil, fmt.Errorf("failed to parse a template mission leg for template flight log: %s", template_id)
+		}
+		template_mission.TakeoffTime = takeoff_time.Time
+		template_mission.LandTime = land_time.Time
+		template_missions = append(template_missions, template_mission)
+	}
+	return template_missions, nil
 }
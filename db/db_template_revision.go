@@ -0,0 +1,200 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/thedanisaur/jfl_platform/types"
+	"github.com/thedanisaur/jfl_platform/util"
+)
+
+var ErrTemplateRevisionNotFound = errors.New("template flight log version not found")
+
+func GetTemplateRevision(txid uuid.UUID, template_id uuid.UUID, version int) (TemplateRevisionDTO, error) {
+	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(GetTemplateRevision))
+	database, err := GetInstance()
+	if err != nil {
+		log.Printf("Failed to connect to DB\n%s\n", err.Error())
+		return TemplateRevisionDTO{}, errors.New("failed to connect to DB")
+	}
+	query := `
+		SELECT BIN_TO_UUID(template_id) AS template_id
+			, version
+			, BIN_TO_UUID(user_id) AS user_id
+			, created_on
+			, content
+		FROM template_flight_log_revisions
+		WHERE template_id = UUID_TO_BIN(?) AND version = ?
+	`
+	var revision TemplateRevisionDTO
+	var user_id sql.NullString
+	var content []byte
+	err = database.QueryRow(query, template_id, version).Scan(
+		&revision.TemplateID,
+		&revision.Version,
+		&user_id,
+		&revision.CreatedOn,
+		&content,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return TemplateRevisionDTO{}, ErrTemplateRevisionNotFound
+	}
+	if err != nil {
+		log.Printf("Failed to retrieve template flight log: %s version: %d\n%s\n", template_id, version, err.Error())
+		return TemplateRevisionDTO{}, errors.New("failed to retrieve template flight log version")
+	}
+	revision.UserID, err = revisionAuthor(user_id)
+	if err != nil {
+		log.Printf("Failed to parse template flight log: %s version: %d author\n%s\n", template_id, version, err.Error())
+		return TemplateRevisionDTO{}, errors.New("failed to retrieve template flight log version")
+	}
+	revision.Template = &SharedTemplateFlightLogDTO{}
+	err = json.Unmarshal(content, revision.Template)
+	if err != nil {
+		log.Printf("Failed to parse template flight log: %s version: %d content\n%s\n", template_id, version, err.Error())
+		return TemplateRevisionDTO{}, errors.New("failed to retrieve template flight log version")
+	}
+	return revision, nil
+}
+
+func GetTemplateRevisions(txid uuid.UUID, template_id uuid.UUID) ([]TemplateRevisionDTO, error) {
+	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(GetTemplateRevisions))
+	database, err := GetInstance()
+	if err != nil {
+		log.Printf("Failed to connect to DB\n%s\n", err.Error())
+		return nil, errors.New("failed to connect to DB")
+	}
+	query := `
+		SELECT BIN_TO_UUID(template_id) AS template_id
+			, version
+			, BIN_TO_UUID(user_id) AS user_id
+			, created_on
+		FROM template_flight_log_revisions
+		WHERE template_id = UUID_TO_BIN(?)
+		ORDER BY version DESC
+	`
+	rows, err := database.Query(query, template_id)
+	if err != nil {
+		log.Printf("Failed to retrieve versions for template flight log: %s\n%s\n", template_id, err.Error())
+		return nil, fmt.Errorf("failed to retrieve versions for template flight log: %s", template_id)
+	}
+	defer rows.Close()
+
+	revisions := make([]TemplateRevisionDTO, 0)
+	for rows.Next() {
+		var revision TemplateRevisionDTO
+		var user_id sql.NullString
+		err := rows.Scan(
+			&revision.TemplateID,
+			&revision.Version,
+			&user_id,
+			&revision.CreatedOn,
+		)
+		if err == nil {
+			revision.UserID, err = revisionAuthor(user_id)
+		}
+		if err != nil {
+			log.Printf("Failed to parse a version for template flight log: %s\n%s\n", template_id, err.Error())
+			return nil, fmt.Errorf("failed to parse a version for template flight log: %s", template_id)
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, nil
+}
+
+// ReadTemplateFlightLog reads a template with its missions and aircrew through
+// the transaction, so it sees the transaction's own uncommitted writes.
+func ReadTemplateFlightLog(txid uuid.UUID, transaction *sql.Tx, user_id uuid.UUID, template_id uuid.UUID) (types.TemplateFlightLogDTO, error) {
+	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(ReadTemplateFlightLog))
+	template_flight_log, err := selectTemplateFlightlog(transaction, user_id, template_id)
+	if err != nil {
+		return types.TemplateFlightLogDTO{}, err
+	}
+	template_flight_log.Missions, err = selectTemplateMissions(transaction, template_id)
+	if err != nil {
+		return types.TemplateFlightLogDTO{}, err
+	}
+	template_flight_log.Aircrew, err = selectTemplateAirCrews(transaction, template_id)
+	if err != nil {
+		return types.TemplateFlightLogDTO{}, err
+	}
+	return template_flight_log, nil
+}
+
+// RecordTemplateRevision stores the template and its sharing as they stand in
+// the transaction as revision version. Revisions are immutable: if one already exists for
+// that version it is left alone without reading the template, which lets
+// writes call this before editing to capture templates that predate
+// versioning. author is nil for those.
+func RecordTemplateRevision(txid uuid.UUID, transaction *sql.Tx, user_id uuid.UUID, template_id uuid.UUID, version int, author *uuid.UUID) error {
+	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(RecordTemplateRevision))
+	err_string := fmt.Sprintf("database error: %s\n", txid.String())
+
+	/* Callers hold the template's version lock, so nothing can add this revision in between */
+	exists_query := `SELECT COUNT(*) FROM template_flight_log_revisions WHERE template_id = UUID_TO_BIN(?) AND version = ?`
+	var count int
+	err := transaction.QueryRow(exists_query, template_id, version).Scan(&count)
+	if err != nil {
+		log.Printf("failed template flight log revision lookup\n%s\n", err.Error())
+		return errors.New(err_string)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	template_flight_log, err := ReadTemplateFlightLog(txid, transaction, user_id, template_id)
+	if err != nil {
+		return err
+	}
+	sharing, err := selectTemplateSharing(transaction, template_id)
+	if err != nil {
+		log.Printf("failed template flight log sharing lookup\n%s\n", err.Error())
+		return errors.New(err_string)
+	}
+	content, err := json.Marshal(SharedTemplateFlightLogDTO{TemplateFlightLogDTO: template_flight_log, Sharing: sharing})
+	if err != nil {
+		log.Printf("failed to encode template flight log: %s version: %d\n%s\n", template_id, version, err.Error())
+		return errors.New(err_string)
+	}
+	var author_id interface{}
+	if author != nil {
+		author_id = *author
+	}
+	query := `
+		INSERT INTO template_flight_log_revisions
+		(
+			template_id
+			, version
+			, user_id
+			, content
+		)
+		VALUES
+		(
+			UUID_TO_BIN(?), -- template_id
+			?, -- version
+			UUID_TO_BIN(?), -- user_id
+			? -- content
+		)
+	`
+	_, err = transaction.Exec(query, template_id, version, author_id, content)
+	if err != nil {
+		log.Printf("failed template flight log revision insert\n%s\n", err.Error())
+		return errors.New(err_string)
+	}
+	return nil
+}
+
+func revisionAuthor(user_id sql.NullString) (*uuid.UUID, error) {
+	if !user_id.Valid {
+		return nil, nil
+	}
+	id, err := uuid.Parse(user_id.String)
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
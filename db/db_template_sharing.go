@@ -0,0 +1,59 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/thedanisaur/jfl_platform/util"
+)
+
+// Template sharing scopes. Anything but personal is a shared template, which
+// only roles with a templates/share policy may change.
+const (
+	TemplateScopePersonal = "personal"
+	TemplateScopeUnit     = "unit"
+	TemplateScopeWing     = "wing"
+	TemplateScopeGlobal   = "global"
+)
+
+func GetTemplateFlightLogSharing(txid uuid.UUID, template_id uuid.UUID) (TemplateSharingDTO, error) {
+	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(GetTemplateFlightLogSharing))
+	database, err := GetInstance()
+	if err != nil {
+		log.Printf("Failed to connect to DB\n%s\n", err.Error())
+		return TemplateSharingDTO{}, errors.New("failed to connect to DB")
+	}
+	sharing, err := selectTemplateSharing(database, template_id)
+	if err != nil {
+		log.Printf("Failed to retrieve template flight log sharing: %s\n%s\n", template_id, err.Error())
+		return TemplateSharingDTO{}, errors.New("failed to retrieve template flight log")
+	}
+	return sharing, nil
+}
+
+func selectTemplateSharing(q queryer, template_id uuid.UUID) (TemplateSharingDTO, error) {
+	query := `SELECT scope, scope_owner FROM template_flight_logs WHERE id = UUID_TO_BIN(?)`
+	var sharing TemplateSharingDTO
+	err := q.QueryRow(query, template_id).Scan(&sharing.Scope, &sharing.Owner)
+	return sharing, err
+}
+
+func UpdateTemplateFlightLogSharing(txid uuid.UUID, transaction *sql.Tx, template_id uuid.UUID, sharing TemplateSharingDTO) error {
+	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(UpdateTemplateFlightLogSharing))
+	err_string := fmt.Sprintf("database error: %s\n", txid.String())
+	query := `
+		UPDATE template_flight_logs
+		SET scope = ?
+			, scope_owner = ?
+		WHERE id = UUID_TO_BIN(?)
+	`
+	_, err := transaction.Exec(query, sharing.Scope, sharing.Owner, template_id)
+	if err != nil {
+		log.Printf("failed template flight log sharing update\n%s\n", err.Error())
+		return errors.New(err_string)
+	}
+	return nil
+}
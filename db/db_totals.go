@@ -0,0 +1,149 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/thedanisaur/jfl_platform/util"
+)
+
+// FlyingTotals is one crew member's time summed over a period. MDS is empty
+// on the all-aircraft row. Landings come from the missions of every log the
+// crew member flew on.
+type FlyingTotals struct {
+	MDS                  string  `json:"mds,omitempty"`
+	Flights              int     `json:"flights"`
+	Primary              float64 `json:"primary"`
+	Secondary            float64 `json:"secondary"`
+	Instructor           float64 `json:"instructor"`
+	Evaluator            float64 `json:"evaluator"`
+	Other                float64 `json:"other"`
+	Total                float64 `json:"total"`
+	Night                float64 `json:"night"`
+	Instrument           float64 `json:"instrument"`
+	SimInstrument        float64 `json:"sim_instrument"`
+	Nvg                  float64 `json:"nvg"`
+	Combat               float64 `json:"combat"`
+	CombatSupport        float64 `json:"combat_support"`
+	Sorties              int     `json:"sorties"`
+	CombatSorties        int     `json:"combat_sorties"`
+	CombatSupportSorties int     `json:"combat_support_sorties"`
+	Landings             int     `json:"landings"`
+}
+
+// GetFlyingTotals sums a crew member's aircrews rows between date_from
+// (inclusive) and date_to (exclusive), either of which may be nil. The first
+// return is the all-aircraft total, the second the per-MDS breakdown. The
+// policy WHERE clause from auth.EvaluateRead is applied as-is.
+func GetFlyingTotals(txid uuid.UUID, user_id uuid.UUID, where_clause string, where_args []interface{}, date_from *time.Time, date_to *time.Time) (FlyingTotals, []FlyingTotals, error) {
+	log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(GetFlyingTotals))
+	err_string := fmt.Sprintf("database error: %s\n", txid.String())
+	database, err := GetInstance()
+	if err != nil {
+		log.Printf("Failed to connect to DB\n%s\n", err.Error())
+		return FlyingTotals{}, nil, errors.New("failed to connect to DB")
+	}
+
+	conditions := []string{"aircrews.user_id = UUID_TO_BIN(?)"}
+	arguments := []interface{}{user_id}
+	if date_from != nil {
+		conditions = append(conditions, "flight_logs.flight_log_date >= ?")
+		arguments = append(arguments, *date_from)
+	}
+	if date_to != nil {
+		conditions = append(conditions, "flight_logs.flight_log_date < ?")
+		arguments = append(arguments, *date_to)
+	}
+	if where_clause != "" {
+		conditions = append(conditions, "("+where_clause+")")
+		arguments = append(arguments, where_args...)
+	}
+
+	/* WITH ROLLUP adds the all-aircraft row, GROUPING() tells it apart */
+	/* Landings join to one of the crew member's rows per log, so two crew lines on a log count its landings once */
+	query := fmt.Sprintf(`
+		SELECT GROUPING(flight_logs.mds) AS is_total
+			, COALESCE(flight_logs.mds, '') AS mds
+			, COUNT(DISTINCT flight_logs.id) AS flights
+			, COALESCE(SUM(aircrews.time_primary), 0)
+			, COALESCE(SUM(aircrews.time_secondary), 0)
+			, COALESCE(SUM(aircrews.time_instructor), 0)
+			, COALESCE(SUM(aircrews.time_evaluator), 0)
+			, COALESCE(SUM(aircrews.time_other), 0)
+			, COALESCE(SUM(aircrews.total_aircrew_duration_decimal), 0)
+			, COALESCE(SUM(aircrews.cond_night_time), 0)
+			, COALESCE(SUM(aircrews.cond_instrument_time), 0)
+			, COALESCE(SUM(aircrews.cond_sim_instrument_time), 0)
+			, COALESCE(SUM(aircrews.cond_nvg_time), 0)
+			, COALESCE(SUM(aircrews.cond_combat_time), 0)
+			, COALESCE(SUM(aircrews.cond_combat_support_time), 0)
+			, COALESCE(SUM(aircrews.total_aircrew_sorties), 0)
+			, COALESCE(SUM(aircrews.cond_combat_sortie), 0)
+			, COALESCE(SUM(aircrews.cond_combat_support_sortie), 0)
+			, COALESCE(SUM(mission_landings.landings), 0)
+		FROM aircrews
+		JOIN flight_logs ON flight_logs.id = aircrews.flight_log_id
+		LEFT JOIN (
+			SELECT flight_log_id, SUM(total_landings) AS landings
+			FROM missions
+			GROUP BY flight_log_id
+		) mission_landings ON mission_landings.flight_log_id = flight_logs.id
+			AND aircrews.id = (
+				SELECT MIN(first_aircrew.id)
+				FROM aircrews first_aircrew
+				WHERE first_aircrew.flight_log_id = aircrews.flight_log_id
+					AND first_aircrew.user_id = aircrews.user_id
+			)
+		WHERE %s
+		GROUP BY flight_logs.mds WITH ROLLUP
+	`, strings.Join(conditions, " AND "))
+	rows, err := database.Query(query, arguments...)
+	if err != nil {
+		log.Printf("Failed to retrieve flying totals for user: %s\n%s\n", user_id, err.Error())
+		return FlyingTotals{}, nil, errors.New(err_string)
+	}
+	defer rows.Close()
+
+	total := FlyingTotals{}
+	by_mds := make([]FlyingTotals, 0)
+	for rows.Next() {
+		var is_total bool
+		var totals FlyingTotals
+		err := rows.Scan(
+			&is_total,
+			&totals.MDS,
+			&totals.Flights,
+			&totals.Primary,
+			&totals.Secondary,
+			&totals.Instructor,
+			&totals.Evaluator,
+			&totals.Other,
+			&totals.Total,
+			&totals.Night,
+			&totals.Instrument,
+			&totals.SimInstrument,
+			&totals.Nvg,
+			&totals.Combat,
+			&totals.CombatSupport,
+			&totals.Sorties,
+			&totals.CombatSorties,
+			&totals.CombatSupportSorties,
+			&totals.Landings,
+		)
+		if err != nil {
+			log.Printf("Failed to parse flying totals for user: %s\n%s\n", user_id, err.Error())
+			return FlyingTotals{}, nil, errors.New(err_string)
+		}
+		if is_total {
+			totals.MDS = ""
+			total = totals
+			continue
+		}
+		by_mds = append(by_mds, totals)
+	}
+	return total, by_mds, nil
+}
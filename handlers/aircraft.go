@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"log"
+
+	"flight_log_service/db"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/thedanisaur/jfl_platform/types"
+	"github.com/thedanisaur/jfl_platform/util"
+)
+
+func GetAircraft(config types.Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		txid := c.Locals("transaction_id").(uuid.UUID)
+		log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(GetAircraft))
+
+		/* Scoped by AuthorizationMiddleware, the same as GetFlightlogsAll */
+		where_clause := c.Locals("authorization_where_clause").(string)
+		arguments := c.Locals("authorization_arguments").([]interface{})
+
+		aircraft, err := db.GetAircraft(txid, where_clause, arguments, "", c.Query("mds"))
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+
+		response := fiber.Map{
+			"txid":     txid.String(),
+			"aircraft": aircraft,
+		}
+		return c.Status(fiber.StatusOK).JSON(response)
+	}
+}
+
+func GetAircraftHistory(config types.Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		txid := c.Locals("transaction_id").(uuid.UUID)
+		log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(GetAircraftHistory))
+
+		serial_number := c.Params("serial_number")
+
+		/* Scoped by AuthorizationMiddleware, the same as GetFlightlogsAll */
+		where_clause := c.Locals("authorization_where_clause").(string)
+		arguments := c.Locals("authorization_arguments").([]interface{})
+
+		aircraft, err := db.GetAircraft(txid, where_clause, arguments, serial_number, "")
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		if len(aircraft) == 0 {
+			return c.Status(fiber.StatusNotFound).SendString("unknown aircraft")
+		}
+		history, err := db.GetAircraftHistory(txid, where_clause, arguments, serial_number)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+
+		response := fiber.Map{
+			"txid":     txid.String(),
+			"aircraft": aircraft[0],
+			"history":  history,
+		}
+		return c.Status(fiber.StatusOK).JSON(response)
+	}
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"log"
+	"strconv"
+
+	"flight_log_service/airfields"
+	"flight_log_service/db"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/thedanisaur/jfl_platform/types"
+	"github.com/thedanisaur/jfl_platform/util"
+)
+
+const (
+	default_airfield_limit = 20
+	max_airfield_limit     = 100
+)
+
+func GetAirfield(config types.Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		txid := c.Locals("transaction_id").(uuid.UUID)
+		log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(GetAirfield))
+
+		airfield, ok := airfields.Lookup(c.Params("identifier"))
+		if !ok {
+			return c.Status(fiber.StatusNotFound).SendString("unknown airfield")
+		}
+		return c.Status(fiber.StatusOK).JSON(airfield)
+	}
+}
+
+func GetAirfields(config types.Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		txid := c.Locals("transaction_id").(uuid.UUID)
+		log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(GetAirfields))
+
+		limit := default_airfield_limit
+		if c.Query("limit") != "" {
+			parsed, err := strconv.Atoi(c.Query("limit"))
+			if err != nil || parsed < 1 || parsed > max_airfield_limit {
+				return c.Status(fiber.StatusBadRequest).SendString("invalid limit")
+			}
+			limit = parsed
+		}
+
+		response := fiber.Map{
+			"txid":      txid.String(),
+			"airfields": airfields.Search(c.Query("q"), limit),
+		}
+		return c.Status(fiber.StatusOK).JSON(response)
+	}
+}
+
+// normalizeMissionAirfields rewrites mission_from / mission_to to ICAO codes
+// where the registry recognises them. Unknown values are left for the
+// validator to report.
+func normalizeMissionAirfields(missions []types.FlightLogMissionDTO) {
+	for i := range missions {
+		missions[i].MissionFrom, _ = airfields.Normalize(missions[i].MissionFrom)
+		missions[i].MissionTo, _ = airfields.Normalize(missions[i].MissionTo)
+	}
+}
+
+func normalizeMissionPatchAirfields(missions []db.MissionPatchDTO) {
+	for i := range missions {
+		for _, field := range []*types.NullableString{&missions[i].MissionFrom, &missions[i].MissionTo} {
+			if field.Value != nil {
+				icao, _ := airfields.Normalize(*field.Value)
+				field.Value = &icao
+			}
+		}
+	}
+}
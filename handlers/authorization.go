@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"log"
+
+	"flight_log_service/db"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/thedanisaur/jfl_platform/auth"
+	"github.com/thedanisaur/jfl_platform/types"
+	"github.com/thedanisaur/jfl_platform/util"
+)
+
+// AuthorizationMiddleware loads the caller's policies for resource/operation
+// and evaluates them. Routes that address a single flight log or template are
+// checked against that row, and its owner must match :user_id. The evaluated
+// WHERE clause is left in Locals so list handlers can scope their queries.
+func AuthorizationMiddleware(config types.Config, resource string, operation string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		txid := c.Locals("transaction_id").(uuid.UUID)
+		log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(AuthorizationMiddleware))
+
+		/* Get the requesting user's info */
+		request_user := c.Locals("user_claims").(types.UserClaims)
+
+		/* Load Permissions */
+		policies, err := db.LoadPermissions(txid, request_user.RoleName, resource, operation)
+		if err != nil {
+			return c.Status(fiber.StatusForbidden).SendString(err.Error())
+		}
+		if len(policies) <= 0 {
+			return c.Status(fiber.StatusForbidden).SendString("not authorized")
+		}
+
+		/* Authorize */
+		scope := map[string]string{
+			"log":     "flight_logs",
+			"aircrew": "aircrews",
+		}
+		if c.Params("template_id") != "" || resource == "templates" {
+			scope = map[string]string{
+				"log":     "template_flight_logs",
+				"aircrew": "template_aircrews",
+			}
+		}
+		where_clause, arguments, err := auth.EvaluateRead(txid, resource, operation, scope, request_user, policies)
+		if err != nil {
+			return c.Status(fiber.StatusForbidden).SendString(err.Error())
+		}
+
+		/* Evaluate against the target row before the handler touches it */
+		if c.Params("template_id") != "" || c.Params("flight_log_id") != "" {
+			user_id, err := uuid.Parse(c.Params("user_id"))
+			if err != nil {
+				return c.Status(fiber.StatusServiceUnavailable).SendString("invalid user")
+			}
+			var allowed bool
+			if c.Params("template_id") != "" {
+				template_id, err := uuid.Parse(c.Params("template_id"))
+				if err != nil {
+					return c.Status(fiber.StatusServiceUnavailable).SendString("invalid template flight log")
+				}
+				allowed, err = db.AuthorizeTemplateFlightLog(txid, user_id, template_id, where_clause, arguments)
+				if err != nil {
+					return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+				}
+			} else {
+				flight_log_id, err := uuid.Parse(c.Params("flight_log_id"))
+				if err != nil {
+					return c.Status(fiber.StatusServiceUnavailable).SendString("invalid flight log")
+				}
+				allowed, err = db.AuthorizeFlightLog(txid, user_id, flight_log_id, where_clause, arguments)
+				if err != nil {
+					return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+				}
+			}
+			if !allowed {
+				return c.Status(fiber.StatusForbidden).SendString("not authorized")
+			}
+		}
+
+		c.Locals("authorization_where_clause", where_clause)
+		c.Locals("authorization_arguments", arguments)
+		return c.Next()
+	}
+}
+
+// authorizeCreate applies the create policy of resource for routes that make
+// a flight log or template but are routed through another resource, such as
+// instantiating a template or saving a flight log as a template. There is no
+// row yet, so only the policy evaluation can refuse.
+func authorizeCreate(txid uuid.UUID, request_user types.UserClaims, resource string) (bool, error) {
+	policies, err := db.LoadPermissions(txid, request_user.RoleName, resource, "create")
+	if err != nil {
+		return false, err
+	}
+	if len(policies) <= 0 {
+		return false, nil
+	}
+	scope := map[string]string{
+		"log":     "flight_logs",
+		"aircrew": "aircrews",
+	}
+	if resource == "templates" {
+		scope = map[string]string{
+			"log":     "template_flight_logs",
+			"aircrew": "template_aircrews",
+		}
+	}
+	_, _, err = auth.EvaluateRead(txid, resource, "create", scope, request_user, policies)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// authorizeTemplateEdit lets any write through on a personal template, which
+// the route's own policy has already admitted. A shared template also needs a
+// templates/share policy that admits the row, so only authorized roles can
+// change what other crews fly from.
+func authorizeTemplateEdit(txid uuid.UUID, request_user types.UserClaims, user_id uuid.UUID, template_id uuid.UUID) (bool, error) {
+	sharing, err := db.GetTemplateFlightLogSharing(txid, template_id)
+	if err != nil {
+		return false, err
+	}
+	if sharing.Scope == db.TemplateScopePersonal {
+		return true, nil
+	}
+	return authorizeTemplateShare(txid, request_user, user_id, template_id)
+}
+
+// authorizeTemplateShare applies the templates/share policy to the template
+// row, for writes that change sharing without going through the sharing
+// route, such as restoring a revision.
+func authorizeTemplateShare(txid uuid.UUID, request_user types.UserClaims, user_id uuid.UUID, template_id uuid.UUID) (bool, error) {
+	policies, err := db.LoadPermissions(txid, request_user.RoleName, "templates", "share")
+	if err != nil {
+		return false, err
+	}
+	if len(policies) <= 0 {
+		return false, nil
+	}
+	scope := map[string]string{
+		"log":     "template_flight_logs",
+		"aircrew": "template_aircrews",
+	}
+	where_clause, arguments, err := auth.EvaluateRead(txid, "templates", "share", scope, request_user, policies)
+	if err != nil {
+		return false, err
+	}
+	return db.AuthorizeTemplateFlightLog(txid, user_id, template_id, where_clause, arguments)
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"log"
+	"time"
+
+	"flight_log_service/db"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/thedanisaur/jfl_platform/types"
+	"github.com/thedanisaur/jfl_platform/util"
+)
+
+const (
+	CurrencyCurrent = "current"
+	CurrencyOverdue = "overdue"
+)
+
+type currencyStatus struct {
+	Event       string     `json:"event"`
+	Description string     `json:"description"`
+	Required    float64    `json:"required"`
+	WindowDays  int        `json:"window_days"`
+	Count       float64    `json:"count"`
+	Status      string     `json:"status"`
+	LastEventOn *time.Time `json:"last_event_on"`
+	ExpiresOn   *time.Time `json:"expires_on"`
+}
+
+type currencyOverdue struct {
+	Event       string                 `json:"event"`
+	Description string                 `json:"description"`
+	Required    float64                `json:"required"`
+	WindowDays  int                    `json:"window_days"`
+	Overdue     []db.OverdueCrewMember `json:"overdue"`
+}
+
+func GetCurrencyOverdue(config types.Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		txid := c.Locals("transaction_id").(uuid.UUID)
+		log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(GetCurrencyOverdue))
+
+		/* Scoped by AuthorizationMiddleware, which is what makes this unit wide */
+		where_clause := c.Locals("authorization_where_clause").(string)
+		arguments := c.Locals("authorization_arguments").([]interface{})
+
+		rules, ok := currencyRules(c.Query("event"))
+		if !ok {
+			return c.Status(fiber.StatusNotFound).SendString("unknown currency event")
+		}
+		today := currencyToday(time.Now().UTC())
+		events := make([]currencyOverdue, 0, len(rules))
+		for _, rule := range rules {
+			since := today.AddDate(0, 0, -rule.WindowDays)
+			overdue, err := db.GetOverdueCurrency(txid, where_clause, arguments, rule.Source, rule.When, since, rule.Required)
+			if err != nil {
+				return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+			}
+			events = append(events, currencyOverdue{
+				Event:       rule.Event,
+				Description: rule.Description,
+				Required:    rule.Required,
+				WindowDays:  rule.WindowDays,
+				Overdue:     overdue,
+			})
+		}
+
+		response := fiber.Map{
+			"txid":   txid.String(),
+			"as_of":  today,
+			"events": events,
+		}
+		return c.Status(fiber.StatusOK).JSON(response)
+	}
+}
+
+func GetFlightlogCurrency(config types.Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		txid := c.Locals("transaction_id").(uuid.UUID)
+		log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(GetFlightlogCurrency))
+
+		user_id, err := uuid.Parse(c.Params("user_id"))
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString("invalid user")
+		}
+
+		/* Scoped by AuthorizationMiddleware */
+		where_clause := c.Locals("authorization_where_clause").(string)
+		arguments := c.Locals("authorization_arguments").([]interface{})
+
+		rules, ok := currencyRules(c.Query("event"))
+		if !ok {
+			return c.Status(fiber.StatusNotFound).SendString("unknown currency event")
+		}
+		today := currencyToday(time.Now().UTC())
+		statuses := make([]currencyStatus, 0, len(rules))
+		for _, rule := range rules {
+			since := today.AddDate(0, 0, -rule.WindowDays)
+			events, err := db.GetCurrencyEvents(txid, user_id, where_clause, arguments, rule.Source, rule.When, since)
+			if err != nil {
+				return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+			}
+			statuses = append(statuses, evaluateCurrency(rule, events))
+		}
+
+		response := fiber.Map{
+			"txid":     txid.String(),
+			"user_id":  user_id,
+			"as_of":    today,
+			"currency": statuses,
+		}
+		return c.Status(fiber.StatusOK).JSON(response)
+	}
+}
+
+// currencyRules returns the configured rules, or just the one named event
+// when it is set.
+func currencyRules(event string) ([]CurrencyRule, bool) {
+	if event == "" {
+		return settings.Currency, true
+	}
+	for _, rule := range settings.Currency {
+		if rule.Event == event {
+			return []CurrencyRule{rule}, true
+		}
+	}
+	return nil, false
+}
+
+// currencyToday is the UTC day currency windows are measured back from. A
+// window of N days covers today and the N days before it.
+func currencyToday(now time.Time) time.Time {
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// evaluateCurrency walks events newest first until the rule's requirement is
+// met. The day that happens is the oldest event still needed, so the crew
+// member stays current until the window slides past it.
+func evaluateCurrency(rule CurrencyRule, events []db.CurrencyEvent) currencyStatus {
+	status := currencyStatus{
+		Event:       rule.Event,
+		Description: rule.Description,
+		Required:    rule.Required,
+		WindowDays:  rule.WindowDays,
+		Status:      CurrencyOverdue,
+	}
+	if len(events) > 0 {
+		last_event_on := events[0].Date
+		status.LastEventOn = &last_event_on
+	}
+	for _, event := range events {
+		status.Count += event.Count
+		if status.Status == CurrencyOverdue && status.Count >= rule.Required {
+			expires_on := event.Date.AddDate(0, 0, rule.WindowDays)
+			status.Status = CurrencyCurrent
+			status.ExpiresOn = &expires_on
+		}
+	}
+	return status
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"flight_log_service/db"
+)
+
+func TestEvaluateCurrency(t *testing.T) {
+	rule := CurrencyRule{Event: "landing", Required: 3, WindowDays: 45}
+	day := func(d int) time.Time { return time.Date(2024, 5, d, 0, 0, 0, 0, time.UTC) }
+	cases := []struct {
+		name       string
+		events     []db.CurrencyEvent
+		status     string
+		count      float64
+		expires_on time.Time
+	}{
+		{"no events", nil, CurrencyOverdue, 0, time.Time{}},
+		{"short", []db.CurrencyEvent{{Date: day(20), Count: 1}, {Date: day(10), Count: 1}}, CurrencyOverdue, 2, time.Time{}},
+		{"met on one day", []db.CurrencyEvent{{Date: day(20), Count: 3}}, CurrencyCurrent, 3, day(20).AddDate(0, 0, 45)},
+		{"met by the oldest needed", []db.CurrencyEvent{{Date: day(20), Count: 2}, {Date: day(10), Count: 1}, {Date: day(5), Count: 4}}, CurrencyCurrent, 7, day(10).AddDate(0, 0, 45)},
+	}
+	for _, test := range cases {
+		status := evaluateCurrency(rule, test.events)
+		if status.Status != test.status || status.Count != test.count {
+			t.Errorf("%s: status %s count %v, want %s count %v", test.name, status.Status, status.Count, test.status, test.count)
+		}
+		if test.expires_on.IsZero() != (status.ExpiresOn == nil) || (status.ExpiresOn != nil && !status.ExpiresOn.Equal(test.expires_on)) {
+			t.Errorf("%s: expires_on = %v, want %s", test.name, status.ExpiresOn, test.expires_on)
+		}
+		if len(test.events) > 0 && (status.LastEventOn == nil || !status.LastEventOn.Equal(test.events[0].Date)) {
+			t.Errorf("%s: last_event_on = %v, want %s", test.name, status.LastEventOn, test.events[0].Date)
+		}
+	}
+}
+
+func TestCurrencyToday(t *testing.T) {
+	now := time.Date(2024, 5, 1, 23, 59, 0, 0, time.UTC)
+	if got := currencyToday(now); !got.Equal(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("currencyToday = %s", got)
+	}
+}
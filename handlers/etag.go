@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Flight logs and templates carry a row version that is surfaced as a strong
+// ETag. Clients send it back in If-Match to guard writes and in If-None-Match
+// to revalidate cached reads.
+
+func formatETag(version int) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// etagListContains matches version against an ETag list. If-None-Match uses
+// weak comparison, so weak allows a W/ tag to match; If-Match must compare
+// strongly, so a W/ tag never matches there.
+func etagListContains(header string, version int, weak bool) bool {
+	etag := formatETag(version)
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatchFailed reports whether the request carried an If-Match header that
+// does not match the stored version. Requests without If-Match are allowed.
+func ifMatchFailed(c *fiber.Ctx, version int) bool {
+	header := c.Get(fiber.HeaderIfMatch)
+	if header == "" {
+		return false
+	}
+	return !etagListContains(header, version, false)
+}
+
+func ifNoneMatchHit(c *fiber.Ctx, version int) bool {
+	header := c.Get(fiber.HeaderIfNoneMatch)
+	if header == "" {
+		return false
+	}
+	return etagListContains(header, version, true)
+}
@@ -0,0 +1,26 @@
+package handlers
+
+import "testing"
+
+func TestEtagListContains(t *testing.T) {
+	cases := []struct {
+		header string
+		weak   bool
+		match  bool
+	}{
+		{`"3"`, false, true},
+		{`"3"`, true, true},
+		{`"2", "3"`, false, true},
+		{`"2"`, false, false},
+		{`*`, false, true},
+		{`W/"3"`, false, false},
+		{`W/"3"`, true, true},
+		{`"1", W/"3"`, true, true},
+		{`3`, true, false},
+	}
+	for _, test := range cases {
+		if got := etagListContains(test.header, 3, test.weak); got != test.match {
+			t.Errorf("etagListContains(%s, 3, weak %v) = %v, want %v", test.header, test.weak, got, test.match)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"flight_log_service/db"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/thedanisaur/jfl_platform/types"
+	"github.com/thedanisaur/jfl_platform/util"
+)
+
+const (
+	ExportLayoutFlightLog = "flight_log"
+	ExportLayoutMission   = "mission"
+	ExportLayoutAircrew   = "aircrew"
+)
+
+type flightLogColumn struct {
+	name  string
+	value func(flight_log types.FlightLogDTO) interface{}
+}
+
+type missionColumn struct {
+	name  string
+	value func(mission types.FlightLogMissionDTO) interface{}
+}
+
+type aircrewColumn struct {
+	name  string
+	value func(aircrew types.FlightLogAircrewDTO) interface{}
+}
+
+// Column names follow the SQL. Child ids are renamed where they would clash
+// with the flight log columns every layout starts with.
+var flight_log_export_columns = []flightLogColumn{
+	{"flight_log_id", func(f types.FlightLogDTO) interface{} { return f.ID }},
+	{"user_id", func(f types.FlightLogDTO) interface{} { return f.UserID }},
+	{"mds", func(f types.FlightLogDTO) interface{} { return f.MDS }},
+	{"flight_log_date", func(f types.FlightLogDTO) interface{} { return f.FlightLogDate }},
+	{"serial_number", func(f types.FlightLogDTO) interface{} { return f.SerialNumber }},
+	{"unit_charged", func(f types.FlightLogDTO) interface{} { return f.UnitCharged }},
+	{"harm_location", func(f types.FlightLogDTO) interface{} { return f.HarmLocation }},
+	{"flight_authorization", func(f types.FlightLogDTO) interface{} { return f.FlightAuthorization }},
+	{"issuing_unit", func(f types.FlightLogDTO) interface{} { return f.IssuingUnit }},
+	{"is_training_flight", func(f types.FlightLogDTO) interface{} { return f.IsTrainingFlight }},
+	{"is_training_only", func(f types.FlightLogDTO) interface{} { return f.IsTrainingOnly }},
+	{"total_flight_decimal_time", func(f types.FlightLogDTO) interface{} { return f.TotalFlightDecimalTime }},
+	{"scheduler_signature_id", func(f types.FlightLogDTO) interface{} { return f.SchedulerSignatureID }},
+	{"sarm_signature_id", func(f types.FlightLogDTO) interface{} { return f.SarmSignatureID }},
+	{"instructor_signature_id", func(f types.FlightLogDTO) interface{} { return f.InstructorSignatureID }},
+	{"student_signature_id", func(f types.FlightLogDTO) interface{} { return f.StudentSignatureID }},
+	{"training_officer_signature_id", func(f types.FlightLogDTO) interface{} { return f.TrainingOfficerSignatureID }},
+	{"type", func(f types.FlightLogDTO) interface{} { return f.Type }},
+	{"remarks", func(f types.FlightLogDTO) interface{} { return f.Remarks }},
+}
+
+var mission_export_columns = []missionColumn{
+	{"mission_id", func(m types.FlightLogMissionDTO) interface{} { return m.ID }},
+	{"mission_number", func(m types.FlightLogMissionDTO) interface{} { return m.MissionNumber }},
+	{"mission_symbol", func(m types.FlightLogMissionDTO) interface{} { return m.MissionSymbol }},
+	{"mission_from", func(m types.FlightLogMissionDTO) interface{} { return m.MissionFrom }},
+	{"mission_to", func(m types.FlightLogMissionDTO) interface{} { return m.MissionTo }},
+	{"takeoff_time", func(m types.FlightLogMissionDTO) interface{} { return m.TakeoffTime }},
+	{"land_time", func(m types.FlightLogMissionDTO) interface{} { return m.LandTime }},
+	{"total_time_decimal", func(m types.FlightLogMissionDTO) interface{} { return m.TotalTimeDecimal }},
+	{"total_time_display", func(m types.FlightLogMissionDTO) interface{} { return m.TotalTimeDisplay }},
+	{"touch_and_gos", func(m types.FlightLogMissionDTO) interface{} { return m.TouchAndGos }},
+	{"full_stops", func(m types.FlightLogMissionDTO) interface{} { return m.FullStops }},
+	{"total_landings", func(m types.FlightLogMissionDTO) interface{} { return m.TotalLandings }},
+	{"sorties", func(m types.FlightLogMissionDTO) interface{} { return m.Sorties }},
+}
+
+var aircrew_export_columns = []aircrewColumn{
+	{"aircrew_id", func(a types.FlightLogAircrewDTO) interface{} { return a.ID }},
+	{"aircrew_user_id", func(a types.FlightLogAircrewDTO) interface{} { return a.UserID }},
+	{"flying_origin", func(a types.FlightLogAircrewDTO) interface{} { return a.FlyingOrigin }},
+	{"flight_auth_code", func(a types.FlightLogAircrewDTO) interface{} { return a.FlightAuthCode }},
+	{"time_primary", func(a types.FlightLogAircrewDTO) interface{} { return a.TimePrimary }},
+	{"time_secondary", func(a types.FlightLogAircrewDTO) interface{} { return a.TimeSecondary }},
+	{"time_instructor", func(a types.FlightLogAircrewDTO) interface{} { return a.TimeInstructor }},
+	{"time_evaluator", func(a types.FlightLogAircrewDTO) interface{} { return a.TimeEvaluator }},
+	{"time_other", func(a types.FlightLogAircrewDTO) interface{} { return a.TimeOther }},
+	{"total_aircrew_duration_decimal", func(a types.FlightLogAircrewDTO) interface{} { return a.TotalAircrewDurationDecimal }},
+	{"total_aircrew_sorties", func(a types.FlightLogAircrewDTO) interface{} { return a.TotalAircrewSorties }},
+	{"cond_night_time", func(a types.FlightLogAircrewDTO) interface{} { return a.CondNightTime }},
+	{"cond_instrument_time", func(a types.FlightLogAircrewDTO) interface{} { return a.CondInstrumentTime }},
+	{"cond_sim_instrument_time", func(a types.FlightLogAircrewDTO) interface{} { return a.CondSimInstrumentTime }},
+	{"cond_nvg_time", func(a types.FlightLogAircrewDTO) interface{} { return a.CondNvgTime }},
+	{"cond_combat_time", func(a types.FlightLogAircrewDTO) interface{} { return a.CondCombatTime }},
+	{"cond_combat_sortie", func(a types.FlightLogAircrewDTO) interface{} { return a.CondCombatSortie }},
+	{"cond_combat_support_time", func(a types.FlightLogAircrewDTO) interface{} { return a.CondCombatSupportTime }},
+	{"cond_combat_support_sortie", func(a types.FlightLogAircrewDTO) interface{} { return a.CondCombatSupportSortie }},
+	{"aircrew_role_type", func(a types.FlightLogAircrewDTO) interface{} { return a.AircrewRoleType }},
+}
+
+// csv_guarded_prefixes are the leading characters csvValue guards with a
+// quote and importValue unguards.
+const csv_guarded_prefixes = "=+-@\t\r'"
+
+// flightLogPager fetches one page of the export's scope.
+type flightLogPager func(query db.FlightLogQuery) (db.FlightLogPage, error)
+
+func ExportFlightlogs(config types.Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		txid := c.Locals("transaction_id").(uuid.UUID)
+		log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(ExportFlightlogs))
+
+		user_id, err := uuid.Parse(c.Params("user_id"))
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString("invalid user")
+		}
+
+		/* Scoped by AuthorizationMiddleware, copied because the stream outlives c */
+		where_clause := c.Locals("authorization_where_clause").(string)
+		arguments := append([]interface{}{}, c.Locals("authorization_arguments").([]interface{})...)
+
+		return streamFlightLogExport(c, txid, func(query db.FlightLogQuery) (db.FlightLogPage, error) {
+			return db.GetFlightlogs(txid, user_id, where_clause, arguments, query)
+		})
+	}
+}
+
+func ExportFlightlogsAll(config types.Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		txid := c.Locals("transaction_id").(uuid.UUID)
+		log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(ExportFlightlogsAll))
+
+		request_user := c.Locals("user_claims").(types.UserClaims)
+
+		/* Scoped by AuthorizationMiddleware, copied because the stream outlives c */
+		where_clause := c.Locals("authorization_where_clause").(string)
+		arguments := append([]interface{}{}, c.Locals("authorization_arguments").([]interface{})...)
+
+		return streamFlightLogExport(c, txid, func(query db.FlightLogQuery) (db.FlightLogPage, error) {
+			return db.GetFlightlogsAll(txid, request_user.UserID, where_clause, arguments, query)
+		})
+	}
+}
+
+// csvValue renders a column value. Text that a spreadsheet would run as a
+// formula is prefixed with a quote so a remark cannot execute on open. Text
+// that already starts with a quote gets one too, so importValue can always
+// strip exactly one.
+func csvValue(value interface{}) string {
+	switch typed := value.(type) {
+	case string:
+		if typed != "" && strings.ContainsRune(csv_guarded_prefixes, rune(typed[0])) {
+			return "'" + typed
+		}
+		return typed
+	case float64:
+		return strconv.FormatFloat(typed, 'f', -1, 64)
+	case time.Time:
+		if typed.IsZero() {
+			return ""
+		}
+		return typed.UTC().Format(time.RFC3339)
+	case uuid.UUID:
+		if typed == uuid.Nil {
+			return ""
+		}
+		return typed.String()
+	default:
+		return fmt.Sprint(typed)
+	}
+}
+
+func exportHeader(layout string) []string {
+	names := []string{}
+	for _, column := range flight_log_export_columns {
+		names = append(names, column.name)
+	}
+	switch layout {
+	case ExportLayoutMission:
+		for _, column := range mission_export_columns {
+			names = append(names, column.name)
+		}
+	case ExportLayoutAircrew:
+		for _, column := range aircrew_export_columns {
+			names = append(names, column.name)
+		}
+	}
+	return names
+}
+
+// exportRows flattens one flight log into the rows of layout, each starting
+// with the flight log columns. A log with no children still gets one row so
+// it does not vanish from the export.
+func exportRows(flight_log types.FlightLogDTO, layout string) [][]string {
+	header := make([]string, 0, len(flight_log_export_columns))
+	for _, column := range flight_log_export_columns {
+		header = append(header, csvValue(column.value(flight_log)))
+	}
+	rows := [][]string{}
+	switch layout {
+	case ExportLayoutMission:
+		for _, mission := range flight_log.Missions {
+			row := append([]string{}, header...)
+			for _, column := range mission_export_columns {
+				row = append(row, csvValue(column.value(mission)))
+			}
+			rows = append(rows, row)
+		}
+		if len(rows) == 0 {
+			rows = append(rows, append(header, make([]string, len(mission_export_columns))...))
+		}
+	case ExportLayoutAircrew:
+		for _, aircrew := range flight_log.Aircrew {
+			row := append([]string{}, header...)
+			for _, column := range aircrew_export_columns {
+				row = append(row, csvValue(column.value(aircrew)))
+			}
+			rows = append(rows, row)
+		}
+		if len(rows) == 0 {
+			rows = append(rows, append(header, make([]string, len(aircrew_export_columns))...))
+		}
+	default:
+		rows = append(rows, header)
+	}
+	return rows
+}
+
+// streamFlightLogExport takes the same filters, sort and cursor as the list
+// endpoints and writes every matching flight log as CSV, a page at a time.
+// The first page is read before anything is sent so a bad query or database
+// error still gets a proper status; later failures end the stream with a
+// marker row.
+func streamFlightLogExport(c *fiber.Ctx, txid uuid.UUID, pager flightLogPager) error {
+	layout := c.Query("layout", ExportLayoutFlightLog)
+	switch layout {
+	case ExportLayoutFlightLog, ExportLayoutMission, ExportLayoutAircrew:
+	default:
+		return c.Status(fiber.StatusBadRequest).SendString("layout must be flight_log, mission or aircrew")
+	}
+	query, err := parseFlightLogQuery(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+	query.Limit = db.MaxFlightLogLimit
+	include := map[string]bool{
+		"missions": layout == ExportLayoutMission,
+		"aircrew":  layout == ExportLayoutAircrew,
+	}
+
+	page, err := pager(query)
+	if err != nil {
+		return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+	}
+	err = loadFlightLogChildren(txid, page.FlightLogs, include)
+	if err != nil {
+		return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+	}
+
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="flight-logs-%s.csv"`, layout))
+	c.Status(fiber.StatusOK).Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		writer := csv.NewWriter(w)
+		defer writer.Flush()
+		writer.Write(exportHeader(layout))
+		for {
+			for _, flight_log := range page.FlightLogs {
+				writer.WriteAll(exportRows(flight_log, layout))
+			}
+			if writer.Error() != nil {
+				log.Printf("%s | Failed to write flight log export\n%s\n", txid.String(), writer.Error().Error())
+				return
+			}
+			if page.NextCursor == "" {
+				return
+			}
+			query.After, err = db.DecodeFlightLogCursor(page.NextCursor, query.Sort)
+			if err == nil {
+				page, err = pager(query)
+			}
+			if err == nil {
+				err = loadFlightLogChildren(txid, page.FlightLogs, include)
+			}
+			if err != nil {
+				/* The status is already sent, so mark the file as incomplete; the marker row also makes an import reject it */
+				log.Printf("%s | Flight log export stopped early\n%s\n", txid.String(), err.Error())
+				writer.Write([]string{fmt.Sprintf("# export incomplete, stopped by an error: %s", txid.String())})
+				return
+			}
+		}
+	})
+	return nil
+}
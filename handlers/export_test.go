@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCsvValue(t *testing.T) {
+	id := uuid.New()
+	cases := []struct {
+		value interface{}
+		want  string
+	}{
+		{"plain", "plain"},
+		{"", ""},
+		{"=SUM(A1)", "'=SUM(A1)"},
+		{"+1", "'+1"},
+		{"-2", "'-2"},
+		{"@x", "'@x"},
+		{"\tx", "'\tx"},
+		{"\rx", "'\rx"},
+		{"'quoted", "''quoted"},
+		{"a=b", "a=b"},
+		{1.5, "1.5"},
+		{time.Time{}, ""},
+		{time.Date(2024, 5, 1, 14, 0, 0, 0, time.UTC), "2024-05-01T14:00:00Z"},
+		{uuid.Nil, ""},
+		{id, id.String()},
+		{true, "true"},
+		{3, "3"},
+	}
+	for _, test := range cases {
+		if got := csvValue(test.value); got != test.want {
+			t.Errorf("csvValue(%#v) = %q, want %q", test.value, got, test.want)
+		}
+	}
+}
+
+func TestCsvValueImportRoundTrip(t *testing.T) {
+	for _, value := range []string{"=SUM(A1)", "+1", "-2", "@x", "\tx", "\rx", "'quoted", "''twice", "plain", "a=b"} {
+		if got := importValue(csvValue(value)); got != value {
+			t.Errorf("round trip of %q = %q", value, got)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/thedanisaur/jfl_platform/types"
+)
+
+// deriveFlightTimes fills in every computed time on a flight log so clients
+// no longer have to agree on rounding:
+//   - mission total_time_decimal and total_time_display from takeoff/land
+//   - mission total_landings from touch_and_gos + full_stops
+//   - total_flight_decimal_time as the sum of the mission decimals
+//   - each crew member's total_aircrew_sorties as the sum of mission sorties
+//
+// Missions without a usable takeoff/land pair keep what was sent so the
+// validator can report them. Header totals are only derived when there are
+// missions to derive them from.
+func deriveFlightTimes(flight_log *types.FlightLogDTO) {
+	if len(flight_log.Missions) == 0 {
+		return
+	}
+	flight_total := 0.0
+	for i := range flight_log.Missions {
+		mission := &flight_log.Missions[i]
+		mission.TotalLandings = mission.TouchAndGos + mission.FullStops
+		if !mission.TakeoffTime.IsZero() && mission.LandTime.After(mission.TakeoffTime) {
+			minutes := int(math.Round(mission.LandTime.Sub(mission.TakeoffTime).Minutes()))
+			mission.TotalTimeDecimal = decimalHours(minutes)
+			mission.TotalTimeDisplay = displayHours(minutes)
+		}
+		flight_total += mission.TotalTimeDecimal
+	}
+	flight_log.TotalFlightDecimalTime = math.Round(flight_total*100) / 100
+	sorties := missionSorties(flight_log.Missions)
+	for i := range flight_log.Aircrew {
+		flight_log.Aircrew[i].TotalAircrewSorties = sorties
+	}
+}
+
+// decimalHours converts whole minutes to decimal hours under the configured
+// rounding rule. Tenths follow the AFI 11-401 conversion table, applied to the
+// minutes past the hour; its buckets are not all six minutes wide (27-33 is
+// .5), so it is looked up rather than computed. Minutes keeps the exact value
+// to two places.
+func decimalHours(minutes int) float64 {
+	if settings.FlightTimes.Rounding == RoundingMinutes {
+		return math.Round(float64(minutes)/60*100) / 100
+	}
+	tenth := len(tenth_upper_minutes)
+	for i, upper := range tenth_upper_minutes {
+		if minutes%60 <= upper {
+			tenth = i
+			break
+		}
+	}
+	return float64(minutes/60*10+tenth) / 10
+}
+
+// tenth_upper_minutes is the last minute past the hour logged as each tenth,
+// from .0 to .9; 58 and 59 minutes round up to the next whole hour.
+var tenth_upper_minutes = [...]int{2, 8, 14, 20, 26, 33, 39, 45, 51, 57}
+
+// displayHours renders whole minutes as HH:MM.
+func displayHours(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+func missionSorties(missions []types.FlightLogMissionDTO) int {
+	sorties := 0
+	for _, mission := range missions {
+		sorties += mission.Sorties
+	}
+	return sorties
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import "testing"
+
+func TestDecimalHoursTenths(t *testing.T) {
+	rounding := settings.FlightTimes.Rounding
+	defer func() { settings.FlightTimes.Rounding = rounding }()
+	settings.FlightTimes.Rounding = RoundingTenths
+	cases := []struct {
+		minutes int
+		hours   float64
+	}{
+		{0, 0},
+		{2, 0},
+		{3, 0.1},
+		{26, 0.4},
+		{27, 0.5},
+		{33, 0.5},
+		{34, 0.6},
+		{39, 0.6},
+		{45, 0.7},
+		{51, 0.8},
+		{57, 0.9},
+		{58, 1.0},
+		{60, 1.0},
+		{93, 1.5},
+	}
+	for _, test := range cases {
+		got := decimalHours(test.minutes)
+		if got != test.hours {
+			t.Errorf("decimalHours(%d) = %v, want %v", test.minutes, got, test.hours)
+		}
+	}
+}
+
+func TestDecimalHoursMinutes(t *testing.T) {
+	rounding := settings.FlightTimes.Rounding
+	defer func() { settings.FlightTimes.Rounding = rounding }()
+	settings.FlightTimes.Rounding = RoundingMinutes
+	cases := []struct {
+		minutes int
+		hours   float64
+	}{
+		{0, 0},
+		{1, 0.02},
+		{30, 0.5},
+		{58, 0.97},
+		{93, 1.55},
+	}
+	for _, test := range cases {
+		got := decimalHours(test.minutes)
+		if got != test.hours {
+			t.Errorf("decimalHours(%d) = %v, want %v", test.minutes, got, test.hours)
+		}
+	}
+}
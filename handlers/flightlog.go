@@ -1,18 +1,30 @@
 package handlers
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"strconv"
+	"strings"
+	"time"
 
 	"flight_log_service/db"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
-	"github.com/thedanisaur/jfl_platform/auth"
 	"github.com/thedanisaur/jfl_platform/types"
 	"github.com/thedanisaur/jfl_platform/util"
 )
 
+// createdFlightLog is the ids handed back for a newly inserted flight log.
+type createdFlightLog struct {
+	FlightLogID uuid.UUID   `json:"flight_log_id"`
+	MissionIDs  []uuid.UUID `json:"mission_ids"`
+	AircrewIDs  []uuid.UUID `json:"aircrew_ids"`
+	CommentIDs  []uuid.UUID `json:"comment_ids"`
+}
+
 func CreateFlightlog(config types.Config) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		txid := c.Locals("transaction_id").(uuid.UUID)
@@ -24,32 +36,24 @@ func CreateFlightlog(config types.Config) fiber.Handler {
 			log.Printf("Failed to parse flight log data\n%s\n", err.Error())
 			return c.Status(fiber.StatusBadRequest).SendString(fmt.Sprintf("Failed to parse flight log data: %s\n", txid.String()))
 		}
+		field_errors := prepareFlightLog(&flight_log)
+		if len(field_errors) > 0 {
+			return validationFailed(c, txid, field_errors)
+		}
 		/* Get the requesting user */
 		request_user := c.Locals("user_claims").(types.UserClaims)
 
-		/* Now start inserting the flight log */
-		flight_log.ID, err = db.InsertFlightLog(txid, request_user.UserID, flight_log)
-		if err != nil {
-			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
-		}
-		mission_ids, err := db.InsertMissions(txid, flight_log)
-		if err != nil {
-			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
-		}
-		aircrew_ids, err := db.InsertAircrews(txid, flight_log)
-		if err != nil {
-			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
-		}
-		comment_ids, err := db.InsertFlightLogComments(txid, request_user.UserID, flight_log)
+		created, err := insertNewFlightLog(txid, request_user.UserID, &flight_log)
 		if err != nil {
 			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
 		}
 		response := fiber.Map{
-			"txid":          txid.String(),
-			"flight_log_id": flight_log.ID.String(),
-			"mission_ids":   mission_ids,
-			"aircrew_ids":   aircrew_ids,
-			"comment_ids":   comment_ids,
+			"txid":           txid.String(),
+			"flight_log_id":  flight_log.ID.String(),
+			"mission_ids":    created.MissionIDs,
+			"aircrew_ids":    created.AircrewIDs,
+			"comment_ids":    created.CommentIDs,
+			"reconciliation": reconcileFlightLog(flight_log, db.FlightLogStatusDraft),
 		}
 		return c.Status(fiber.StatusOK).JSON(response)
 	}
@@ -69,7 +73,25 @@ func DeleteFlightlog(config types.Config) fiber.Handler {
 			return c.Status(fiber.StatusServiceUnavailable).SendString("invalid flight log")
 		}
 
-		_, err = db.DeleteFlightlog(txid, user_id, flight_log_id)
+		transaction, err := db.BeginTransaction(txid)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		defer transaction.Rollback()
+
+		version, err := db.LockFlightLogVersion(txid, transaction, flight_log_id)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		if ifMatchFailed(c, version) {
+			return c.Status(fiber.StatusPreconditionFailed).SendString("flight log has been modified")
+		}
+
+		_, err = db.DeleteFlightlog(txid, transaction, user_id, flight_log_id)
+		if err != nil {
+			return c.Status(flightLogWriteStatus(err)).SendString(err.Error())
+		}
+		err = db.CommitTransaction(txid, transaction)
 		if err != nil {
 			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
 		}
@@ -96,6 +118,15 @@ func GetFlightlog(config types.Config) fiber.Handler {
 			return c.Status(fiber.StatusServiceUnavailable).SendString("invalid flight log")
 		}
 
+		version, err := db.GetFlightLogVersion(txid, user_id, flight_log_id)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		c.Set(fiber.HeaderETag, formatETag(version))
+		if ifNoneMatchHit(c, version) {
+			return c.SendStatus(fiber.StatusNotModified)
+		}
+
 		flight_log, err := db.GetFlightlog(txid, user_id, flight_log_id)
 		if err != nil {
 			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
@@ -116,11 +147,17 @@ func GetFlightlog(config types.Config) fiber.Handler {
 			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
 		}
 
+		read_model := newFlightLogReadModel(flight_log)
+		read_model.SourceTemplate, err = db.GetFlightLogSourceTemplate(txid, flight_log_id)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+
 		// response := fiber.Map{
 		// 	"txid": txid.String(),
 		// }
 
-		return c.Status(fiber.StatusOK).JSON(flight_log)
+		return c.Status(fiber.StatusOK).JSON(read_model)
 	}
 }
 
@@ -134,33 +171,37 @@ func GetFlightlogs(config types.Config) fiber.Handler {
 			return c.Status(fiber.StatusServiceUnavailable).SendString("invalid user")
 		}
 
-		flight_logs, err := db.GetFlightlogs(txid, user_id)
+		/* Scoped by AuthorizationMiddleware */
+		where_clause := c.Locals("authorization_where_clause").(string)
+		arguments := c.Locals("authorization_arguments").([]interface{})
+
+		query, err := parseFlightLogQuery(c)
 		if err != nil {
-			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+			return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+		}
+		include, err := parseInclude(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString(err.Error())
 		}
 
-		for index := range flight_logs {
-			flight_logs[index].Missions, err = db.GetMissions(txid, flight_logs[index].ID)
-			if err != nil {
-				return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
-			}
-
-			flight_logs[index].Aircrew, err = db.GetAirCrews(txid, flight_logs[index].ID)
-			if err != nil {
-				return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
-			}
-
-			flight_logs[index].Comments, err = db.GetFlightLogComments(txid, flight_logs[index].ID)
-			if err != nil {
-				return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
-			}
+		page, err := db.GetFlightlogs(txid, user_id, where_clause, arguments, query)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
 		}
+		flight_logs := page.FlightLogs
 
-		// response := fiber.Map{
-		// 	"txid": txid.String(),
-		// }
+		err = loadFlightLogChildren(txid, flight_logs, include)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
 
-		return c.Status(fiber.StatusOK).JSON(flight_logs)
+		response := fiber.Map{
+			"txid":        txid.String(),
+			"flight_logs": newFlightLogReadModels(flight_logs),
+			"next_cursor": page.NextCursor,
+			"total_count": page.TotalCount,
+		}
+		return c.Status(fiber.StatusOK).JSON(response)
 	}
 }
 
@@ -171,57 +212,138 @@ func GetFlightlogsAll(config types.Config) fiber.Handler {
 
 		/* Get the requesting user's info */
 		request_user := c.Locals("user_claims").(types.UserClaims)
-		resource := "flight-logs"
-		operation := "read"
 
-		/* Load Permissions */
-		policies, err := db.LoadPermissions(txid, request_user.RoleName, resource, operation)
+		/* Scoped by AuthorizationMiddleware */
+		where_clause := c.Locals("authorization_where_clause").(string)
+		arguments := c.Locals("authorization_arguments").([]interface{})
+
+		query, err := parseFlightLogQuery(c)
 		if err != nil {
-			return c.Status(fiber.StatusUnauthorized).SendString(err.Error())
+			return c.Status(fiber.StatusBadRequest).SendString(err.Error())
 		}
-		if len(policies) <= 0 {
-			return c.Status(fiber.StatusUnauthorized).SendString("not authorized")
+		include, err := parseInclude(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString(err.Error())
 		}
 
-		/* Authorize */
-		scope := map[string]string{
-			"log":     "flight_logs",
-			"aircrew": "aircrews",
-		}
-		where_clause, arguments, err := auth.EvaluateRead(txid, resource, operation, scope, request_user, policies)
+		/* Get the flight logs */
+		page, err := db.GetFlightlogsAll(txid, request_user.UserID, where_clause, arguments, query)
 		if err != nil {
-			return c.Status(fiber.StatusUnauthorized).SendString(err.Error())
+			return c.Status(fiber.StatusNotFound).SendString(err.Error())
 		}
+		flight_logs := page.FlightLogs
 
-		/* Get the flight logs */
-		flight_logs, err := db.GetFlightlogsAll(txid, request_user.UserID, where_clause, arguments)
+		err = loadFlightLogChildren(txid, flight_logs, include)
 		if err != nil {
 			return c.Status(fiber.StatusNotFound).SendString(err.Error())
 		}
 
-		for index := range flight_logs {
-			flight_logs[index].Missions, err = db.GetMissions(txid, flight_logs[index].ID)
-			if err != nil {
-				return c.Status(fiber.StatusNotFound).SendString(err.Error())
-			}
+		response := fiber.Map{
+			"txid":        txid.String(),
+			"flight_logs": newFlightLogReadModels(flight_logs),
+			"next_cursor": page.NextCursor,
+			"total_count": page.TotalCount,
+		}
+		return c.Status(fiber.StatusOK).JSON(response)
+	}
+}
 
-			flight_logs[index].Aircrew, err = db.GetAirCrews(txid, flight_logs[index].ID)
-			if err != nil {
-				return c.Status(fiber.StatusNotFound).SendString(err.Error())
-			}
+func PatchFlightlog(config types.Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		txid := c.Locals("transaction_id").(uuid.UUID)
+		log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(PatchFlightlog))
 
-			flight_logs[index].Comments, err = db.GetFlightLogComments(txid, flight_logs[index].ID)
-			if err != nil {
-				return c.Status(fiber.StatusNotFound).SendString(err.Error())
-			}
+		user_id, err := uuid.Parse(c.Params("user_id"))
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString("invalid user")
+		}
+		flight_log_id, err := uuid.Parse(c.Params("flight_log_id"))
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString("invalid flight log")
 		}
 
-		// TODO [drd] include txid in the response
-		// response := fiber.Map{
-		// 	"txid": txid.String(),
-		// }
+		var patch db.FlightLogPatchDTO
+		err = c.BodyParser(&patch)
+		if err != nil {
+			log.Printf("Failed to parse flight log patch\n%s\n", err.Error())
+			return c.Status(fiber.StatusBadRequest).SendString(fmt.Sprintf("Failed to parse flight log patch: %s\n", txid.String()))
+		}
+		normalizeMissionPatchAirfields(patch.Missions)
+		field_errors := validateFlightLogPatch(patch)
+		if len(field_errors) > 0 {
+			return validationFailed(c, txid, field_errors)
+		}
+
+		/* Only the columns present in the patch are written */
+		transaction, err := db.BeginTransaction(txid)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		defer transaction.Rollback()
+
+		version, err := db.LockFlightLogVersion(txid, transaction, flight_log_id)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		if ifMatchFailed(c, version) {
+			return c.Status(fiber.StatusPreconditionFailed).SendString("flight log has been modified")
+		}
 
-		return c.Status(fiber.StatusOK).JSON(flight_logs)
+		_, err = db.PatchFlightLog(txid, transaction, user_id, flight_log_id, patch)
+		if err != nil {
+			return c.Status(flightLogWriteStatus(err)).SendString(err.Error())
+		}
+		mission_ids, err := db.PatchMissions(txid, transaction, flight_log_id, patch)
+		if err != nil {
+			return childWriteFailed(c, txid, err)
+		}
+		aircrew_ids, err := db.PatchAircrews(txid, transaction, flight_log_id, patch)
+		if err != nil {
+			return childWriteFailed(c, txid, err)
+		}
+		/* Re-derive computed times from the patched missions */
+		missions, err := db.LockMissions(txid, transaction, flight_log_id)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		derived := types.FlightLogDTO{ID: flight_log_id, Missions: missions}
+		deriveFlightTimes(&derived)
+		err = db.UpdateDerivedTimes(txid, transaction, derived, missionSorties(missions))
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		/* Any edit invalidates every signature taken against the old content */
+		invalidated, err := db.InvalidateFlightLogSignatures(txid, transaction, flight_log_id, signature_roles)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		version, err = db.IncrementFlightLogVersion(txid, transaction, flight_log_id)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		err = db.CommitTransaction(txid, transaction)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		c.Set(fiber.HeaderETag, formatETag(version))
+
+		/* The merged log only exists once committed, reconcile it from there */
+		discrepancies := []Discrepancy{}
+		flight_log, err := loadFlightLog(txid, user_id, flight_log_id)
+		if err != nil {
+			log.Printf("Failed to reconcile flight log: %s\n%s\n", flight_log_id, err.Error())
+		} else {
+			discrepancies = reconcileFlightLog(flight_log, db.FlightLogStatusDraft)
+		}
+		response := fiber.Map{
+			"txid":                   txid.String(),
+			"flight_log_id":          flight_log_id,
+			"mission_ids":            mission_ids,
+			"aircrew_ids":            aircrew_ids,
+			"invalidated_signatures": invalidated,
+			"reconciliation":         discrepancies,
+		}
+		return c.Status(fiber.StatusOK).JSON(response)
 	}
 }
 
@@ -230,35 +352,278 @@ func UpdateFlightlog(config types.Config) fiber.Handler {
 		txid := c.Locals("transaction_id").(uuid.UUID)
 		log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(UpdateFlightlog))
 
+		flight_log_id, err := uuid.Parse(c.Params("flight_log_id"))
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString("invalid flight log")
+		}
+
 		var flight_log types.FlightLogDTO
-		err := c.BodyParser(&flight_log)
+		err = c.BodyParser(&flight_log)
 		if err != nil {
 			log.Printf("Failed to parse flight log data\n%s\n", err.Error())
 			return c.Status(fiber.StatusBadRequest).SendString(fmt.Sprintf("Failed to parse flight log data: %s\n", txid.String()))
 		}
-		// TODO [drd] validate that this action is allowed.
-		/* Get the requesting user */
-		// request_user := c.Locals("user_claims").(types.UserClaims)
+		normalizeMissionAirfields(flight_log.Missions)
+		deriveFlightTimes(&flight_log)
+		field_errors := validateFlightLog(flight_log)
+		if len(field_errors) > 0 {
+			return validationFailed(c, txid, field_errors)
+		}
+		/* Now update the flight log, everything commits or nothing does */
+		transaction, err := db.BeginTransaction(txid)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		defer transaction.Rollback()
 
-		/* Now update the flight log */
-		flight_log_id, err := db.UpdateFlightLog(txid, flight_log)
+		version, err := db.LockFlightLogVersion(txid, transaction, flight_log_id)
 		if err != nil {
 			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
 		}
-		mission_ids, err := db.UpdateMissions(txid, flight_log)
+		if ifMatchFailed(c, version) {
+			return c.Status(fiber.StatusPreconditionFailed).SendString("flight log has been modified")
+		}
+
+		flight_log.ID = flight_log_id
+		_, err = db.UpdateFlightLog(txid, transaction, flight_log)
+		if err != nil {
+			return c.Status(flightLogWriteStatus(err)).SendString(err.Error())
+		}
+		mission_changes, err := db.UpdateMissions(txid, transaction, flight_log)
+		if err != nil {
+			return childWriteFailed(c, txid, err)
+		}
+		aircrew_changes, err := db.UpdateAircrews(txid, transaction, flight_log)
+		if err != nil {
+			return childWriteFailed(c, txid, err)
+		}
+		/* Any edit invalidates every signature taken against the old content */
+		invalidated, err := db.InvalidateFlightLogSignatures(txid, transaction, flight_log_id, signature_roles)
 		if err != nil {
 			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
 		}
-		aircrew_ids, err := db.UpdateAircrews(txid, flight_log)
+		version, err = db.IncrementFlightLogVersion(txid, transaction, flight_log_id)
 		if err != nil {
 			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
 		}
+		err = db.CommitTransaction(txid, transaction)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		c.Set(fiber.HeaderETag, formatETag(version))
 		response := fiber.Map{
-			"txid":          txid.String(),
-			"flight_log_id": flight_log_id,
-			"mission_ids":   mission_ids,
-			"aircrew_ids":   aircrew_ids,
+			"txid":                   txid.String(),
+			"flight_log_id":          flight_log_id,
+			"missions":               mission_changes,
+			"aircrew":                aircrew_changes,
+			"invalidated_signatures": invalidated,
+			"reconciliation":         reconcileFlightLog(flight_log, db.FlightLogStatusDraft),
 		}
 		return c.Status(fiber.StatusOK).JSON(response)
 	}
 }
+
+// parseFlightLogQuery reads limit, cursor, sort and the filter parameters
+// shared by the flight log list endpoints.
+func parseFlightLogQuery(c *fiber.Ctx) (db.FlightLogQuery, error) {
+	query := db.FlightLogQuery{
+		Limit:        db.DefaultFlightLogLimit,
+		MDS:          c.Query("mds"),
+		SerialNumber: c.Query("serial_number"),
+		UnitCharged:  c.Query("unit_charged"),
+		Type:         c.Query("type"),
+	}
+	if c.Query("limit") != "" {
+		limit, err := strconv.Atoi(c.Query("limit"))
+		if err != nil || limit <= 0 || limit > db.MaxFlightLogLimit {
+			return db.FlightLogQuery{}, fmt.Errorf("limit must be between 1 and %d", db.MaxFlightLogLimit)
+		}
+		query.Limit = limit
+	}
+	if c.Query("date_from") != "" {
+		date_from, err := parseQueryDate(c.Query("date_from"))
+		if err != nil {
+			return db.FlightLogQuery{}, errors.New("invalid date_from")
+		}
+		query.DateFrom = &date_from
+	}
+	if c.Query("date_to") != "" {
+		date_to, err := parseQueryDate(c.Query("date_to"))
+		if err != nil {
+			return db.FlightLogQuery{}, errors.New("invalid date_to")
+		}
+		/* A bare date includes the whole day */
+		if len(c.Query("date_to")) == len("2006-01-02") {
+			date_to = date_to.AddDate(0, 0, 1).Add(-time.Second)
+		}
+		query.DateTo = &date_to
+	}
+	if c.Query("is_training_flight") != "" {
+		is_training_flight, err := strconv.ParseBool(c.Query("is_training_flight"))
+		if err != nil {
+			return db.FlightLogQuery{}, errors.New("invalid is_training_flight")
+		}
+		query.IsTrainingFlight = &is_training_flight
+	}
+	var err error
+	query.Sort, err = db.ParseFlightLogSort(c.Query("sort"))
+	if err != nil {
+		return db.FlightLogQuery{}, err
+	}
+	query.After, err = db.DecodeFlightLogCursor(c.Query("cursor"), query.Sort)
+	if err != nil {
+		return db.FlightLogQuery{}, err
+	}
+	return query, nil
+}
+
+// prepareFlightLog is what every new flight log goes through before it is
+// written: airfields normalized, computed times derived, then validated.
+func prepareFlightLog(flight_log *types.FlightLogDTO) []FieldError {
+	normalizeMissionAirfields(flight_log.Missions)
+	deriveFlightTimes(flight_log)
+	return validateFlightLog(*flight_log)
+}
+
+// parseQueryDate accepts a full RFC 3339 timestamp or a bare YYYY-MM-DD date.
+func parseQueryDate(value string) (time.Time, error) {
+	date, err := time.Parse(time.RFC3339, value)
+	if err == nil {
+		return date, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// loadFlightLog reads a flight log with its missions and aircrew, the content
+// that signatures and reconciliation work from.
+func loadFlightLog(txid uuid.UUID, user_id uuid.UUID, flight_log_id uuid.UUID) (types.FlightLogDTO, error) {
+	flight_log, err := db.GetFlightlog(txid, user_id, flight_log_id)
+	if err != nil {
+		return types.FlightLogDTO{}, err
+	}
+	flight_log.Missions, err = db.GetMissions(txid, flight_log_id)
+	if err != nil {
+		return types.FlightLogDTO{}, err
+	}
+	flight_log.Aircrew, err = db.GetAirCrews(txid, flight_log_id)
+	if err != nil {
+		return types.FlightLogDTO{}, err
+	}
+	return flight_log, nil
+}
+
+// insertFlightLogGraph writes a prepared flight log and its children as
+// user_id inside the caller's transaction. The new id is set on flight_log.
+func insertFlightLogGraph(txid uuid.UUID, transaction *sql.Tx, user_id uuid.UUID, flight_log *types.FlightLogDTO) (createdFlightLog, error) {
+	var err error
+	flight_log.ID, err = db.InsertFlightLog(txid, transaction, user_id, *flight_log)
+	if err != nil {
+		return createdFlightLog{}, err
+	}
+	created := createdFlightLog{FlightLogID: flight_log.ID}
+	created.MissionIDs, err = db.InsertMissions(txid, transaction, *flight_log)
+	if err != nil {
+		return createdFlightLog{}, err
+	}
+	created.AircrewIDs, err = db.InsertAircrews(txid, transaction, *flight_log)
+	if err != nil {
+		return createdFlightLog{}, err
+	}
+	created.CommentIDs, err = db.InsertFlightLogComments(txid, transaction, user_id, *flight_log)
+	if err != nil {
+		return createdFlightLog{}, err
+	}
+	return created, nil
+}
+
+// insertNewFlightLog writes a prepared flight log and its children as
+// user_id in one transaction; everything commits or nothing does. The new id
+// is set on flight_log.
+func insertNewFlightLog(txid uuid.UUID, user_id uuid.UUID, flight_log *types.FlightLogDTO) (createdFlightLog, error) {
+	transaction, err := db.BeginTransaction(txid)
+	if err != nil {
+		return createdFlightLog{}, err
+	}
+	defer transaction.Rollback()
+
+	created, err := insertFlightLogGraph(txid, transaction, user_id, flight_log)
+	if err != nil {
+		return createdFlightLog{}, err
+	}
+	err = db.CommitTransaction(txid, transaction)
+	if err != nil {
+		return createdFlightLog{}, err
+	}
+	return created, nil
+}
+
+// loadFlightLogChildren fills in the requested child collections for a page of
+// flight logs with one query per collection rather than one per log.
+func loadFlightLogChildren(txid uuid.UUID, flight_logs []types.FlightLogDTO, include map[string]bool) error {
+	flight_log_ids := make([]uuid.UUID, 0, len(flight_logs))
+	for _, flight_log := range flight_logs {
+		flight_log_ids = append(flight_log_ids, flight_log.ID)
+	}
+	if include["missions"] {
+		missions, err := db.GetMissionsForFlightLogs(txid, flight_log_ids)
+		if err != nil {
+			return err
+		}
+		for index := range flight_logs {
+			flight_logs[index].Missions = missions[flight_logs[index].ID]
+			if flight_logs[index].Missions == nil {
+				flight_logs[index].Missions = make([]types.FlightLogMissionDTO, 0)
+			}
+		}
+	}
+	if include["aircrew"] {
+		aircrews, err := db.GetAirCrewsForFlightLogs(txid, flight_log_ids)
+		if err != nil {
+			return err
+		}
+		for index := range flight_logs {
+			flight_logs[index].Aircrew = aircrews[flight_logs[index].ID]
+			if flight_logs[index].Aircrew == nil {
+				flight_logs[index].Aircrew = make([]types.FlightLogAircrewDTO, 0)
+			}
+		}
+	}
+	if include["comments"] {
+		comments, err := db.GetFlightLogCommentsForFlightLogs(txid, flight_log_ids)
+		if err != nil {
+			return err
+		}
+		for index := range flight_logs {
+			flight_logs[index].Comments = comments[flight_logs[index].ID]
+			if flight_logs[index].Comments == nil {
+				flight_logs[index].Comments = make([]types.FlightLogCommentDTO, 0)
+			}
+		}
+	}
+	return nil
+}
+
+// parseInclude reads include=missions,aircrew,comments. Without the parameter
+// every child collection is loaded, include= (empty) loads none.
+func parseInclude(c *fiber.Ctx) (map[string]bool, error) {
+	include := map[string]bool{
+		"missions": true,
+		"aircrew":  true,
+		"comments": true,
+	}
+	if c.Context().QueryArgs().Has("include") {
+		requested := map[string]bool{}
+		for _, name := range strings.Split(c.Query("include"), ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			if !include[name] {
+				return nil, fmt.Errorf("invalid include: %s", name)
+			}
+			requested[name] = true
+		}
+		include = requested
+	}
+	return include, nil
+}
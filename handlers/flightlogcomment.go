@@ -22,8 +22,7 @@ func CreateFlightlogComment(config types.Config) fiber.Handler {
 			log.Printf("Failed to parse flight log data\n%s\n", err.Error())
 			return c.Status(fiber.StatusBadRequest).SendString(fmt.Sprintf("Failed to parse flight log data: %s\n", txid.String()))
 		}
-		/* Get the target user/flight log info */
-		// TODO [drd] should we make sure we're commenting on a flight log owned by the target user or is any uuid a valid address?
+		/* Get the target flight log info, ownership was checked by AuthorizationMiddleware */
 		flight_log_id, err := uuid.Parse(c.Params("flight_log_id"))
 		if err != nil {
 			log.Printf("Failed to parse flight log id: %s\n", c.Params("flight_log_id"))
@@ -37,7 +36,17 @@ func CreateFlightlogComment(config types.Config) fiber.Handler {
 		request_user := c.Locals("user_claims").(types.UserClaims)
 
 		/* Now start inserting the flight log comment */
-		comment_id, err := db.InsertFlightLogComment(txid, request_user.UserID, flight_log_comment)
+		transaction, err := db.BeginTransaction(txid)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		defer transaction.Rollback()
+
+		comment_id, err := db.InsertFlightLogComment(txid, transaction, request_user.UserID, flight_log_comment)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		err = db.CommitTransaction(txid, transaction)
 		if err != nil {
 			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
 		}
@@ -0,0 +1,305 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"flight_log_service/db"
+	"flight_log_service/pdf"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/thedanisaur/jfl_platform/types"
+	"github.com/thedanisaur/jfl_platform/util"
+)
+
+// The form is drawn landscape on letter paper. Every page repeats the
+// complete layout, so a log with more legs, crew lines or remarks than one
+// sheet holds runs onto continuation pages the way the paper form does.
+const (
+	form_margin          = 24.0
+	form_width           = pdf.LetterHeight - 2*form_margin
+	form_row_height      = 13.0
+	form_mission_rows    = 6
+	form_aircrew_rows    = 12
+	form_remarks_lines   = 5
+	form_remarks_leading = 10.0
+)
+
+type formColumn struct {
+	label string
+	width float64
+}
+
+var form_mission_columns = []formColumn{
+	{"MISSION NUMBER", 70}, {"MISSION SYMBOL", 60}, {"FROM (ICAO)", 60}, {"TO (ICAO)", 60},
+	{"TAKEOFF TIME (Z)", 95}, {"LAND TIME (Z)", 95}, {"TOTAL TIME", 60}, {"TOUCH / GO", 60},
+	{"FULL STOP", 60}, {"TOTAL LANDINGS", 62}, {"SORTIES", 62},
+}
+
+var form_aircrew_columns = []formColumn{
+	{"FLYING ORGN", 40}, {"FLT AUTH DUTY CODE", 40}, {"CREW MEMBER", 150},
+	{"PRIMARY", 31}, {"SECOND- ARY", 31}, {"INSTR", 31}, {"EVAL", 31}, {"OTHER", 31},
+	{"TOTAL TIME", 31}, {"TOTAL SRTY", 31}, {"NIGHT", 31}, {"INS", 31}, {"SIM INS", 31},
+	{"NVG", 31}, {"COMBAT TIME", 31}, {"COMBAT SRTY", 31}, {"CMBT SPT TIME", 31},
+	{"CMBT SPT SRTY", 31}, {"RESV STATUS", 49},
+}
+
+var form_signature_labels = map[string]string{
+	"scheduler":        "SCHEDULER",
+	"instructor":       "INSTRUCTOR",
+	"student":          "STUDENT",
+	"training_officer": "TRAINING OFFICER",
+	"sarm":             "SARM",
+}
+
+func GetFlightlogForm(config types.Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		txid := c.Locals("transaction_id").(uuid.UUID)
+		log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(GetFlightlogForm))
+
+		user_id, err := uuid.Parse(c.Params("user_id"))
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString("invalid user")
+		}
+		flight_log_id, err := uuid.Parse(c.Params("flight_log_id"))
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString("invalid flight log")
+		}
+
+		version, err := db.GetFlightLogVersion(txid, user_id, flight_log_id)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		c.Set(fiber.HeaderETag, formatETag(version))
+		if ifNoneMatchHit(c, version) {
+			return c.SendStatus(fiber.StatusNotModified)
+		}
+
+		flight_log, err := loadFlightLog(txid, user_id, flight_log_id)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		status, err := db.GetFlightLogStatus(txid, user_id, flight_log_id)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		signatures, err := db.GetFlightLogSignatures(txid, flight_log_id)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+
+		document, err := renderFlightLogForm(flight_log, status, signatures, time.Now().UTC())
+		if err != nil {
+			log.Printf("%s | Failed to render flight log form\n%s\n", txid.String(), err.Error())
+			return c.Status(fiber.StatusInternalServerError).SendString("failed to render flight log form")
+		}
+
+		c.Set(fiber.HeaderContentType, "application/pdf")
+		c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`inline; filename="afto-781-%s.pdf"`, flight_log_id))
+		return c.Status(fiber.StatusOK).Send(document)
+	}
+}
+
+// activeSignatures keeps the newest signature still in force for each role.
+func activeSignatures(signatures []db.FlightLogSignatureDTO) map[string]db.FlightLogSignatureDTO {
+	active := map[string]db.FlightLogSignatureDTO{}
+	for _, signature := range signatures {
+		if signature.InvalidatedOn != nil {
+			continue
+		}
+		current, ok := active[signature.Role]
+		if !ok || signature.SignedOn.After(current.SignedOn) {
+			active[signature.Role] = signature
+		}
+	}
+	return active
+}
+
+// drawFormField is a labelled box: a small caption on top, the value below.
+func drawFormField(document *pdf.Document, x float64, y float64, width float64, height float64, label string, value string) {
+	document.Rect(x, y, width, height, 0.5)
+	document.Text(x+2, y+7, 5.5, true, pdf.Fit(label, 5.5, true, width-4))
+	document.Text(x+3, y+height-5, 9, false, pdf.Fit(value, 9, false, width-6))
+}
+
+// drawFormSection is the shaded bar that opens each part of the form.
+func drawFormSection(document *pdf.Document, y float64, title string) float64 {
+	document.FillRect(form_margin, y, form_width, 12, 0.85)
+	document.Rect(form_margin, y, form_width, 12, 0.75)
+	document.Text(form_margin+4, y+9, 8, true, title)
+	return y + 12
+}
+
+// drawFormTable draws a ruled grid of row_count rows under a header of
+// wrapped column captions, filling in as many rows as it is given.
+func drawFormTable(document *pdf.Document, y float64, columns []formColumn, header_height float64, rows [][]string, row_count int) float64 {
+	x := form_margin
+	for _, column := range columns {
+		document.Rect(x, y, column.width, header_height, 0.5)
+		for i, line := range pdf.Wrap(column.label, 5.5, true, column.width-3) {
+			if float64(i+1)*6.5 > header_height-2 {
+				break
+			}
+			document.Text(x+1.5, y+7+float64(i)*6.5, 5.5, true, line)
+		}
+		x += column.width
+	}
+	y += header_height
+	for row := 0; row < row_count; row++ {
+		x = form_margin
+		for i, column := range columns {
+			document.Rect(x, y, column.width, form_row_height, 0.5)
+			if row < len(rows) {
+				document.Text(x+2, y+form_row_height-3.5, 7, false, pdf.Fit(rows[row][i], 7, false, column.width-4))
+			}
+			x += column.width
+		}
+		y += form_row_height
+	}
+	return y
+}
+
+// formNumber leaves zero blank, as it would be on the paper form.
+func formNumber(value float64) string {
+	if value == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func formTime(value time.Time) string {
+	if value.IsZero() {
+		return ""
+	}
+	return value.UTC().Format("2006-01-02 15:04Z")
+}
+
+// pageBounds is the [start, end) range of count items that falls on page
+// when each page holds per_page.
+func pageBounds(count int, page int, per_page int) (int, int) {
+	start := min(page*per_page, count)
+	return start, min(start+per_page, count)
+}
+
+// renderFlightLogForm lays the flight log out as an AFTO Form 781 and returns
+// the PDF bytes.
+func renderFlightLogForm(flight_log types.FlightLogDTO, status string, signatures []db.FlightLogSignatureDTO, generated_on time.Time) ([]byte, error) {
+	missions := make([][]string, 0, len(flight_log.Missions))
+	for _, mission := range flight_log.Missions {
+		missions = append(missions, []string{
+			mission.MissionNumber,
+			mission.MissionSymbol,
+			mission.MissionFrom,
+			mission.MissionTo,
+			formTime(mission.TakeoffTime),
+			formTime(mission.LandTime),
+			formNumber(mission.TotalTimeDecimal),
+			formNumber(float64(mission.TouchAndGos)),
+			formNumber(float64(mission.FullStops)),
+			formNumber(float64(mission.TotalLandings)),
+			formNumber(float64(mission.Sorties)),
+		})
+	}
+	aircrew := make([][]string, 0, len(flight_log.Aircrew))
+	for _, member := range flight_log.Aircrew {
+		aircrew = append(aircrew, []string{
+			member.FlyingOrigin,
+			member.FlightAuthCode,
+			member.UserID.String(),
+			formNumber(member.TimePrimary),
+			formNumber(member.TimeSecondary),
+			formNumber(member.TimeInstructor),
+			formNumber(member.TimeEvaluator),
+			formNumber(member.TimeOther),
+			formNumber(member.TotalAircrewDurationDecimal),
+			formNumber(float64(member.TotalAircrewSorties)),
+			formNumber(member.CondNightTime),
+			formNumber(member.CondInstrumentTime),
+			formNumber(member.CondSimInstrumentTime),
+			formNumber(member.CondNvgTime),
+			formNumber(member.CondCombatTime),
+			formNumber(float64(member.CondCombatSortie)),
+			formNumber(member.CondCombatSupportTime),
+			formNumber(float64(member.CondCombatSupportSortie)),
+			member.AircrewRoleType,
+		})
+	}
+	remarks := []string{}
+	if strings.TrimSpace(flight_log.Remarks) != "" {
+		remarks = pdf.Wrap(flight_log.Remarks, 8, false, form_width-8)
+	}
+	pages := max(1,
+		(len(missions)+form_mission_rows-1)/form_mission_rows,
+		(len(aircrew)+form_aircrew_rows-1)/form_aircrew_rows,
+		(len(remarks)+form_remarks_lines-1)/form_remarks_lines,
+	)
+	active := activeSignatures(signatures)
+	header := []struct {
+		label string
+		value string
+	}{
+		{"DATE", flight_log.FlightLogDate.UTC().Format("02 Jan 2006")},
+		{"MDS", flight_log.MDS},
+		{"SERIAL NUMBER", flight_log.SerialNumber},
+		{"UNIT CHARGED FOR FLYING HOURS", flight_log.UnitCharged},
+		{"HARM LOCATION", flight_log.HarmLocation},
+		{"FLIGHT AUTHORIZATION", flight_log.FlightAuthorization},
+		{"ISSUING UNIT", flight_log.IssuingUnit},
+		{"TOTAL FLIGHT TIME", formNumber(flight_log.TotalFlightDecimalTime)},
+	}
+
+	document := pdf.New("AFTO Form 781 "+flight_log.ID.String(), pdf.LetterHeight, pdf.LetterWidth)
+	for page := 0; page < pages; page++ {
+		document.AddPage()
+		document.Text(form_margin, form_margin+10, 12, true, "AFTO FORM 781, ARMS AIRCREW/MISSION FLIGHT DATA DOCUMENT")
+		page_label := fmt.Sprintf("PAGE %d OF %d", page+1, pages)
+		document.Text(form_margin+form_width-pdf.TextWidth(page_label, 8, true), form_margin+8, 8, true, page_label)
+		status_label := "STATUS: " + strings.ToUpper(status)
+		document.Text(form_margin+form_width-pdf.TextWidth(status_label, 8, true), form_margin+18, 8, true, status_label)
+
+		y := drawFormSection(document, 48, "I. MISSION DATA")
+		field_width := form_width / float64(len(header))
+		for i, field := range header {
+			drawFormField(document, form_margin+float64(i)*field_width, y, field_width, 24, field.label, field.value)
+		}
+		start, end := pageBounds(len(missions), page, form_mission_rows)
+		y = drawFormTable(document, y+24, form_mission_columns, 18, missions[start:end], form_mission_rows)
+
+		y = drawFormSection(document, y+6, "II. AIRCREW DATA")
+		start, end = pageBounds(len(aircrew), page, form_aircrew_rows)
+		y = drawFormTable(document, y, form_aircrew_columns, 24, aircrew[start:end], form_aircrew_rows)
+
+		y = drawFormSection(document, y+6, "III. REMARKS")
+		document.Rect(form_margin, y, form_width, form_remarks_lines*form_remarks_leading+10, 0.5)
+		start, end = pageBounds(len(remarks), page, form_remarks_lines)
+		for i, line := range remarks[start:end] {
+			document.Text(form_margin+4, y+12+float64(i)*form_remarks_leading, 8, false, line)
+		}
+		y += form_remarks_lines*form_remarks_leading + 10
+
+		y = drawFormSection(document, y+6, "IV. SIGNATURES")
+		block_width := form_width / float64(len(signature_roles))
+		for i, role := range signature_roles {
+			x := form_margin + float64(i)*block_width
+			document.Rect(x, y, block_width, 50, 0.5)
+			document.Text(x+3, y+8, 5.5, true, form_signature_labels[role])
+			signature, ok := active[role]
+			if !ok {
+				document.Text(x+3, y+24, 8, false, "NOT SIGNED")
+				continue
+			}
+			document.Text(x+3, y+20, 7, false, pdf.Fit("SIGNED BY "+signature.UserID.String(), 7, false, block_width-6))
+			document.Text(x+3, y+30, 7, false, pdf.Fit("ROLE "+signature.RoleName, 7, false, block_width-6))
+			document.Text(x+3, y+40, 7, false, "SIGNED "+formTime(signature.SignedOn))
+		}
+		y += 50
+
+		footer := fmt.Sprintf("Flight log %s | generated %s", flight_log.ID, formTime(generated_on))
+		document.Text(form_margin, y+14, 6, false, footer)
+	}
+	return document.Bytes()
+}
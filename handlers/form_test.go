@@ -0,0 +1,27 @@
+package handlers
+
+import "testing"
+
+func TestPageBounds(t *testing.T) {
+	cases := []struct {
+		count    int
+		page     int
+		per_page int
+		start    int
+		end      int
+	}{
+		{0, 0, 10, 0, 0},
+		{5, 0, 10, 0, 5},
+		{10, 0, 10, 0, 10},
+		{10, 1, 10, 10, 10},
+		{25, 1, 10, 10, 20},
+		{25, 2, 10, 20, 25},
+		{25, 3, 10, 25, 25},
+	}
+	for _, test := range cases {
+		start, end := pageBounds(test.count, test.page, test.per_page)
+		if start != test.start || end != test.end {
+			t.Errorf("pageBounds(%d, %d, %d) = %d, %d, want %d, %d", test.count, test.page, test.per_page, start, end, test.start, test.end)
+		}
+	}
+}
@@ -0,0 +1,457 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/thedanisaur/jfl_platform/types"
+	"github.com/thedanisaur/jfl_platform/util"
+)
+
+// Import error codes, alongside the validation codes.
+const (
+	importInvalidValue = "invalid_value"
+	importConflict     = "conflict"
+	importDatabase     = "database_error"
+)
+
+// import_log_key is the column rows are grouped into flight logs by. A file
+// without one, such as an export, is grouped by import_export_key instead.
+const (
+	import_log_key    = "log_key"
+	import_export_key = "flight_log_id"
+)
+
+const max_import_rows = 10000
+
+// Import columns use the export names, so an export file (ids and all) can be
+// read back in. Ids and signatures are ignored: every log is created new, and
+// an export's flight_log_id only groups its rows.
+var flight_log_import_fields = map[string]func(flight_log *types.FlightLogDTO, value string) error{
+	"mds":                  func(f *types.FlightLogDTO, v string) error { f.MDS = v; return nil },
+	"serial_number":        func(f *types.FlightLogDTO, v string) error { f.SerialNumber = v; return nil },
+	"unit_charged":         func(f *types.FlightLogDTO, v string) error { f.UnitCharged = v; return nil },
+	"harm_location":        func(f *types.FlightLogDTO, v string) error { f.HarmLocation = v; return nil },
+	"flight_authorization": func(f *types.FlightLogDTO, v string) error { f.FlightAuthorization = v; return nil },
+	"issuing_unit":         func(f *types.FlightLogDTO, v string) error { f.IssuingUnit = v; return nil },
+	"type":                 func(f *types.FlightLogDTO, v string) error { f.Type = v; return nil },
+	"remarks":              func(f *types.FlightLogDTO, v string) error { f.Remarks = v; return nil },
+	"flight_log_date": func(f *types.FlightLogDTO, v string) (err error) {
+		f.FlightLogDate, err = parseImportTime(v)
+		return err
+	},
+	"is_training_flight": func(f *types.FlightLogDTO, v string) (err error) {
+		f.IsTrainingFlight, err = strconv.ParseBool(v)
+		return err
+	},
+	"is_training_only": func(f *types.FlightLogDTO, v string) (err error) {
+		f.IsTrainingOnly, err = strconv.ParseBool(v)
+		return err
+	},
+	"total_flight_decimal_time": func(f *types.FlightLogDTO, v string) (err error) {
+		f.TotalFlightDecimalTime, err = strconv.ParseFloat(v, 64)
+		return err
+	},
+}
+
+var mission_import_fields = map[string]func(mission *types.FlightLogMissionDTO, value string) error{
+	"mission_number":     func(m *types.FlightLogMissionDTO, v string) error { m.MissionNumber = v; return nil },
+	"mission_symbol":     func(m *types.FlightLogMissionDTO, v string) error { m.MissionSymbol = v; return nil },
+	"mission_from":       func(m *types.FlightLogMissionDTO, v string) error { m.MissionFrom = v; return nil },
+	"mission_to":         func(m *types.FlightLogMissionDTO, v string) error { m.MissionTo = v; return nil },
+	"total_time_display": func(m *types.FlightLogMissionDTO, v string) error { m.TotalTimeDisplay = v; return nil },
+	"takeoff_time": func(m *types.FlightLogMissionDTO, v string) (err error) {
+		m.TakeoffTime, err = parseImportTime(v)
+		return err
+	},
+	"land_time": func(m *types.FlightLogMissionDTO, v string) (err error) {
+		m.LandTime, err = parseImportTime(v)
+		return err
+	},
+	"total_time_decimal": func(m *types.FlightLogMissionDTO, v string) (err error) {
+		m.TotalTimeDecimal, err = strconv.ParseFloat(v, 64)
+		return err
+	},
+	"touch_and_gos": func(m *types.FlightLogMissionDTO, v string) (err error) {
+		m.TouchAndGos, err = strconv.Atoi(v)
+		return err
+	},
+	"full_stops": func(m *types.FlightLogMissionDTO, v string) (err error) {
+		m.FullStops, err = strconv.Atoi(v)
+		return err
+	},
+	"total_landings": func(m *types.FlightLogMissionDTO, v string) (err error) {
+		m.TotalLandings, err = strconv.Atoi(v)
+		return err
+	},
+	"sorties": func(m *types.FlightLogMissionDTO, v string) (err error) {
+		m.Sorties, err = strconv.Atoi(v)
+		return err
+	},
+}
+
+var aircrew_import_fields = map[string]func(aircrew *types.FlightLogAircrewDTO, value string) error{
+	"flying_origin":     func(a *types.FlightLogAircrewDTO, v string) error { a.FlyingOrigin = v; return nil },
+	"flight_auth_code":  func(a *types.FlightLogAircrewDTO, v string) error { a.FlightAuthCode = v; return nil },
+	"aircrew_role_type": func(a *types.FlightLogAircrewDTO, v string) error { a.AircrewRoleType = v; return nil },
+	"aircrew_user_id": func(a *types.FlightLogAircrewDTO, v string) (err error) {
+		a.UserID, err = uuid.Parse(v)
+		return err
+	},
+	"time_primary":                   importFloat(func(a *types.FlightLogAircrewDTO) *float64 { return &a.TimePrimary }),
+	"time_secondary":                 importFloat(func(a *types.FlightLogAircrewDTO) *float64 { return &a.TimeSecondary }),
+	"time_instructor":                importFloat(func(a *types.FlightLogAircrewDTO) *float64 { return &a.TimeInstructor }),
+	"time_evaluator":                 importFloat(func(a *types.FlightLogAircrewDTO) *float64 { return &a.TimeEvaluator }),
+	"time_other":                     importFloat(func(a *types.FlightLogAircrewDTO) *float64 { return &a.TimeOther }),
+	"total_aircrew_duration_decimal": importFloat(func(a *types.FlightLogAircrewDTO) *float64 { return &a.TotalAircrewDurationDecimal }),
+	"cond_night_time":                importFloat(func(a *types.FlightLogAircrewDTO) *float64 { return &a.CondNightTime }),
+	"cond_instrument_time":           importFloat(func(a *types.FlightLogAircrewDTO) *float64 { return &a.CondInstrumentTime }),
+	"cond_sim_instrument_time":       importFloat(func(a *types.FlightLogAircrewDTO) *float64 { return &a.CondSimInstrumentTime }),
+	"cond_nvg_time":                  importFloat(func(a *types.FlightLogAircrewDTO) *float64 { return &a.CondNvgTime }),
+	"cond_combat_time":               importFloat(func(a *types.FlightLogAircrewDTO) *float64 { return &a.CondCombatTime }),
+	"cond_combat_support_time":       importFloat(func(a *types.FlightLogAircrewDTO) *float64 { return &a.CondCombatSupportTime }),
+	"total_aircrew_sorties":          importInt(func(a *types.FlightLogAircrewDTO) *int { return &a.TotalAircrewSorties }),
+	"cond_combat_sortie":             importInt(func(a *types.FlightLogAircrewDTO) *int { return &a.CondCombatSortie }),
+	"cond_combat_support_sortie":     importInt(func(a *types.FlightLogAircrewDTO) *int { return &a.CondCombatSupportSortie }),
+}
+
+var ignored_import_columns = map[string]bool{
+	"flight_log_id":                 true,
+	"user_id":                       true,
+	"scheduler_signature_id":        true,
+	"sarm_signature_id":             true,
+	"instructor_signature_id":       true,
+	"student_signature_id":          true,
+	"training_officer_signature_id": true,
+	"mission_id":                    true,
+	"aircrew_id":                    true,
+}
+
+var import_path_index = regexp.MustCompile(`^(missions|aircrew)\[(\d+)\]`)
+
+// ImportRowError ties a problem to the CSV line it came from (the header is
+// line 1).
+type ImportRowError struct {
+	Row    int    `json:"row"`
+	LogKey string `json:"log_key"`
+	FieldError
+}
+
+type importRow struct {
+	line   int
+	values map[string]string
+}
+
+// importGroup is every row sharing a key, and the flight log built from them.
+// key_column names the column the key came from. mission_rows and
+// aircrew_rows give the CSV line each child came from.
+type importGroup struct {
+	key          string
+	key_column   string
+	rows         []importRow
+	flight_log   types.FlightLogDTO
+	mission_rows []int
+	aircrew_rows []int
+	errors       []ImportRowError
+}
+
+type importedFlightLog struct {
+	LogKey string `json:"log_key"`
+	Rows   []int  `json:"rows"`
+	createdFlightLog
+}
+
+func ImportFlightlogs(config types.Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		txid := c.Locals("transaction_id").(uuid.UUID)
+		log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(ImportFlightlogs))
+
+		dry_run := false
+		if c.Query("dry_run") != "" {
+			parsed, err := strconv.ParseBool(c.Query("dry_run"))
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).SendString("invalid dry_run")
+			}
+			dry_run = parsed
+		}
+
+		body, err := importBody(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+		}
+		groups, row_count, err := parseImport(body)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+		}
+
+		/* Get the requesting user, imported logs belong to them as on create */
+		request_user := c.Locals("user_claims").(types.UserClaims)
+
+		created := []importedFlightLog{}
+		valid := []importedFlightLog{}
+		rejected := []ImportRowError{}
+		for _, group := range groups {
+			field_errors := prepareFlightLog(&group.flight_log)
+			for _, field_error := range field_errors {
+				group.errors = append(group.errors, ImportRowError{Row: group.rowFor(field_error.Path), LogKey: group.key, FieldError: field_error})
+			}
+			if len(group.errors) > 0 {
+				rejected = append(rejected, group.errors...)
+				continue
+			}
+			imported := importedFlightLog{LogKey: group.key, Rows: group.lines()}
+			if dry_run {
+				valid = append(valid, imported)
+				continue
+			}
+
+			/* One transaction per log so one bad log cannot sink the rest */
+			imported.createdFlightLog, err = insertNewFlightLog(txid, request_user.UserID, &group.flight_log)
+			if err != nil {
+				log.Printf("%s | Failed to import flight log %s\n%s\n", txid.String(), group.key, err.Error())
+				rejected = append(rejected, ImportRowError{
+					Row:        group.rows[0].line,
+					LogKey:     group.key,
+					FieldError: FieldError{Path: group.key_column, Code: importDatabase, Message: err.Error()},
+				})
+				continue
+			}
+			created = append(created, imported)
+		}
+
+		response := fiber.Map{
+			"txid":          txid.String(),
+			"dry_run":       dry_run,
+			"rows":          row_count,
+			"flight_logs":   len(groups),
+			"created":       created,
+			"rejected_rows": rejected,
+		}
+		if dry_run {
+			response["valid"] = valid
+		}
+		return c.Status(fiber.StatusOK).JSON(response)
+	}
+}
+
+// importBody takes the CSV from a multipart "file" field, or the raw body.
+func importBody(c *fiber.Ctx) ([]byte, error) {
+	if !strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEMultipartForm) {
+		return c.Body(), nil
+	}
+	header, err := c.FormFile("file")
+	if err != nil {
+		return nil, errors.New("multipart import needs a file field")
+	}
+	file, err := header.Open()
+	if err != nil {
+		return nil, errors.New("could not read import file")
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+func importFloat(field func(aircrew *types.FlightLogAircrewDTO) *float64) func(*types.FlightLogAircrewDTO, string) error {
+	return func(aircrew *types.FlightLogAircrewDTO, value string) (err error) {
+		*field(aircrew), err = strconv.ParseFloat(value, 64)
+		return err
+	}
+}
+
+func importInt(field func(aircrew *types.FlightLogAircrewDTO) *int) func(*types.FlightLogAircrewDTO, string) error {
+	return func(aircrew *types.FlightLogAircrewDTO, value string) (err error) {
+		*field(aircrew), err = strconv.Atoi(value)
+		return err
+	}
+}
+
+// importValue undoes the export's formula guard and trims whitespace.
+func importValue(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) > 1 && value[0] == '\'' && strings.ContainsRune(csv_guarded_prefixes, rune(value[1])) {
+		return value[1:]
+	}
+	return value
+}
+
+func (group *importGroup) lines() []int {
+	lines := make([]int, 0, len(group.rows))
+	for _, row := range group.rows {
+		lines = append(lines, row.line)
+	}
+	return lines
+}
+
+// parseImport reads the CSV into flight logs, one per log_key (or, failing
+// that, flight_log_id) in order of first appearance. Each row may carry a mission, an aircrew entry, both or
+// neither; the flight log columns are taken from the first row of the group
+// and any later row that disagrees is rejected. Unknown columns fail the whole
+// file, since they usually mean the wrong layout was uploaded.
+func parseImport(body []byte) ([]*importGroup, int, error) {
+	reader := csv.NewReader(bytes.NewReader(body))
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, 0, errors.New("import needs a header row")
+	}
+	columns := make([]string, len(header))
+	has_log_key := false
+	has_export_key := false
+	unknown := []string{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		columns[i] = name
+		_, log_field := flight_log_import_fields[name]
+		_, mission_field := mission_import_fields[name]
+		_, aircrew_field := aircrew_import_fields[name]
+		switch {
+		case name == import_log_key:
+			has_log_key = true
+		case name == import_export_key:
+			has_export_key = true
+		case !log_field && !mission_field && !aircrew_field && !ignored_import_columns[name]:
+			unknown = append(unknown, name)
+		}
+	}
+	key_column := import_log_key
+	if !has_log_key {
+		if !has_export_key {
+			return nil, 0, fmt.Errorf("import needs a %s or %s column", import_log_key, import_export_key)
+		}
+		key_column = import_export_key
+	}
+	if len(unknown) > 0 {
+		return nil, 0, fmt.Errorf("unknown import columns: %s", strings.Join(unknown, ", "))
+	}
+
+	groups := []*importGroup{}
+	by_key := map[string]*importGroup{}
+	row_count := 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid csv: %s", err.Error())
+		}
+		row_count++
+		if row_count > max_import_rows {
+			return nil, 0, fmt.Errorf("import is limited to %d rows", max_import_rows)
+		}
+		line, _ := reader.FieldPos(0)
+		row := importRow{line: line, values: map[string]string{}}
+		for i, value := range record {
+			row.values[columns[i]] = importValue(value)
+		}
+		key := row.values[key_column]
+		if by_key[key] == nil {
+			by_key[key] = &importGroup{key: key, key_column: key_column}
+			groups = append(groups, by_key[key])
+		}
+		by_key[key].add(row, columns)
+	}
+	return groups, row_count, nil
+}
+
+func parseImportTime(value string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02 15:04", "2006-01-02"} {
+		parsed, err := time.Parse(layout, value)
+		if err == nil {
+			return parsed.UTC(), nil
+		}
+	}
+	return time.Time{}, errors.New("not a date or time")
+}
+
+// add folds one row into the group's flight log, visiting columns in file
+// order so errors come back in the order they appear.
+func (group *importGroup) add(row importRow, columns []string) {
+	if group.key == "" {
+		group.reject(row, group.key_column, validationRequired, fmt.Sprintf("%s is required", group.key_column))
+	}
+	first := len(group.rows) == 0
+	group.rows = append(group.rows, row)
+	for _, column := range columns {
+		set, ok := flight_log_import_fields[column]
+		value := row.values[column]
+		switch {
+		case !ok || value == "":
+		case first:
+			if set(&group.flight_log, value) != nil {
+				group.reject(row, column, importInvalidValue, fmt.Sprintf("%s is not valid: %s", column, value))
+			}
+		case value != group.rows[0].values[column]:
+			group.reject(row, column, importConflict, fmt.Sprintf("%s differs from the log's first row (line %d)", column, group.rows[0].line))
+		}
+	}
+
+	var mission types.FlightLogMissionDTO
+	has_mission := false
+	for _, column := range columns {
+		set, ok := mission_import_fields[column]
+		if !ok || row.values[column] == "" {
+			continue
+		}
+		has_mission = true
+		if set(&mission, row.values[column]) != nil {
+			group.reject(row, column, importInvalidValue, fmt.Sprintf("%s is not valid: %s", column, row.values[column]))
+		}
+	}
+	if has_mission {
+		group.flight_log.Missions = append(group.flight_log.Missions, mission)
+		group.mission_rows = append(group.mission_rows, row.line)
+	}
+
+	var aircrew types.FlightLogAircrewDTO
+	has_aircrew := false
+	for _, column := range columns {
+		set, ok := aircrew_import_fields[column]
+		if !ok || row.values[column] == "" {
+			continue
+		}
+		has_aircrew = true
+		if set(&aircrew, row.values[column]) != nil {
+			group.reject(row, column, importInvalidValue, fmt.Sprintf("%s is not valid: %s", column, row.values[column]))
+		}
+	}
+	if has_aircrew {
+		group.flight_log.Aircrew = append(group.flight_log.Aircrew, aircrew)
+		group.aircrew_rows = append(group.aircrew_rows, row.line)
+	}
+}
+
+func (group *importGroup) reject(row importRow, column string, code string, message string) {
+	group.errors = append(group.errors, ImportRowError{
+		Row:        row.line,
+		LogKey:     group.key,
+		FieldError: FieldError{Path: column, Code: code, Message: message},
+	})
+}
+
+// rowFor maps a validation path such as "missions[2].mission_to" back to the
+// CSV line that mission came from. Flight log level paths point at the
+// group's first row.
+func (group *importGroup) rowFor(path string) int {
+	match := import_path_index.FindStringSubmatch(path)
+	if match != nil {
+		index, _ := strconv.Atoi(match[2])
+		if match[1] == "missions" && index < len(group.mission_rows) {
+			return group.mission_rows[index]
+		}
+		if match[1] == "aircrew" && index < len(group.aircrew_rows) {
+			return group.aircrew_rows[index]
+		}
+	}
+	return group.rows[0].line
+}
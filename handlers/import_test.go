@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseImportGroupsByLogKey(t *testing.T) {
+	body := strings.Join([]string{
+		"log_key,mds,mission_number,aircrew_role_type",
+		"a,T-38C,M1,IP",
+		"b,T-38C,M1,",
+		"a,T-38C,M2,SP",
+	}, "\n")
+	groups, row_count, err := parseImport([]byte(body))
+	if err != nil {
+		t.Fatalf("parseImport: %s", err)
+	}
+	if row_count != 3 {
+		t.Errorf("row_count = %d, want 3", row_count)
+	}
+	if len(groups) != 2 || groups[0].key != "a" || groups[1].key != "b" {
+		t.Fatalf("groups not in order of first appearance: %+v", groups)
+	}
+	a := groups[0]
+	if len(a.errors) > 0 {
+		t.Errorf("unexpected errors: %+v", a.errors)
+	}
+	if a.flight_log.MDS != "T-38C" {
+		t.Errorf("MDS = %q, want T-38C", a.flight_log.MDS)
+	}
+	if len(a.flight_log.Missions) != 2 || len(a.flight_log.Aircrew) != 2 {
+		t.Errorf("got %d missions and %d aircrew, want 2 and 2", len(a.flight_log.Missions), len(a.flight_log.Aircrew))
+	}
+	if len(groups[1].flight_log.Aircrew) != 0 {
+		t.Errorf("blank aircrew columns should not add an aircrew entry")
+	}
+	lines := a.lines()
+	if len(lines) != 2 || lines[0] != 2 || lines[1] != 4 {
+		t.Errorf("lines = %v, want [2 4]", lines)
+	}
+}
+
+func TestParseImportFallsBackToFlightLogID(t *testing.T) {
+	body := strings.Join([]string{
+		"flight_log_id,mds,mission_number",
+		"11111111-1111-1111-1111-111111111111,T-38C,M1",
+		"11111111-1111-1111-1111-111111111111,T-38C,M2",
+	}, "\n")
+	groups, _, err := parseImport([]byte(body))
+	if err != nil {
+		t.Fatalf("parseImport: %s", err)
+	}
+	if len(groups) != 1 || len(groups[0].flight_log.Missions) != 2 {
+		t.Fatalf("export rows were not grouped by flight_log_id: %+v", groups)
+	}
+	if groups[0].key_column != import_export_key {
+		t.Errorf("key_column = %q, want %q", groups[0].key_column, import_export_key)
+	}
+}
+
+func TestParseImportRejectsConflicts(t *testing.T) {
+	body := strings.Join([]string{
+		"log_key,mds,unit_charged",
+		"a,T-38C,0016 TRS",
+		"a,T-6A,0016 TRS",
+	}, "\n")
+	groups, _, err := parseImport([]byte(body))
+	if err != nil {
+		t.Fatalf("parseImport: %s", err)
+	}
+	errs := groups[0].errors
+	if len(errs) != 1 {
+		t.Fatalf("errors = %+v, want one conflict", errs)
+	}
+	if errs[0].Row != 3 || errs[0].Path != "mds" || errs[0].Code != importConflict {
+		t.Errorf("error = %+v, want a mds conflict on line 3", errs[0])
+	}
+}
+
+func TestParseImportRejectsFiles(t *testing.T) {
+	cases := map[string]string{
+		"no header":      "",
+		"no key":         "mds\nT-38C",
+		"unknown column": "log_key,tail_number\na,1",
+		"ragged row":     "log_key,mds\na,T-38C\n# export incomplete",
+	}
+	for name, body := range cases {
+		_, _, err := parseImport([]byte(body))
+		if err == nil {
+			t.Errorf("%s: parseImport accepted the file", name)
+		}
+	}
+}
+
+func TestImportRowFor(t *testing.T) {
+	body := strings.Join([]string{
+		"log_key,mission_number,aircrew_role_type",
+		"a,,IP",
+		"a,M1,",
+		"a,M2,SP",
+	}, "\n")
+	groups, _, err := parseImport([]byte(body))
+	if err != nil {
+		t.Fatalf("parseImport: %s", err)
+	}
+	group := groups[0]
+	cases := map[string]int{
+		"mds":                    2,
+		"missions[0].mission_to": 3,
+		"missions[1].sorties":    4,
+		"missions[5].sorties":    2,
+		"aircrew[0].time_other":  2,
+		"aircrew[1]":             4,
+	}
+	for path, line := range cases {
+		if got := group.rowFor(path); got != line {
+			t.Errorf("rowFor(%q) = %d, want %d", path, got, line)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"flight_log_service/db"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/thedanisaur/jfl_platform/types"
+	"github.com/thedanisaur/jfl_platform/util"
+)
+
+// flight_log_transitions lists the statuses each status may move to. Moving a
+// signed or closed log back to draft is a formal correction and needs a
+// reason; closing and correcting closed logs is reserved for SARM.
+var flight_log_transitions = map[string][]string{
+	db.FlightLogStatusDraft:     {db.FlightLogStatusSubmitted},
+	db.FlightLogStatusSubmitted: {db.FlightLogStatusDraft, db.FlightLogStatusSigned},
+	db.FlightLogStatusSigned:    {db.FlightLogStatusClosed, db.FlightLogStatusDraft},
+	db.FlightLogStatusClosed:    {db.FlightLogStatusDraft},
+}
+
+type transitionRequest struct {
+	Reason string `json:"reason"`
+}
+
+func CreateFlightlogTransition(config types.Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		txid := c.Locals("transaction_id").(uuid.UUID)
+		log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(CreateFlightlogTransition))
+
+		user_id, err := uuid.Parse(c.Params("user_id"))
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString("invalid user")
+		}
+		flight_log_id, err := uuid.Parse(c.Params("flight_log_id"))
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString("invalid flight log")
+		}
+		to_status := c.Params("status")
+		if _, ok := flight_log_transitions[to_status]; !ok {
+			return c.Status(fiber.StatusNotFound).SendString("invalid flight log status")
+		}
+
+		var request transitionRequest
+		if len(c.Body()) > 0 {
+			err = c.BodyParser(&request)
+			if err != nil {
+				log.Printf("Failed to parse transition\n%s\n", err.Error())
+				return c.Status(fiber.StatusBadRequest).SendString(fmt.Sprintf("Failed to parse transition: %s\n", txid.String()))
+			}
+		}
+		request.Reason = strings.TrimSpace(request.Reason)
+
+		/* Get the requesting user's info */
+		request_user := c.Locals("user_claims").(types.UserClaims)
+
+		transaction, err := db.BeginTransaction(txid)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		defer transaction.Rollback()
+
+		version, err := db.LockFlightLogVersion(txid, transaction, flight_log_id)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		if ifMatchFailed(c, version) {
+			return c.Status(fiber.StatusPreconditionFailed).SendString("flight log has been modified")
+		}
+		from_status, err := db.LockFlightLogStatus(txid, transaction, flight_log_id)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+
+		/* Validate the transition */
+		if !transitionAllowed(from_status, to_status) {
+			return c.Status(fiber.StatusConflict).SendString(fmt.Sprintf("cannot move flight log from %s to %s", from_status, to_status))
+		}
+		correction := to_status == db.FlightLogStatusDraft && (from_status == db.FlightLogStatusSigned || from_status == db.FlightLogStatusClosed)
+		if correction && request.Reason == "" {
+			return c.Status(fiber.StatusBadRequest).SendString("a correction requires a reason")
+		}
+		if (to_status == db.FlightLogStatusClosed || from_status == db.FlightLogStatusClosed) && normalizeRoleName(request_user.RoleName) != "sarm" {
+			return c.Status(fiber.StatusForbidden).SendString("only sarm may close or correct a closed flight log")
+		}
+		if to_status != db.FlightLogStatusDraft {
+			flight_log, err := loadFlightLog(txid, user_id, flight_log_id)
+			if err != nil {
+				return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+			}
+			if (to_status == db.FlightLogStatusSigned || to_status == db.FlightLogStatusClosed) && flight_log.SarmSignatureID == uuid.Nil {
+				return c.Status(fiber.StatusConflict).SendString("flight log has not been signed by sarm")
+			}
+			/* Past draft every reconciliation discrepancy blocks */
+			discrepancies := reconcileFlightLog(flight_log, to_status)
+			if hasBlockingDiscrepancy(discrepancies) {
+				response := fiber.Map{
+					"txid":          txid.String(),
+					"discrepancies": discrepancies,
+				}
+				return c.Status(fiber.StatusUnprocessableEntity).JSON(response)
+			}
+		}
+
+		transition_id, err := db.InsertFlightLogTransition(txid, transaction, flight_log_id, from_status, to_status, request_user, request.Reason)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		version, err = db.IncrementFlightLogVersion(txid, transaction, flight_log_id)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		err = db.CommitTransaction(txid, transaction)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		c.Set(fiber.HeaderETag, formatETag(version))
+		response := fiber.Map{
+			"txid":          txid.String(),
+			"flight_log_id": flight_log_id,
+			"transition_id": transition_id,
+			"from_status":   from_status,
+			"status":        to_status,
+		}
+		return c.Status(fiber.StatusCreated).JSON(response)
+	}
+}
+
+func GetFlightlogTransitions(config types.Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		txid := c.Locals("transaction_id").(uuid.UUID)
+		log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(GetFlightlogTransitions))
+
+		user_id, err := uuid.Parse(c.Params("user_id"))
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString("invalid user")
+		}
+		flight_log_id, err := uuid.Parse(c.Params("flight_log_id"))
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString("invalid flight log")
+		}
+
+		status, err := db.GetFlightLogStatus(txid, user_id, flight_log_id)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		transitions, err := db.GetFlightLogTransitions(txid, flight_log_id)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+
+		response := fiber.Map{
+			"txid":          txid.String(),
+			"flight_log_id": flight_log_id,
+			"status":        status,
+			"transitions":   transitions,
+		}
+		return c.Status(fiber.StatusOK).JSON(response)
+	}
+}
+
+// childWriteFailed answers a failed mission or aircrew write. An id that
+// belongs to another log is a 422 against its body path; anything else goes
+// through flightLogWriteStatus.
+func childWriteFailed(c *fiber.Ctx, txid uuid.UUID, err error) error {
+	var foreign *db.ForeignChildError
+	if errors.As(err, &foreign) {
+		return validationFailed(c, txid, []FieldError{{Path: foreign.Path, Code: validationForeignChild, Message: foreign.Error()}})
+	}
+	return c.Status(flightLogWriteStatus(err)).SendString(err.Error())
+}
+
+// flightLogWriteStatus maps an error from a flight log write to a response
+// status, so edits to a locked log surface as a conflict rather than an
+// outage.
+func flightLogWriteStatus(err error) int {
+	if errors.Is(err, db.ErrFlightLogLocked) {
+		return fiber.StatusConflict
+	}
+	return fiber.StatusServiceUnavailable
+}
+
+func transitionAllowed(from_status string, to_status string) bool {
+	for _, status := range flight_log_transitions[from_status] {
+		if status == to_status {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"testing"
+
+	"flight_log_service/db"
+)
+
+func TestTransitionAllowed(t *testing.T) {
+	cases := []struct {
+		from    string
+		to      string
+		allowed bool
+	}{
+		{db.FlightLogStatusDraft, db.FlightLogStatusSubmitted, true},
+		{db.FlightLogStatusDraft, db.FlightLogStatusSigned, false},
+		{db.FlightLogStatusDraft, db.FlightLogStatusClosed, false},
+		{db.FlightLogStatusSubmitted, db.FlightLogStatusDraft, true},
+		{db.FlightLogStatusSubmitted, db.FlightLogStatusSigned, true},
+		{db.FlightLogStatusSubmitted, db.FlightLogStatusClosed, false},
+		{db.FlightLogStatusSigned, db.FlightLogStatusClosed, true},
+		{db.FlightLogStatusSigned, db.FlightLogStatusDraft, true},
+		{db.FlightLogStatusSigned, db.FlightLogStatusSubmitted, false},
+		{db.FlightLogStatusClosed, db.FlightLogStatusDraft, true},
+		{db.FlightLogStatusClosed, db.FlightLogStatusSigned, false},
+		{"unknown", db.FlightLogStatusDraft, false},
+	}
+	for _, test := range cases {
+		if got := transitionAllowed(test.from, test.to); got != test.allowed {
+			t.Errorf("transitionAllowed(%s, %s) = %v, want %v", test.from, test.to, got, test.allowed)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"math"
+	"time"
+
+	"flight_log_service/airfields"
+
+	"github.com/thedanisaur/jfl_platform/types"
+)
+
+// civil_twilight_elevation is the solar elevation, in degrees, below which it
+// counts as night: from the end of evening civil twilight to the start of
+// morning civil twilight.
+const civil_twilight_elevation = -6.0
+
+// solarElevation returns the sun's elevation in degrees at a position and
+// instant, using the low precision NOAA/Astronomical Almanac formulas (good
+// to about 0.01 degrees, far tighter than a one minute sample needs).
+func solarElevation(at time.Time, latitude float64, longitude float64) float64 {
+	radians := math.Pi / 180
+	days := float64(at.UTC().UnixNano())/float64(24*time.Hour) + 2440587.5 - 2451545.0
+
+	mean_longitude := math.Mod(280.460+0.9856474*days, 360)
+	mean_anomaly := math.Mod(357.528+0.9856003*days, 360) * radians
+	ecliptic_longitude := (mean_longitude + 1.915*math.Sin(mean_anomaly) + 0.020*math.Sin(2*mean_anomaly)) * radians
+	obliquity := (23.439 - 0.0000004*days) * radians
+
+	right_ascension := math.Atan2(math.Cos(obliquity)*math.Sin(ecliptic_longitude), math.Cos(ecliptic_longitude))
+	declination := math.Asin(math.Sin(obliquity) * math.Sin(ecliptic_longitude))
+	sidereal := math.Mod(280.46061837+360.98564736629*days, 360) * radians
+	hour_angle := sidereal + longitude*radians - right_ascension
+
+	sin_elevation := math.Sin(latitude*radians)*math.Sin(declination) + math.Cos(latitude*radians)*math.Cos(declination)*math.Cos(hour_angle)
+	return math.Asin(sin_elevation) / radians
+}
+
+// suggestedNightMinutes estimates how many minutes of a mission were flown at
+// night. The aircraft is assumed to move in a straight line from mission_from
+// to mission_to, and the sun is sampled once per minute at that position. If
+// only one end is a known airfield both ends use it. ok is false when neither
+// airfield is known or the times are unusable.
+func suggestedNightMinutes(mission types.FlightLogMissionDTO) (int, bool) {
+	if mission.TakeoffTime.IsZero() || !mission.LandTime.After(mission.TakeoffTime) {
+		return 0, false
+	}
+	from, from_ok := airfields.Lookup(mission.MissionFrom)
+	to, to_ok := airfields.Lookup(mission.MissionTo)
+	if !from_ok && !to_ok {
+		return 0, false
+	}
+	if !from_ok {
+		from = to
+	}
+	if !to_ok {
+		to = from
+	}
+
+	delta_longitude := to.Longitude - from.Longitude
+	if delta_longitude > 180 {
+		delta_longitude -= 360
+	} else if delta_longitude < -180 {
+		delta_longitude += 360
+	}
+	minutes := int(math.Round(mission.LandTime.Sub(mission.TakeoffTime).Minutes()))
+	night := 0
+	for minute := 0; minute < minutes; minute++ {
+		fraction := (float64(minute) + 0.5) / float64(minutes)
+		latitude := from.Latitude + (to.Latitude-from.Latitude)*fraction
+		longitude := from.Longitude + delta_longitude*fraction
+		at := mission.TakeoffTime.Add(time.Duration(float64(minute)+0.5) * time.Minute)
+		if solarElevation(at, latitude, longitude) < civil_twilight_elevation {
+			night++
+		}
+	}
+	return night, true
+}
+
+// suggestedNightTime sums the suggested night time over a flight log's
+// missions in decimal hours. ok is false if any mission could not be
+// computed, since a partial figure would understate night time.
+func suggestedNightTime(missions []types.FlightLogMissionDTO) (float64, bool) {
+	if len(missions) == 0 {
+		return 0, false
+	}
+	total := 0.0
+	for _, mission := range missions {
+		minutes, ok := suggestedNightMinutes(mission)
+		if !ok {
+			return 0, false
+		}
+		total += decimalHours(minutes)
+	}
+	return math.Round(total*100) / 100, true
+}
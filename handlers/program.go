@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"flight_log_service/db"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/thedanisaur/jfl_platform/types"
+	"github.com/thedanisaur/jfl_platform/util"
+)
+
+// programLine is one unit (MDS empty) or one unit and MDS measured against
+// its allocation. Allocation figures and everything derived from them are
+// null when the program has no matching entry.
+type programLine struct {
+	UnitCharged       string          `json:"unit_charged"`
+	MDS               string          `json:"mds"`
+	AllocatedHours    *float64        `json:"allocated_hours"`
+	AllocatedSorties  *int            `json:"allocated_sorties"`
+	Flights           int             `json:"flights"`
+	Hours             float64         `json:"hours"`
+	Sorties           int             `json:"sorties"`
+	RemainingHours    *float64        `json:"remaining_hours"`
+	PercentFlown      *float64        `json:"percent_flown"`
+	BurnRate          float64         `json:"burn_rate"`
+	ProjectedHours    *float64        `json:"projected_hours"`
+	ProjectedVariance *float64        `json:"projected_variance"`
+	ByQuarter         []programPeriod `json:"by_quarter"`
+	ByMonth           []programPeriod `json:"by_month"`
+}
+
+type programPeriod struct {
+	Period  string  `json:"period"`
+	Flights int     `json:"flights"`
+	Hours   float64 `json:"hours"`
+	Sorties int     `json:"sorties"`
+}
+
+type programKey struct {
+	unit_charged string
+	mds          string
+}
+
+func GetFlyingHourProgram(config types.Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		txid := c.Locals("transaction_id").(uuid.UUID)
+		log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(GetFlyingHourProgram))
+
+		/* Scoped by AuthorizationMiddleware */
+		where_clause := c.Locals("authorization_where_clause").(string)
+		arguments := c.Locals("authorization_arguments").([]interface{})
+
+		now := time.Now().UTC()
+		fy_start := fiscalYearStart(now)
+		if c.Query("fiscal_year") != "" {
+			fiscal_year, err := strconv.Atoi(c.Query("fiscal_year"))
+			if err != nil || fiscal_year < 1 {
+				return c.Status(fiber.StatusBadRequest).SendString("invalid fiscal_year")
+			}
+			fy_start = time.Date(fiscal_year-1, time.October, 1, 0, 0, 0, 0, time.UTC)
+		}
+		fy_end := fy_start.AddDate(1, 0, 0)
+		unit_charged := c.Query("unit_charged")
+		mds := c.Query("mds")
+
+		months, err := db.GetFlyingHourRollup(txid, where_clause, arguments, fy_start, fy_end, unit_charged, mds)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+
+		total_days := fy_end.Sub(fy_start).Hours() / 24
+		elapsed_days := math.Floor(now.Sub(fy_start).Hours()/24) + 1
+		elapsed_days = math.Max(0, math.Min(elapsed_days, total_days))
+		lines := buildProgramLines(fy_start, months, unit_charged, mds)
+		for i := range lines {
+			projectProgramLine(&lines[i], elapsed_days, total_days)
+		}
+
+		response := fiber.Map{
+			"txid":            txid.String(),
+			"fiscal_year":     fy_end.Year(),
+			"date_from":       fy_start,
+			"date_to":         fy_end,
+			"elapsed_days":    elapsed_days,
+			"total_days":      total_days,
+			"percent_elapsed": roundTenth(elapsed_days / total_days * 100),
+			"lines":           lines,
+		}
+		return c.Status(fiber.StatusOK).JSON(response)
+	}
+}
+
+// buildProgramLines spreads the monthly rollup into a line per unit and MDS,
+// plus a whole-unit line when the request is not already narrowed to one
+// MDS, and adds a line for every matching allocation nothing was flown
+// against.
+func buildProgramLines(fy_start time.Time, months []db.FlyingHourMonth, unit_charged string, mds string) []programLine {
+	fiscal_year := fy_start.Year() + 1
+	lines := map[programKey]*programLine{}
+	line := func(key programKey) *programLine {
+		if lines[key] == nil {
+			lines[key] = newProgramLine(fy_start, key)
+		}
+		return lines[key]
+	}
+
+	for _, month := range months {
+		keys := []programKey{{unit_charged: month.UnitCharged, mds: month.MDS}}
+		if mds == "" {
+			keys = append(keys, programKey{unit_charged: month.UnitCharged})
+		}
+		month_index := (int(month.Month.Month()) + 2) % 12
+		for _, key := range keys {
+			program_line := line(key)
+			program_line.Flights += month.Flights
+			program_line.Hours += month.Hours
+			program_line.Sorties += month.Sorties
+			for _, period := range []*programPeriod{&program_line.ByMonth[month_index], &program_line.ByQuarter[month_index/3]} {
+				period.Flights += month.Flights
+				period.Hours += month.Hours
+				period.Sorties += month.Sorties
+			}
+		}
+	}
+
+	for _, allocation := range settings.Program {
+		if allocation.FiscalYear != fiscal_year {
+			continue
+		}
+		if (unit_charged != "" && allocation.UnitCharged != unit_charged) || (mds != "" && allocation.MDS != mds) {
+			continue
+		}
+		program_line := line(programKey{unit_charged: allocation.UnitCharged, mds: allocation.MDS})
+		hours := allocation.Hours
+		sorties := allocation.Sorties
+		program_line.AllocatedHours = &hours
+		program_line.AllocatedSorties = &sorties
+	}
+
+	sorted := make([]programLine, 0, len(lines))
+	for _, program_line := range lines {
+		sorted = append(sorted, *program_line)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].UnitCharged != sorted[j].UnitCharged {
+			return sorted[i].UnitCharged < sorted[j].UnitCharged
+		}
+		return sorted[i].MDS < sorted[j].MDS
+	})
+	return sorted
+}
+
+func newProgramLine(fy_start time.Time, key programKey) *programLine {
+	program_line := &programLine{
+		UnitCharged: key.unit_charged,
+		MDS:         key.mds,
+		ByQuarter:   make([]programPeriod, 4),
+		ByMonth:     make([]programPeriod, 12),
+	}
+	for i := range program_line.ByMonth {
+		program_line.ByMonth[i].Period = fy_start.AddDate(0, i, 0).Format("2006-01")
+	}
+	for i := range program_line.ByQuarter {
+		program_line.ByQuarter[i].Period = fmt.Sprintf("Q%d", i+1)
+	}
+	return program_line
+}
+
+// projectProgramLine extends the burn rate so far (hours per elapsed day)
+// across the whole fiscal year. Nothing is projected before the year starts.
+func projectProgramLine(program_line *programLine, elapsed_days float64, total_days float64) {
+	program_line.Hours = roundTenth(program_line.Hours)
+	for i := range program_line.ByMonth {
+		program_line.ByMonth[i].Hours = roundTenth(program_line.ByMonth[i].Hours)
+	}
+	for i := range program_line.ByQuarter {
+		program_line.ByQuarter[i].Hours = roundTenth(program_line.ByQuarter[i].Hours)
+	}
+	if elapsed_days > 0 {
+		program_line.BurnRate = math.Round(program_line.Hours/elapsed_days*100) / 100
+		projected := roundTenth(program_line.Hours / elapsed_days * total_days)
+		program_line.ProjectedHours = &projected
+	}
+	if program_line.AllocatedHours == nil {
+		return
+	}
+	allocated := *program_line.AllocatedHours
+	remaining := roundTenth(allocated - program_line.Hours)
+	program_line.RemainingHours = &remaining
+	if allocated > 0 {
+		percent := roundTenth(program_line.Hours / allocated * 100)
+		program_line.PercentFlown = &percent
+	}
+	if program_line.ProjectedHours != nil {
+		variance := roundTenth(*program_line.ProjectedHours - allocated)
+		program_line.ProjectedVariance = &variance
+	}
+}
+
+func roundTenth(value float64) float64 {
+	return math.Round(value*10) / 10
+}
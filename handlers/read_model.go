@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"flight_log_service/db"
+
+	"github.com/thedanisaur/jfl_platform/types"
+)
+
+// flightLogReadModel is what the read endpoints return: the platform DTO with
+// missions swapped for missionReadModel so service computed values can ride
+// along without changing the shared types. SourceTemplate is only filled in
+// by the single log read, and only for logs instantiated from a template.
+type flightLogReadModel struct {
+	types.FlightLogDTO
+	Missions       []missionReadModel             `json:"missions"`
+	SourceTemplate *db.FlightLogSourceTemplateDTO `json:"source_template,omitempty"`
+}
+
+// missionReadModel adds the suggested night time, in decimal hours under the
+// configured rounding. It is null when neither airfield is in the table.
+type missionReadModel struct {
+	types.FlightLogMissionDTO
+	SuggestedNightTime *float64 `json:"suggested_night_time"`
+}
+
+func newFlightLogReadModel(flight_log types.FlightLogDTO) flightLogReadModel {
+	read_model := flightLogReadModel{FlightLogDTO: flight_log}
+	if flight_log.Missions == nil {
+		return read_model
+	}
+	read_model.Missions = make([]missionReadModel, 0, len(flight_log.Missions))
+	for _, mission := range flight_log.Missions {
+		mission_read_model := missionReadModel{FlightLogMissionDTO: mission}
+		minutes, ok := suggestedNightMinutes(mission)
+		if ok {
+			night_time := decimalHours(minutes)
+			mission_read_model.SuggestedNightTime = &night_time
+		}
+		read_model.Missions = append(read_model.Missions, mission_read_model)
+	}
+	return read_model
+}
+
+func newFlightLogReadModels(flight_logs []types.FlightLogDTO) []flightLogReadModel {
+	read_models := make([]flightLogReadModel, 0, len(flight_logs))
+	for _, flight_log := range flight_logs {
+		read_models = append(read_models, newFlightLogReadModel(flight_log))
+	}
+	return read_models
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+
+	"flight_log_service/airfields"
+	"flight_log_service/db"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/thedanisaur/jfl_platform/types"
+	"github.com/thedanisaur/jfl_platform/util"
+)
+
+// Reconciliation codes and severities. Drafts only collect warnings; once a
+// log leaves draft every discrepancy is an error and blocks the transition.
+const (
+	reconcileFlightTotal     = "flight_total_mismatch"
+	reconcileAircrewDuration = "aircrew_duration_mismatch"
+	reconcileAircrewBreakout = "aircrew_breakout_mismatch"
+	reconcileConditionExcess = "condition_exceeds_total"
+	reconcileNightTime       = "night_time_mismatch"
+	reconcileAirfield        = "unknown_airfield"
+
+	severityWarning = "warning"
+	severityError   = "error"
+)
+
+type Discrepancy struct {
+	FieldError
+	Severity string `json:"severity"`
+}
+
+func GetFlightlogReconciliation(config types.Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		txid := c.Locals("transaction_id").(uuid.UUID)
+		log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(GetFlightlogReconciliation))
+
+		user_id, err := uuid.Parse(c.Params("user_id"))
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString("invalid user")
+		}
+		flight_log_id, err := uuid.Parse(c.Params("flight_log_id"))
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString("invalid flight log")
+		}
+
+		status, err := db.GetFlightLogStatus(txid, user_id, flight_log_id)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		flight_log, err := loadFlightLog(txid, user_id, flight_log_id)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+
+		discrepancies := reconcileFlightLog(flight_log, status)
+		response := fiber.Map{
+			"txid":          txid.String(),
+			"flight_log_id": flight_log_id,
+			"status":        status,
+			"blocking":      hasBlockingDiscrepancy(discrepancies),
+			"discrepancies": discrepancies,
+		}
+		return c.Status(fiber.StatusOK).JSON(response)
+	}
+}
+
+func hasBlockingDiscrepancy(discrepancies []Discrepancy) bool {
+	for _, discrepancy := range discrepancies {
+		if discrepancy.Severity == severityError {
+			return true
+		}
+	}
+	return false
+}
+
+// reconcileFlightLog cross-checks aircrew time against mission time:
+//   - the header total against the sum of mission total_time_decimal
+//   - each crew member's total_aircrew_duration_decimal against the same sum
+//   - each crew member's time_* breakout against their total
+//   - night, NVG and instrument time never exceeding their total
+//   - night time against the civil twilight estimate, as a warning only
+//   - mission airfields missing from the bundled table, as a warning only
+//
+// status decides whether discrepancies are warnings or errors.
+func reconcileFlightLog(flight_log types.FlightLogDTO, status string) []Discrepancy {
+	severity := severityError
+	if status == db.FlightLogStatusDraft {
+		severity = severityWarning
+	}
+	discrepancies := []Discrepancy{}
+	add := func(path string, code string, message string) {
+		discrepancies = append(discrepancies, Discrepancy{
+			FieldError: FieldError{Path: path, Code: code, Message: message},
+			Severity:   severity,
+		})
+	}
+
+	mission_total := 0.0
+	for _, mission := range flight_log.Missions {
+		mission_total += mission.TotalTimeDecimal
+	}
+	if len(flight_log.Missions) > 0 && !timesAgree(flight_log.TotalFlightDecimalTime, mission_total) {
+		add("total_flight_decimal_time", reconcileFlightTotal, fmt.Sprintf("total_flight_decimal_time %.1f does not match mission total %.1f", flight_log.TotalFlightDecimalTime, mission_total))
+	}
+
+	for i, aircrew := range flight_log.Aircrew {
+		path := fmt.Sprintf("aircrew[%d]", i)
+		total := aircrew.TotalAircrewDurationDecimal
+		if len(flight_log.Missions) > 0 && !timesAgree(total, mission_total) {
+			add(path+".total_aircrew_duration_decimal", reconcileAircrewDuration, fmt.Sprintf("total_aircrew_duration_decimal %.1f does not match mission total %.1f", total, mission_total))
+		}
+		breakout := aircrew.TimePrimary + aircrew.TimeSecondary + aircrew.TimeInstructor + aircrew.TimeEvaluator + aircrew.TimeOther
+		if !timesAgree(breakout, total) {
+			add(path+".total_aircrew_duration_decimal", reconcileAircrewBreakout, fmt.Sprintf("primary, secondary, instructor, evaluator and other time sum to %.1f, not %.1f", breakout, total))
+		}
+		for _, condition := range []struct {
+			name  string
+			value float64
+		}{
+			{"cond_night_time", aircrew.CondNightTime},
+			{"cond_nvg_time", aircrew.CondNvgTime},
+			{"cond_instrument_time", aircrew.CondInstrumentTime},
+		} {
+			if tenths(condition.value) > tenths(total) {
+				add(path+"."+condition.name, reconcileConditionExcess, fmt.Sprintf("%s %.1f exceeds total_aircrew_duration_decimal %.1f", condition.name, condition.value, total))
+			}
+		}
+	}
+
+	/* Night time is an estimate, so a mismatch never blocks */
+	suggested_night, ok := suggestedNightTime(flight_log.Missions)
+	if ok {
+		for i, aircrew := range flight_log.Aircrew {
+			if math.Abs(float64(tenths(aircrew.CondNightTime)-tenths(suggested_night))) > 1 {
+				discrepancies = append(discrepancies, Discrepancy{
+					FieldError: FieldError{
+						Path:    fmt.Sprintf("aircrew[%d].cond_night_time", i),
+						Code:    reconcileNightTime,
+						Message: fmt.Sprintf("cond_night_time %.1f differs from the %.1f computed from airfields and civil twilight", aircrew.CondNightTime, suggested_night),
+					},
+					Severity: severityWarning,
+				})
+			}
+		}
+	}
+	/* The table only covers common fields, so an unknown one never blocks */
+	for i, mission := range flight_log.Missions {
+		for _, field := range []struct {
+			name  string
+			value string
+		}{
+			{"mission_from", mission.MissionFrom},
+			{"mission_to", mission.MissionTo},
+		} {
+			if strings.TrimSpace(field.value) == "" {
+				continue
+			}
+			if _, ok := airfields.Lookup(field.value); !ok {
+				discrepancies = append(discrepancies, Discrepancy{
+					FieldError: FieldError{
+						Path:    fmt.Sprintf("missions[%d].%s", i, field.name),
+						Code:    reconcileAirfield,
+						Message: fmt.Sprintf("%s is not in the airfield table: %s", field.name, field.value),
+					},
+					Severity: severityWarning,
+				})
+			}
+		}
+	}
+	return discrepancies
+}
+
+// tenths rounds decimal hours to the tenth they are logged in, so sums of
+// tenths compare cleanly despite float error.
+func tenths(hours float64) int {
+	return int(math.Round(hours * 10))
+}
+
+func timesAgree(a float64, b float64) bool {
+	return tenths(a) == tenths(b)
+}
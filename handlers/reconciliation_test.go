@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"testing"
+
+	"flight_log_service/db"
+
+	"github.com/thedanisaur/jfl_platform/types"
+)
+
+func TestReconcileFlightLog(t *testing.T) {
+	flight_log := types.FlightLogDTO{
+		TotalFlightDecimalTime: 1.5,
+		Missions:               []types.FlightLogMissionDTO{{TotalTimeDecimal: 1.0}, {TotalTimeDecimal: 0.5}},
+		Aircrew: []types.FlightLogAircrewDTO{
+			{TotalAircrewDurationDecimal: 1.5, TimePrimary: 1.5, CondInstrumentTime: 0.3},
+			{TotalAircrewDurationDecimal: 1.2, TimePrimary: 1.0, CondNvgTime: 1.3},
+		},
+	}
+	want := map[string]string{
+		"aircrew[1].total_aircrew_duration_decimal": reconcileAircrewDuration,
+		"aircrew[1].cond_nvg_time":                  reconcileConditionExcess,
+	}
+	cases := []struct {
+		status   string
+		severity string
+		blocking bool
+	}{
+		{db.FlightLogStatusDraft, severityWarning, false},
+		{db.FlightLogStatusSubmitted, severityError, true},
+		{db.FlightLogStatusSigned, severityError, true},
+	}
+	for _, test := range cases {
+		discrepancies := reconcileFlightLog(flight_log, test.status)
+		found := map[string]bool{}
+		for _, discrepancy := range discrepancies {
+			if discrepancy.Code == reconcileAirfield || discrepancy.Code == reconcileNightTime {
+				continue
+			}
+			if discrepancy.Severity != test.severity {
+				t.Errorf("%s: %s severity = %s, want %s", test.status, discrepancy.Code, discrepancy.Severity, test.severity)
+			}
+			if discrepancy.Code == want[discrepancy.Path] {
+				found[discrepancy.Path] = true
+			}
+		}
+		for path, code := range want {
+			if !found[path] {
+				t.Errorf("%s: missing %s on %s in %+v", test.status, code, path, discrepancies)
+			}
+		}
+		if got := hasBlockingDiscrepancy(discrepancies); got != test.blocking {
+			t.Errorf("%s: blocking = %v, want %v", test.status, got, test.blocking)
+		}
+	}
+}
+
+func TestReconcileFlightLogAgrees(t *testing.T) {
+	flight_log := types.FlightLogDTO{
+		TotalFlightDecimalTime: 1.5,
+		Missions:               []types.FlightLogMissionDTO{{TotalTimeDecimal: 1.5}},
+		Aircrew:                []types.FlightLogAircrewDTO{{TotalAircrewDurationDecimal: 1.5, TimeInstructor: 1.0, TimeOther: 0.5, CondNightTime: 1.5}},
+	}
+	for _, discrepancy := range reconcileFlightLog(flight_log, db.FlightLogStatusSubmitted) {
+		if discrepancy.Code != reconcileAirfield && discrepancy.Code != reconcileNightTime {
+			t.Errorf("unexpected discrepancy %+v", discrepancy)
+		}
+	}
+}
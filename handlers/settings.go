@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"flight_log_service/db"
+)
+
+// Rounding rules for decimal flight time.
+const (
+	RoundingTenths  = "tenths"
+	RoundingMinutes = "minutes"
+)
+
+// ServiceSettings holds the flight log specific options from the "service"
+// section of config.json. The platform types.Config does not know about
+// them, so they are read separately and kept for the life of the process.
+type ServiceSettings struct {
+	FlightTimes FlightTimeSettings  `json:"flight_times"`
+	Currency    []CurrencyRule      `json:"currency"`
+	Program     []ProgramAllocation `json:"flying_hour_program"`
+}
+
+// CurrencyRule requires Required units of Source within the last WindowDays
+// days. Source and When name a mission or aircrew field, e.g.
+// "missions.total_landings"; when When is set only flight logs where it is
+// positive count towards the rule.
+type CurrencyRule struct {
+	Event       string  `json:"event"`
+	Description string  `json:"description"`
+	Source      string  `json:"source"`
+	When        string  `json:"when"`
+	Required    float64 `json:"required"`
+	WindowDays  int     `json:"window_days"`
+}
+
+// ProgramAllocation is the flying-hour program a unit was allocated for one
+// fiscal year (named for the year it ends in). An empty MDS allocates the
+// unit as a whole rather than one aircraft type.
+type ProgramAllocation struct {
+	FiscalYear  int     `json:"fiscal_year"`
+	UnitCharged string  `json:"unit_charged"`
+	MDS         string  `json:"mds"`
+	Hours       float64 `json:"hours"`
+	Sorties     int     `json:"sorties"`
+}
+
+type FlightTimeSettings struct {
+	Rounding string `json:"rounding"`
+}
+
+var settings = ServiceSettings{
+	FlightTimes: FlightTimeSettings{
+		Rounding: RoundingTenths,
+	},
+}
+
+// LoadSettings reads the "service" section of the config file at path.
+// Missing keys keep their defaults.
+func LoadSettings(path string) error {
+	json_file, err := os.Open(path)
+	if err != nil {
+		log.Printf("Reading config file error: %s", err.Error())
+		return fmt.Errorf("could not read config file: %s", path)
+	}
+	defer json_file.Close()
+	bytes, err := io.ReadAll(json_file)
+	if err != nil {
+		return fmt.Errorf("could not read config file: %s", path)
+	}
+
+	loaded := struct {
+		Service ServiceSettings `json:"service"`
+	}{
+		Service: settings,
+	}
+	err = json.Unmarshal(bytes, &loaded)
+	if err != nil {
+		return fmt.Errorf("could not parse config file: %s", err.Error())
+	}
+	switch loaded.Service.FlightTimes.Rounding {
+	case RoundingTenths, RoundingMinutes:
+	default:
+		return fmt.Errorf("invalid flight_times.rounding: %s", loaded.Service.FlightTimes.Rounding)
+	}
+	events := map[string]bool{}
+	for _, rule := range loaded.Service.Currency {
+		switch {
+		case rule.Event == "" || events[rule.Event]:
+			return fmt.Errorf("invalid currency event: %q", rule.Event)
+		case !db.CurrencyFieldValid(rule.Source):
+			return fmt.Errorf("invalid currency source for %s: %s", rule.Event, rule.Source)
+		case rule.When != "" && !db.CurrencyFieldValid(rule.When):
+			return fmt.Errorf("invalid currency when for %s: %s", rule.Event, rule.When)
+		case rule.Required <= 0 || rule.WindowDays <= 0:
+			return fmt.Errorf("currency %s needs a positive required and window_days", rule.Event)
+		}
+		events[rule.Event] = true
+	}
+	allocations := map[string]bool{}
+	for _, allocation := range loaded.Service.Program {
+		key := fmt.Sprintf("%d/%s/%s", allocation.FiscalYear, allocation.UnitCharged, allocation.MDS)
+		switch {
+		case allocation.FiscalYear <= 0 || allocation.UnitCharged == "":
+			return fmt.Errorf("flying_hour_program entries need a fiscal_year and unit_charged")
+		case allocations[key]:
+			return fmt.Errorf("duplicate flying_hour_program entry: %s", key)
+		case allocation.Hours < 0 || allocation.Sorties < 0:
+			return fmt.Errorf("negative flying_hour_program allocation: %s", key)
+		}
+		allocations[key] = true
+	}
+	settings = loaded.Service
+	return nil
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"sort"
+	"strings"
+
+	"flight_log_service/db"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/thedanisaur/jfl_platform/types"
+	"github.com/thedanisaur/jfl_platform/util"
+)
+
+// signature_roles is the signing chain in order. Re-signing a role invalidates
+// its own signature and every role after it; editing the log invalidates all
+// of them.
+var signature_roles = []string{
+	"scheduler",
+	"instructor",
+	"student",
+	"training_officer",
+	"sarm",
+}
+
+func CreateFlightlogSignature(config types.Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		txid := c.Locals("transaction_id").(uuid.UUID)
+		log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(CreateFlightlogSignature))
+
+		user_id, err := uuid.Parse(c.Params("user_id"))
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString("invalid user")
+		}
+		flight_log_id, err := uuid.Parse(c.Params("flight_log_id"))
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString("invalid flight log")
+		}
+		role := c.Params("role")
+		downstream_roles := downstreamSignatureRoles(role)
+		if downstream_roles == nil {
+			return c.Status(fiber.StatusNotFound).SendString("invalid signature role")
+		}
+
+		/* Get the requesting user's info */
+		request_user := c.Locals("user_claims").(types.UserClaims)
+		if normalizeRoleName(request_user.RoleName) != role {
+			return c.Status(fiber.StatusForbidden).SendString("not authorized to sign as " + role)
+		}
+
+		/* Hold the version lock so the hashed content cannot change underneath us */
+		transaction, err := db.BeginTransaction(txid)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		defer transaction.Rollback()
+
+		version, err := db.LockFlightLogVersion(txid, transaction, flight_log_id)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		if ifMatchFailed(c, version) {
+			return c.Status(fiber.StatusPreconditionFailed).SendString("flight log has been modified")
+		}
+		status, err := db.LockFlightLogStatus(txid, transaction, flight_log_id)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		/* Signed and closed logs must go back to draft before anyone signs again */
+		if status != db.FlightLogStatusDraft && status != db.FlightLogStatusSubmitted {
+			return c.Status(fiber.StatusConflict).SendString("flight log can only be signed while draft or submitted")
+		}
+
+		flight_log, err := loadFlightLog(txid, user_id, flight_log_id)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		content_hash, err := flightLogContentHash(flight_log)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+
+		invalidated, err := db.InvalidateFlightLogSignatures(txid, transaction, flight_log_id, downstream_roles)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		signature_id, err := db.InsertFlightLogSignature(txid, transaction, flight_log_id, role, request_user, content_hash, version)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		version, err = db.IncrementFlightLogVersion(txid, transaction, flight_log_id)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		err = db.CommitTransaction(txid, transaction)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		c.Set(fiber.HeaderETag, formatETag(version))
+		response := fiber.Map{
+			"txid":                   txid.String(),
+			"flight_log_id":          flight_log_id,
+			"signature_id":           signature_id,
+			"role":                   role,
+			"content_hash":           content_hash,
+			"invalidated_signatures": invalidated,
+		}
+		return c.Status(fiber.StatusCreated).JSON(response)
+	}
+}
+
+func GetFlightlogSignatures(config types.Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		txid := c.Locals("transaction_id").(uuid.UUID)
+		log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(GetFlightlogSignatures))
+
+		flight_log_id, err := uuid.Parse(c.Params("flight_log_id"))
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString("invalid flight log")
+		}
+
+		signatures, err := db.GetFlightLogSignatures(txid, flight_log_id)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+
+		response := fiber.Map{
+			"txid":          txid.String(),
+			"flight_log_id": flight_log_id,
+			"signatures":    signatures,
+		}
+		return c.Status(fiber.StatusOK).JSON(response)
+	}
+}
+
+// downstreamSignatureRoles returns role and every role after it in the signing
+// chain, or nil when role is not a signing role.
+func downstreamSignatureRoles(role string) []string {
+	for i, signature_role := range signature_roles {
+		if signature_role == role {
+			return signature_roles[i:]
+		}
+	}
+	return nil
+}
+
+// flightLogContentHash is a SHA-256 over the signed content of a flight log:
+// the header, missions and aircrew. Signatures and comments are excluded so
+// signing and commenting do not change the hash, and children are ordered by
+// id so the hash does not depend on row order.
+func flightLogContentHash(flight_log types.FlightLogDTO) (string, error) {
+	flight_log.SchedulerSignatureID = uuid.Nil
+	flight_log.SarmSignatureID = uuid.Nil
+	flight_log.InstructorSignatureID = uuid.Nil
+	flight_log.StudentSignatureID = uuid.Nil
+	flight_log.TrainingOfficerSignatureID = uuid.Nil
+	flight_log.Comments = nil
+
+	missions := append([]types.FlightLogMissionDTO{}, flight_log.Missions...)
+	sort.Slice(missions, func(i, j int) bool {
+		return missions[i].ID.String() < missions[j].ID.String()
+	})
+	flight_log.Missions = missions
+	aircrew := append([]types.FlightLogAircrewDTO{}, flight_log.Aircrew...)
+	sort.Slice(aircrew, func(i, j int) bool {
+		return aircrew[i].ID.String() < aircrew[j].ID.String()
+	})
+	flight_log.Aircrew = aircrew
+
+	content, err := json.Marshal(flight_log)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// normalizeRoleName maps a platform role name such as "Training Officer" onto
+// the signing role form used in the URL ("training_officer").
+func normalizeRoleName(role_name string) string {
+	role_name = strings.ToLower(strings.TrimSpace(role_name))
+	role_name = strings.ReplaceAll(role_name, " ", "_")
+	return strings.ReplaceAll(role_name, "-", "_")
+}
@@ -3,6 +3,8 @@ package handlers
 import (
 	"fmt"
 	"log"
+	"strings"
+	"time"
 
 	"flight_log_service/db"
 
@@ -23,19 +25,122 @@ func CreateTemplateFlightlog(config types.Config) fiber.Handler {
 			log.Printf("Failed to parse template flight log data\n%s\n", err.Error())
 			return c.Status(fiber.StatusBadRequest).SendString(fmt.Sprintf("Failed to parse template flight log data: %s\n", txid.String()))
 		}
+		normalizeMissionAirfields(template_flight_log.Missions)
 		/* Get the requesting user */
 		request_user := c.Locals("user_claims").(types.UserClaims)
 
-		/* Now start inserting the flight log */
-		template_flight_log.ID, err = db.InsertTemplateFlightLog(txid, request_user.UserID, template_flight_log)
+		/* Now start inserting the flight log, everything commits or nothing does */
+		transaction, err := db.BeginTransaction(txid)
 		if err != nil {
 			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
 		}
-		mission_ids, err := db.InsertTemplateMissions(txid, template_flight_log)
+		defer transaction.Rollback()
+
+		template_flight_log.ID, err = db.InsertTemplateFlightLog(txid, transaction, request_user.UserID, template_flight_log)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		mission_ids, err := db.InsertTemplateMissions(txid, transaction, template_flight_log)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		aircrew_ids, err := db.InsertTemplateAircrews(txid, transaction, template_flight_log)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		version, err := db.LockTemplateFlightLogVersion(txid, transaction, template_flight_log.ID)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		err = db.RecordTemplateRevision(txid, transaction, request_user.UserID, template_flight_log.ID, version, &request_user.UserID)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		err = db.CommitTransaction(txid, transaction)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		response := fiber.Map{
+			"txid":                   txid.String(),
+			"template_flight_log_id": template_flight_log.ID.String(),
+			"template_mission_ids":   mission_ids,
+			"template_aircrew_ids":   aircrew_ids,
+		}
+		return c.Status(fiber.StatusOK).JSON(response)
+	}
+}
+
+func CreateTemplateFromFlightlog(config types.Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		txid := c.Locals("transaction_id").(uuid.UUID)
+		log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(CreateTemplateFromFlightlog))
+
+		user_id, err := uuid.Parse(c.Params("user_id"))
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString("invalid user")
+		}
+		flight_log_id, err := uuid.Parse(c.Params("flight_log_id"))
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString("invalid flight log")
+		}
+
+		var request struct {
+			Name string `json:"name"`
+		}
+		err = c.BodyParser(&request)
+		if err != nil {
+			log.Printf("Failed to parse template name\n%s\n", err.Error())
+			return c.Status(fiber.StatusBadRequest).SendString(fmt.Sprintf("Failed to parse template name: %s\n", txid.String()))
+		}
+		request.Name = strings.TrimSpace(request.Name)
+		if request.Name == "" {
+			return validationFailed(c, txid, []FieldError{{Path: "name", Code: validationRequired, Message: "is required"}})
+		}
+
+		/* The route admits reading the log; making the template needs templates/create too */
+		request_user := c.Locals("user_claims").(types.UserClaims)
+		allowed, err := authorizeCreate(txid, request_user, "templates")
+		if err != nil {
+			return c.Status(fiber.StatusForbidden).SendString(err.Error())
+		}
+		if !allowed {
+			return c.Status(fiber.StatusForbidden).SendString("not authorized")
+		}
+
+		flight_log, err := loadFlightLog(txid, user_id, flight_log_id)
 		if err != nil {
 			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
 		}
-		aircrew_ids, err := db.InsertTemplateAircrews(txid, template_flight_log)
+		template_flight_log := templateFromFlightLog(request.Name, flight_log)
+
+		/* Now start inserting the template, everything commits or nothing does */
+		transaction, err := db.BeginTransaction(txid)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		defer transaction.Rollback()
+
+		template_flight_log.ID, err = db.InsertTemplateFlightLog(txid, transaction, request_user.UserID, template_flight_log)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		mission_ids, err := db.InsertTemplateMissions(txid, transaction, template_flight_log)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		aircrew_ids, err := db.InsertTemplateAircrews(txid, transaction, template_flight_log)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		version, err := db.LockTemplateFlightLogVersion(txid, transaction, template_flight_log.ID)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		err = db.RecordTemplateRevision(txid, transaction, request_user.UserID, template_flight_log.ID, version, &request_user.UserID)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		err = db.CommitTransaction(txid, transaction)
 		if err != nil {
 			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
 		}
@@ -63,7 +168,32 @@ func DeleteTemplateFlightlog(config types.Config) fiber.Handler {
 			return c.Status(fiber.StatusServiceUnavailable).SendString("invalid template flight log")
 		}
 
-		flight_log, err := db.DeleteTemplateFlightlog(txid, user_id, template_id)
+		transaction, err := db.BeginTransaction(txid)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		defer transaction.Rollback()
+
+		version, err := db.LockTemplateFlightLogVersion(txid, transaction, template_id)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		if ifMatchFailed(c, version) {
+			return c.Status(fiber.StatusPreconditionFailed).SendString("template flight log has been modified")
+		}
+		allowed, err := authorizeTemplateEdit(txid, c.Locals("user_claims").(types.UserClaims), user_id, template_id)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		if !allowed {
+			return c.Status(fiber.StatusForbidden).SendString("shared template flight log can only be changed by authorized roles")
+		}
+
+		flight_log, err := db.DeleteTemplateFlightlog(txid, transaction, user_id, template_id)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		err = db.CommitTransaction(txid, transaction)
 		if err != nil {
 			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
 		}
@@ -90,6 +220,15 @@ func GetTemplateFlightlog(config types.Config) fiber.Handler {
 			return c.Status(fiber.StatusServiceUnavailable).SendString("invalid flight log")
 		}
 
+		version, err := db.GetTemplateFlightLogVersion(txid, user_id, template_id)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		c.Set(fiber.HeaderETag, formatETag(version))
+		if ifNoneMatchHit(c, version) {
+			return c.SendStatus(fiber.StatusNotModified)
+		}
+
 		flight_log, err := db.GetTemplateFlightlog(txid, user_id, template_id)
 		if err != nil {
 			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
@@ -105,11 +244,16 @@ func GetTemplateFlightlog(config types.Config) fiber.Handler {
 			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
 		}
 
+		sharing, err := db.GetTemplateFlightLogSharing(txid, template_id)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+
 		// response := fiber.Map{
 		// 	"txid": txid.String(),
 		// }
 
-		return c.Status(fiber.StatusOK).JSON(flight_log)
+		return c.Status(fiber.StatusOK).JSON(db.SharedTemplateFlightLogDTO{TemplateFlightLogDTO: flight_log, Sharing: sharing})
 	}
 }
 
@@ -123,7 +267,11 @@ func GetTemplateFlightlogs(config types.Config) fiber.Handler {
 			return c.Status(fiber.StatusServiceUnavailable).SendString("invalid user")
 		}
 
-		template_flight_logs, err := db.GetTemplateFlightlogs(txid, user_id)
+		/* Scoped by AuthorizationMiddleware */
+		where_clause := c.Locals("authorization_where_clause").(string)
+		arguments := c.Locals("authorization_arguments").([]interface{})
+
+		template_flight_logs, err := db.GetTemplateFlightlogs(txid, user_id, where_clause, arguments)
 		if err != nil {
 			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
 		}
@@ -148,40 +296,457 @@ func GetTemplateFlightlogs(config types.Config) fiber.Handler {
 	}
 }
 
+func GetTemplateFlightlogsAll(config types.Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		txid := c.Locals("transaction_id").(uuid.UUID)
+		log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(GetTemplateFlightlogsAll))
+
+		/* Scoped by AuthorizationMiddleware; shared templates of other owners come through the policies */
+		where_clause := c.Locals("authorization_where_clause").(string)
+		arguments := c.Locals("authorization_arguments").([]interface{})
+
+		/* Get the requesting user */
+		request_user := c.Locals("user_claims").(types.UserClaims)
+
+		template_flight_logs, err := db.GetTemplateFlightlogsAll(txid, request_user.UserID, where_clause, arguments)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		return c.Status(fiber.StatusOK).JSON(template_flight_logs)
+	}
+}
+
+func InstantiateTemplateFlightlog(config types.Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		txid := c.Locals("transaction_id").(uuid.UUID)
+		log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(InstantiateTemplateFlightlog))
+
+		user_id, err := uuid.Parse(c.Params("user_id"))
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString("invalid user")
+		}
+		template_id, err := uuid.Parse(c.Params("template_id"))
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString("invalid template flight log")
+		}
+
+		/* The body is optional; without one the template is copied as is, which needs stored mission times */
+		var overrides templateInstantiation
+		if len(c.Body()) > 0 {
+			err = c.BodyParser(&overrides)
+			if err != nil {
+				log.Printf("Failed to parse template instantiation\n%s\n", err.Error())
+				return c.Status(fiber.StatusBadRequest).SendString(fmt.Sprintf("Failed to parse template instantiation: %s\n", txid.String()))
+			}
+		}
+
+		/* The route is checked as templates/instantiate, but it creates a flight log */
+		request_user := c.Locals("user_claims").(types.UserClaims)
+		allowed, err := authorizeCreate(txid, request_user, "flight-logs")
+		if err != nil {
+			return c.Status(fiber.StatusForbidden).SendString(err.Error())
+		}
+		if !allowed {
+			return c.Status(fiber.StatusForbidden).SendString("not authorized to create flight logs")
+		}
+
+		transaction, err := db.BeginTransaction(txid)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		defer transaction.Rollback()
+
+		/* Holding the version lock keeps template writers out until the copy commits */
+		version, err := db.LockTemplateFlightLogVersion(txid, transaction, template_id)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		/* Templates from before versioning get their current state recorded, so source_template.version resolves */
+		err = db.RecordTemplateRevision(txid, transaction, user_id, template_id, version, nil)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		template_flight_log, err := db.GetTemplateFlightlog(txid, user_id, template_id)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		template_flight_log.Missions, err = db.GetTemplateMissions(txid, template_id)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		template_flight_log.Aircrew, err = db.GetTemplateAirCrews(txid, template_id)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+
+		flight_log, field_errors := instantiateTemplate(template_flight_log, overrides)
+		if len(field_errors) > 0 {
+			return validationFailed(c, txid, field_errors)
+		}
+		field_errors = prepareFlightLog(&flight_log)
+		if len(field_errors) > 0 {
+			return validationFailed(c, txid, field_errors)
+		}
+		created, err := insertFlightLogGraph(txid, transaction, request_user.UserID, &flight_log)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		source := db.FlightLogSourceTemplateDTO{TemplateID: template_id, Version: version}
+		err = db.SetFlightLogSourceTemplate(txid, transaction, flight_log.ID, source)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		err = db.CommitTransaction(txid, transaction)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		response := fiber.Map{
+			"txid":            txid.String(),
+			"flight_log_id":   flight_log.ID.String(),
+			"mission_ids":     created.MissionIDs,
+			"aircrew_ids":     created.AircrewIDs,
+			"source_template": source,
+			"reconciliation":  reconcileFlightLog(flight_log, db.FlightLogStatusDraft),
+		}
+		return c.Status(fiber.StatusOK).JSON(response)
+	}
+}
+
+func PatchTemplateFlightlog(config types.Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		txid := c.Locals("transaction_id").(uuid.UUID)
+		log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(PatchTemplateFlightlog))
+
+		user_id, err := uuid.Parse(c.Params("user_id"))
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString("invalid user")
+		}
+		template_id, err := uuid.Parse(c.Params("template_id"))
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString("invalid template flight log")
+		}
+
+		var patch db.TemplateFlightLogPatchDTO
+		err = c.BodyParser(&patch)
+		if err != nil {
+			log.Printf("Failed to parse template flight log patch\n%s\n", err.Error())
+			return c.Status(fiber.StatusBadRequest).SendString(fmt.Sprintf("Failed to parse template flight log patch: %s\n", txid.String()))
+		}
+		normalizeMissionPatchAirfields(patch.Missions)
+
+		/* Only the columns present in the patch are written */
+		transaction, err := db.BeginTransaction(txid)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		defer transaction.Rollback()
+
+		version, err := db.LockTemplateFlightLogVersion(txid, transaction, template_id)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		if ifMatchFailed(c, version) {
+			return c.Status(fiber.StatusPreconditionFailed).SendString("template flight log has been modified")
+		}
+		allowed, err := authorizeTemplateEdit(txid, c.Locals("user_claims").(types.UserClaims), user_id, template_id)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		if !allowed {
+			return c.Status(fiber.StatusForbidden).SendString("shared template flight log can only be changed by authorized roles")
+		}
+		/* Templates from before versioning get their current state recorded first */
+		err = db.RecordTemplateRevision(txid, transaction, user_id, template_id, version, nil)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+
+		_, err = db.PatchTemplateFlightLog(txid, transaction, user_id, template_id, patch)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		mission_ids, err := db.PatchTemplateMissions(txid, transaction, template_id, patch)
+		if err != nil {
+			return childWriteFailed(c, txid, err)
+		}
+		aircrew_ids, err := db.PatchTemplateAircrews(txid, transaction, template_id, patch)
+		if err != nil {
+			return childWriteFailed(c, txid, err)
+		}
+		version, err = db.IncrementTemplateFlightLogVersion(txid, transaction, template_id)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		request_user := c.Locals("user_claims").(types.UserClaims)
+		err = db.RecordTemplateRevision(txid, transaction, user_id, template_id, version, &request_user.UserID)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		err = db.CommitTransaction(txid, transaction)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		c.Set(fiber.HeaderETag, formatETag(version))
+		response := fiber.Map{
+			"txid":                   txid.String(),
+			"template_flight_log_id": template_id,
+			"template_mission_ids":   mission_ids,
+			"template_aircrew_ids":   aircrew_ids,
+		}
+		return c.Status(fiber.StatusOK).JSON(response)
+	}
+}
+
 func UpdateTemplateFlightlog(config types.Config) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		txid := c.Locals("transaction_id").(uuid.UUID)
 		log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(UpdateTemplateFlightlog))
 
+		user_id, err := uuid.Parse(c.Params("user_id"))
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString("invalid user")
+		}
+		template_id, err := uuid.Parse(c.Params("template_id"))
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString("invalid template flight log")
+		}
+
 		var template_flight_log types.TemplateFlightLogDTO
-		err := c.BodyParser(&template_flight_log)
+		err = c.BodyParser(&template_flight_log)
 		if err != nil {
 			log.Printf("Failed to parse template flight log data\n%s\n", err.Error())
 			return c.Status(fiber.StatusBadRequest).SendString(fmt.Sprintf("Failed to parse template flight log data: %s\n", txid.String()))
 		}
-		// TODO [drd] validate that this action is allowed.
-		/* Get the requesting user */
-		// request_user := c.Locals("user_claims").(types.UserClaims)
+		normalizeMissionAirfields(template_flight_log.Missions)
+		/* Now update the flight log, everything commits or nothing does */
+		transaction, err := db.BeginTransaction(txid)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		defer transaction.Rollback()
 
-		/* Now update the flight log */
-		template_id, err := db.UpdateTemplateFlightLog(txid, template_flight_log)
+		version, err := db.LockTemplateFlightLogVersion(txid, transaction, template_id)
 		if err != nil {
 			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
 		}
-		mission_ids, err := db.UpdateTemplateMissions(txid, template_flight_log)
+		if ifMatchFailed(c, version) {
+			return c.Status(fiber.StatusPreconditionFailed).SendString("template flight log has been modified")
+		}
+		allowed, err := authorizeTemplateEdit(txid, c.Locals("user_claims").(types.UserClaims), user_id, template_id)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		if !allowed {
+			return c.Status(fiber.StatusForbidden).SendString("shared template flight log can only be changed by authorized roles")
+		}
+		/* Templates from before versioning get their current state recorded first */
+		err = db.RecordTemplateRevision(txid, transaction, user_id, template_id, version, nil)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+
+		template_flight_log.ID = template_id
+		_, err = db.UpdateTemplateFlightLog(txid, transaction, template_flight_log)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		mission_changes, err := db.UpdateTemplateMissions(txid, transaction, template_flight_log)
+		if err != nil {
+			return childWriteFailed(c, txid, err)
+		}
+		aircrew_changes, err := db.UpdateTemplateAircrews(txid, transaction, template_flight_log)
+		if err != nil {
+			return childWriteFailed(c, txid, err)
+		}
+		version, err = db.IncrementTemplateFlightLogVersion(txid, transaction, template_id)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		request_user := c.Locals("user_claims").(types.UserClaims)
+		err = db.RecordTemplateRevision(txid, transaction, user_id, template_id, version, &request_user.UserID)
 		if err != nil {
 			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
 		}
-		aircrew_ids, err := db.UpdateTemplateAircrews(txid, template_flight_log)
+		err = db.CommitTransaction(txid, transaction)
 		if err != nil {
 			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
 		}
+		c.Set(fiber.HeaderETag, formatETag(version))
 		response := fiber.Map{
 			"txid":                   txid.String(),
 			"template_flight_log_id": template_id,
-			"template_mission_ids":   mission_ids,
-			"template_aircrew_ids":   aircrew_ids,
+			"template_missions":      mission_changes,
+			"template_aircrew":       aircrew_changes,
 		}
 		return c.Status(fiber.StatusOK).JSON(response)
 	}
 }
+
+// templateInstantiation is the optional body of an instantiate request. Every
+// field is an override; anything left out is copied from the template.
+type templateInstantiation struct {
+	FlightLogDate       string                         `json:"flight_log_date"`
+	SerialNumber        *string                        `json:"serial_number"`
+	UnitCharged         *string                        `json:"unit_charged"`
+	HarmLocation        *string                        `json:"harm_location"`
+	FlightAuthorization *string                        `json:"flight_authorization"`
+	IssuingUnit         *string                        `json:"issuing_unit"`
+	Remarks             *string                        `json:"remarks"`
+	Missions            []templateInstantiationMission `json:"missions"`
+	Aircrew             []templateInstantiationAircrew `json:"aircrew"`
+}
+
+// templateInstantiationMission sets the takeoff and land times of the template
+// mission row ID, which templates saved from a flight log leave empty.
+type templateInstantiationMission struct {
+	ID          uuid.UUID  `json:"id"`
+	TakeoffTime *time.Time `json:"takeoff_time"`
+	LandTime    *time.Time `json:"land_time"`
+}
+
+// templateInstantiationAircrew puts a different crew member in the seat of
+// the template aircrew row ID.
+type templateInstantiationAircrew struct {
+	ID     uuid.UUID `json:"id"`
+	UserID uuid.UUID `json:"user_id"`
+}
+
+// instantiateTemplate copies a template into a new, unsaved flight log and
+// applies the overrides. Moving the date moves every mission by the same
+// number of days so takeoff and land times stay on the new date; explicit
+// mission times are taken as sent. A mission left with no takeoff or land
+// time, as in templates saved from a flight log, is an error naming the
+// template mission that needs an override.
+func instantiateTemplate(template_flight_log types.TemplateFlightLogDTO, overrides templateInstantiation) (types.FlightLogDTO, []FieldError) {
+	var errs fieldErrors
+	flight_log := types.FlightLogDTO{
+		MDS:                    template_flight_log.MDS,
+		FlightLogDate:          template_flight_log.FlightLogDate,
+		SerialNumber:           template_flight_log.SerialNumber,
+		UnitCharged:            template_flight_log.UnitCharged,
+		HarmLocation:           template_flight_log.HarmLocation,
+		FlightAuthorization:    template_flight_log.FlightAuthorization,
+		IssuingUnit:            template_flight_log.IssuingUnit,
+		IsTrainingFlight:       template_flight_log.IsTrainingFlight,
+		IsTrainingOnly:         template_flight_log.IsTrainingOnly,
+		TotalFlightDecimalTime: template_flight_log.TotalFlightDecimalTime,
+		Type:                   template_flight_log.Type,
+		Remarks:                template_flight_log.Remarks,
+	}
+
+	var shift time.Duration
+	if overrides.FlightLogDate != "" {
+		date, err := parseQueryDate(overrides.FlightLogDate)
+		if err != nil {
+			errs.add("flight_log_date", validationInvalid, "must be an RFC 3339 timestamp or a YYYY-MM-DD date")
+		} else {
+			from := template_flight_log.FlightLogDate
+			shift = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC).Sub(time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC))
+			flight_log.FlightLogDate = date
+		}
+	}
+	for _, override := range []struct {
+		value *string
+		field *string
+	}{
+		{overrides.SerialNumber, &flight_log.SerialNumber},
+		{overrides.UnitCharged, &flight_log.UnitCharged},
+		{overrides.HarmLocation, &flight_log.HarmLocation},
+		{overrides.FlightAuthorization, &flight_log.FlightAuthorization},
+		{overrides.IssuingUnit, &flight_log.IssuingUnit},
+		{overrides.Remarks, &flight_log.Remarks},
+	} {
+		if override.value != nil {
+			*override.field = *override.value
+		}
+	}
+
+	mission_times := map[uuid.UUID]templateInstantiationMission{}
+	for _, mission := range template_flight_log.Missions {
+		mission_times[mission.ID] = templateInstantiationMission{}
+	}
+	for i, override := range overrides.Missions {
+		_, ok := mission_times[override.ID]
+		if !ok {
+			errs.add(fmt.Sprintf("missions[%d].id", i), validationInvalid, "is not a mission row of this template")
+			continue
+		}
+		mission_times[override.ID] = override
+	}
+	flight_log.Missions = make([]types.FlightLogMissionDTO, 0, len(template_flight_log.Missions))
+	for _, mission := range template_flight_log.Missions {
+		override := mission_times[mission.ID]
+		template_mission_id := mission.ID
+		mission.ID = uuid.Nil
+		mission.FlightLogID = uuid.Nil
+		if !mission.TakeoffTime.IsZero() {
+			mission.TakeoffTime = mission.TakeoffTime.Add(shift)
+		}
+		if !mission.LandTime.IsZero() {
+			mission.LandTime = mission.LandTime.Add(shift)
+		}
+		if override.TakeoffTime != nil {
+			mission.TakeoffTime = *override.TakeoffTime
+		}
+		if override.LandTime != nil {
+			mission.LandTime = *override.LandTime
+		}
+		if mission.TakeoffTime.IsZero() {
+			errs.add(fmt.Sprintf("missions[%d].takeoff_time", len(flight_log.Missions)), validationRequired, fmt.Sprintf("template mission %s has no takeoff time; send one in missions", template_mission_id))
+		}
+		if mission.LandTime.IsZero() {
+			errs.add(fmt.Sprintf("missions[%d].land_time", len(flight_log.Missions)), validationRequired, fmt.Sprintf("template mission %s has no land time; send one in missions", template_mission_id))
+		}
+		flight_log.Missions = append(flight_log.Missions, mission)
+	}
+
+	crew_members := map[uuid.UUID]uuid.UUID{}
+	for _, aircrew := range template_flight_log.Aircrew {
+		crew_members[aircrew.ID] = aircrew.UserID
+	}
+	for i, override := range overrides.Aircrew {
+		_, ok := crew_members[override.ID]
+		if !ok {
+			errs.add(fmt.Sprintf("aircrew[%d].id", i), validationInvalid, "is not an aircrew row of this template")
+			continue
+		}
+		crew_members[override.ID] = override.UserID
+	}
+	flight_log.Aircrew = make([]types.FlightLogAircrewDTO, 0, len(template_flight_log.Aircrew))
+	for _, aircrew := range template_flight_log.Aircrew {
+		aircrew.UserID = crew_members[aircrew.ID]
+		aircrew.ID = uuid.Nil
+		aircrew.FlightLogID = uuid.Nil
+		flight_log.Aircrew = append(flight_log.Aircrew, aircrew)
+	}
+	return flight_log, errs
+}
+
+// templateFromFlightLog captures a flown log as a reusable profile. The
+// header, missions and aircrew carry over; signatures, comments and the actual
+// takeoff and land times do not. Mission durations stay as the planned time.
+func templateFromFlightLog(name string, flight_log types.FlightLogDTO) types.TemplateFlightLogDTO {
+	template_flight_log := types.TemplateFlightLogDTO{
+		Name:                   name,
+		MDS:                    flight_log.MDS,
+		FlightLogDate:          flight_log.FlightLogDate,
+		SerialNumber:           flight_log.SerialNumber,
+		UnitCharged:            flight_log.UnitCharged,
+		HarmLocation:           flight_log.HarmLocation,
+		FlightAuthorization:    flight_log.FlightAuthorization,
+		IssuingUnit:            flight_log.IssuingUnit,
+		IsTrainingFlight:       flight_log.IsTrainingFlight,
+		IsTrainingOnly:         flight_log.IsTrainingOnly,
+		TotalFlightDecimalTime: flight_log.TotalFlightDecimalTime,
+		Type:                   flight_log.Type,
+		Remarks:                flight_log.Remarks,
+	}
+	template_flight_log.Missions = make([]types.FlightLogMissionDTO, 0, len(flight_log.Missions))
+	for _, mission := range flight_log.Missions {
+		mission.TakeoffTime = time.Time{}
+		mission.LandTime = time.Time{}
+		template_flight_log.Missions = append(template_flight_log.Missions, mission)
+	}
+	template_flight_log.Aircrew = append([]types.FlightLogAircrewDTO{}, flight_log.Aircrew...)
+	return template_flight_log
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/thedanisaur/jfl_platform/types"
+)
+
+func TestInstantiateTemplateShiftsMissionTimes(t *testing.T) {
+	takeoff := time.Date(2024, 5, 1, 14, 0, 0, 0, time.UTC)
+	template_flight_log := types.TemplateFlightLogDTO{
+		FlightLogDate: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+		Missions: []types.FlightLogMissionDTO{
+			{ID: uuid.New(), TakeoffTime: takeoff, LandTime: takeoff.Add(90 * time.Minute)},
+		},
+	}
+	flight_log, errs := instantiateTemplate(template_flight_log, templateInstantiation{FlightLogDate: "2024-05-03"})
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %+v", errs)
+	}
+	mission := flight_log.Missions[0]
+	if mission.ID != uuid.Nil {
+		t.Errorf("mission kept its template id")
+	}
+	if want := takeoff.AddDate(0, 0, 2); !mission.TakeoffTime.Equal(want) {
+		t.Errorf("takeoff_time = %s, want %s", mission.TakeoffTime, want)
+	}
+}
+
+func TestInstantiateTemplateNeedsMissionTimes(t *testing.T) {
+	mission_id := uuid.New()
+	template_flight_log := types.TemplateFlightLogDTO{
+		Missions: []types.FlightLogMissionDTO{{ID: mission_id}},
+	}
+	_, errs := instantiateTemplate(template_flight_log, templateInstantiation{})
+	if len(errs) != 2 || errs[0].Path != "missions[0].takeoff_time" || errs[1].Path != "missions[0].land_time" {
+		t.Fatalf("errors = %+v, want takeoff_time and land_time required", errs)
+	}
+
+	takeoff := time.Date(2024, 5, 1, 14, 0, 0, 0, time.UTC)
+	land := takeoff.Add(time.Hour)
+	overrides := templateInstantiation{
+		Missions: []templateInstantiationMission{{ID: mission_id, TakeoffTime: &takeoff, LandTime: &land}},
+	}
+	flight_log, errs := instantiateTemplate(template_flight_log, overrides)
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %+v", errs)
+	}
+	if !flight_log.Missions[0].LandTime.Equal(land) {
+		t.Errorf("land_time = %s, want %s", flight_log.Missions[0].LandTime, land)
+	}
+}
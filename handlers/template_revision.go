@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"strconv"
+
+	"flight_log_service/db"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/thedanisaur/jfl_platform/types"
+	"github.com/thedanisaur/jfl_platform/util"
+)
+
+func GetTemplateFlightlogRevision(config types.Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		txid := c.Locals("transaction_id").(uuid.UUID)
+		log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(GetTemplateFlightlogRevision))
+
+		template_id, err := uuid.Parse(c.Params("template_id"))
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString("invalid template flight log")
+		}
+		version, err := strconv.Atoi(c.Params("version"))
+		if err != nil || version < 1 {
+			return c.Status(fiber.StatusBadRequest).SendString("invalid version")
+		}
+
+		revision, err := db.GetTemplateRevision(txid, template_id, version)
+		if errors.Is(err, db.ErrTemplateRevisionNotFound) {
+			return c.Status(fiber.StatusNotFound).SendString(err.Error())
+		}
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		/* A version never changes, so its number is a strong validator */
+		c.Set(fiber.HeaderETag, formatETag(version))
+		if ifNoneMatchHit(c, version) {
+			return c.SendStatus(fiber.StatusNotModified)
+		}
+		return c.Status(fiber.StatusOK).JSON(revision)
+	}
+}
+
+func GetTemplateFlightlogRevisions(config types.Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		txid := c.Locals("transaction_id").(uuid.UUID)
+		log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(GetTemplateFlightlogRevisions))
+
+		template_id, err := uuid.Parse(c.Params("template_id"))
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString("invalid template flight log")
+		}
+
+		revisions, err := db.GetTemplateRevisions(txid, template_id)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+
+		response := fiber.Map{
+			"txid":     txid.String(),
+			"versions": revisions,
+		}
+		return c.Status(fiber.StatusOK).JSON(response)
+	}
+}
+
+func RestoreTemplateFlightlogRevision(config types.Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		txid := c.Locals("transaction_id").(uuid.UUID)
+		log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(RestoreTemplateFlightlogRevision))
+
+		user_id, err := uuid.Parse(c.Params("user_id"))
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString("invalid user")
+		}
+		template_id, err := uuid.Parse(c.Params("template_id"))
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString("invalid template flight log")
+		}
+		restore_version, err := strconv.Atoi(c.Params("version"))
+		if err != nil || restore_version < 1 {
+			return c.Status(fiber.StatusBadRequest).SendString("invalid version")
+		}
+
+		revision, err := db.GetTemplateRevision(txid, template_id, restore_version)
+		if errors.Is(err, db.ErrTemplateRevisionNotFound) {
+			return c.Status(fiber.StatusNotFound).SendString(err.Error())
+		}
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+
+		/* A restore is an ordinary update that writes the old content as a new version */
+		transaction, err := db.BeginTransaction(txid)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		defer transaction.Rollback()
+
+		version, err := db.LockTemplateFlightLogVersion(txid, transaction, template_id)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		if ifMatchFailed(c, version) {
+			return c.Status(fiber.StatusPreconditionFailed).SendString("template flight log has been modified")
+		}
+		/* Get the requesting user */
+		request_user := c.Locals("user_claims").(types.UserClaims)
+		allowed, err := authorizeTemplateEdit(txid, request_user, user_id, template_id)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		if !allowed {
+			return c.Status(fiber.StatusForbidden).SendString("shared template flight log can only be changed by authorized roles")
+		}
+		/* Templates from before versioning get their current state recorded first */
+		err = db.RecordTemplateRevision(txid, transaction, user_id, template_id, version, nil)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+
+		/* Revisions from before sharing was recorded have no scope and leave it as it is */
+		sharing := revision.Template.Sharing
+		if sharing.Scope != "" {
+			current_sharing, err := db.GetTemplateFlightLogSharing(txid, template_id)
+			if err != nil {
+				return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+			}
+			if sharing != current_sharing {
+				allowed, err := authorizeTemplateShare(txid, request_user, user_id, template_id)
+				if err != nil {
+					return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+				}
+				if !allowed {
+					return c.Status(fiber.StatusForbidden).SendString("restoring this version changes sharing, which needs a share policy")
+				}
+				err = db.UpdateTemplateFlightLogSharing(txid, transaction, template_id, sharing)
+				if err != nil {
+					return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+				}
+			}
+		}
+
+		current, err := db.ReadTemplateFlightLog(txid, transaction, user_id, template_id)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		template_flight_log := restorableTemplate(revision.Template.TemplateFlightLogDTO, current)
+		template_flight_log.ID = template_id
+		_, err = db.UpdateTemplateFlightLog(txid, transaction, template_flight_log)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		mission_changes, err := db.UpdateTemplateMissions(txid, transaction, template_flight_log)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		aircrew_changes, err := db.UpdateTemplateAircrews(txid, transaction, template_flight_log)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		version, err = db.IncrementTemplateFlightLogVersion(txid, transaction, template_id)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		err = db.RecordTemplateRevision(txid, transaction, user_id, template_id, version, &request_user.UserID)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		err = db.CommitTransaction(txid, transaction)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		c.Set(fiber.HeaderETag, formatETag(version))
+		response := fiber.Map{
+			"txid":                   txid.String(),
+			"template_flight_log_id": template_id,
+			"restored_version":       restore_version,
+			"version":                version,
+			"template_missions":      mission_changes,
+			"template_aircrew":       aircrew_changes,
+		}
+		return c.Status(fiber.StatusOK).JSON(response)
+	}
+}
+
+// restorableTemplate prepares an old revision to be written back over the
+// current template. Missions and aircrew that still exist keep their ids and
+// are updated in place; ones deleted since that version are inserted again
+// under new ids.
+func restorableTemplate(revision types.TemplateFlightLogDTO, current types.TemplateFlightLogDTO) types.TemplateFlightLogDTO {
+	mission_ids := map[uuid.UUID]bool{}
+	for _, mission := range current.Missions {
+		mission_ids[mission.ID] = true
+	}
+	aircrew_ids := map[uuid.UUID]bool{}
+	for _, aircrew := range current.Aircrew {
+		aircrew_ids[aircrew.ID] = true
+	}
+	missions := make([]types.FlightLogMissionDTO, 0, len(revision.Missions))
+	for _, mission := range revision.Missions {
+		if !mission_ids[mission.ID] {
+			mission.ID = uuid.Nil
+		}
+		missions = append(missions, mission)
+	}
+	aircrews := make([]types.FlightLogAircrewDTO, 0, len(revision.Aircrew))
+	for _, aircrew := range revision.Aircrew {
+		if !aircrew_ids[aircrew.ID] {
+			aircrew.ID = uuid.Nil
+		}
+		aircrews = append(aircrews, aircrew)
+	}
+	revision.Missions = missions
+	revision.Aircrew = aircrews
+	return revision
+}
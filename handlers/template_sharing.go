@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"flight_log_service/db"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/thedanisaur/jfl_platform/types"
+	"github.com/thedanisaur/jfl_platform/util"
+)
+
+func UpdateTemplateFlightlogSharing(config types.Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		txid := c.Locals("transaction_id").(uuid.UUID)
+		log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(UpdateTemplateFlightlogSharing))
+
+		user_id, err := uuid.Parse(c.Params("user_id"))
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString("invalid user")
+		}
+		template_id, err := uuid.Parse(c.Params("template_id"))
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString("invalid template flight log")
+		}
+
+		var sharing db.TemplateSharingDTO
+		err = c.BodyParser(&sharing)
+		if err != nil {
+			log.Printf("Failed to parse template sharing\n%s\n", err.Error())
+			return c.Status(fiber.StatusBadRequest).SendString(fmt.Sprintf("Failed to parse template sharing: %s\n", txid.String()))
+		}
+		field_errors := validateTemplateSharing(&sharing)
+		if len(field_errors) > 0 {
+			return validationFailed(c, txid, field_errors)
+		}
+
+		/* The templates/share policy has already admitted this row */
+		transaction, err := db.BeginTransaction(txid)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		defer transaction.Rollback()
+
+		version, err := db.LockTemplateFlightLogVersion(txid, transaction, template_id)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		if ifMatchFailed(c, version) {
+			return c.Status(fiber.StatusPreconditionFailed).SendString("template flight log has been modified")
+		}
+		/* Templates from before versioning get their current state recorded first */
+		err = db.RecordTemplateRevision(txid, transaction, user_id, template_id, version, nil)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		err = db.UpdateTemplateFlightLogSharing(txid, transaction, template_id, sharing)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		version, err = db.IncrementTemplateFlightLogVersion(txid, transaction, template_id)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		request_user := c.Locals("user_claims").(types.UserClaims)
+		err = db.RecordTemplateRevision(txid, transaction, user_id, template_id, version, &request_user.UserID)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		err = db.CommitTransaction(txid, transaction)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+		}
+		c.Set(fiber.HeaderETag, formatETag(version))
+		response := fiber.Map{
+			"txid":                   txid.String(),
+			"template_flight_log_id": template_id,
+			"sharing":                sharing,
+		}
+		return c.Status(fiber.StatusOK).JSON(response)
+	}
+}
+
+// validateTemplateSharing checks the scope and trims the owner. Unit and wing
+// templates must name who they are shared with; personal and global ones
+// must not.
+func validateTemplateSharing(sharing *db.TemplateSharingDTO) []FieldError {
+	var errs fieldErrors
+	sharing.Owner = strings.TrimSpace(sharing.Owner)
+	switch sharing.Scope {
+	case db.TemplateScopeUnit, db.TemplateScopeWing:
+		errs.required("owner", sharing.Owner == "")
+	case db.TemplateScopePersonal, db.TemplateScopeGlobal:
+		if sharing.Owner != "" {
+			errs.add("owner", validationInvalid, fmt.Sprintf("must be empty for %s templates", sharing.Scope))
+		}
+	default:
+		errs.add("scope", validationInvalid, "must be personal, unit, wing or global")
+	}
+	return errs
+}
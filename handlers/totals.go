@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"flight_log_service/db"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/thedanisaur/jfl_platform/types"
+	"github.com/thedanisaur/jfl_platform/util"
+)
+
+var default_total_periods = []string{"30d", "60d", "90d", "180d", "fy", "lifetime"}
+
+type totalsPeriod struct {
+	Name     string            `json:"name"`
+	DateFrom *time.Time        `json:"date_from"`
+	DateTo   *time.Time        `json:"date_to"`
+	Totals   db.FlyingTotals   `json:"totals"`
+	ByMDS    []db.FlyingTotals `json:"by_mds"`
+}
+
+func GetFlightlogTotals(config types.Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		txid := c.Locals("transaction_id").(uuid.UUID)
+		log.Printf("%s | %s\n", txid.String(), util.GetFunctionName(GetFlightlogTotals))
+
+		user_id, err := uuid.Parse(c.Params("user_id"))
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString("invalid user")
+		}
+
+		/* Scoped by AuthorizationMiddleware */
+		where_clause := c.Locals("authorization_where_clause").(string)
+		arguments := c.Locals("authorization_arguments").([]interface{})
+
+		periods, err := parseTotalsPeriods(c, time.Now().UTC())
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+		}
+		for i := range periods {
+			periods[i].Totals, periods[i].ByMDS, err = db.GetFlyingTotals(txid, user_id, where_clause, arguments, periods[i].DateFrom, periods[i].DateTo)
+			if err != nil {
+				return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+			}
+		}
+
+		response := fiber.Map{
+			"txid":    txid.String(),
+			"user_id": user_id,
+			"periods": periods,
+		}
+		return c.Status(fiber.StatusOK).JSON(response)
+	}
+}
+
+// fiscalYearStart is 1 October of the federal fiscal year containing now.
+func fiscalYearStart(now time.Time) time.Time {
+	year := now.Year()
+	if now.Month() < time.October {
+		year--
+	}
+	return time.Date(year, time.October, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// parseTotalsPeriods reads periods=30d,60d,90d,180d,fy,lifetime (all by
+// default). Any other Nd is accepted too, and date_from/date_to add a custom
+// period. Day periods start at midnight UTC N days back and run to now.
+func parseTotalsPeriods(c *fiber.Ctx, now time.Time) ([]totalsPeriod, error) {
+	names := default_total_periods
+	if c.Query("periods") != "" {
+		names = strings.Split(c.Query("periods"), ",")
+	}
+	if c.Query("periods") == "" && (c.Query("date_from") != "" || c.Query("date_to") != "") {
+		names = []string{}
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	periods := []totalsPeriod{}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		period := totalsPeriod{Name: name}
+		switch {
+		case name == "lifetime":
+		case name == "fy":
+			date_from := fiscalYearStart(now)
+			period.DateFrom = &date_from
+		case strings.HasSuffix(name, "d"):
+			var days int
+			_, err := fmt.Sscanf(name, "%dd", &days)
+			if err != nil || days <= 0 || fmt.Sprintf("%dd", days) != name {
+				return nil, fmt.Errorf("invalid period: %s", name)
+			}
+			date_from := today.AddDate(0, 0, -days)
+			period.DateFrom = &date_from
+		default:
+			return nil, fmt.Errorf("invalid period: %s", name)
+		}
+		periods = append(periods, period)
+	}
+
+	if c.Query("date_from") != "" || c.Query("date_to") != "" {
+		period := totalsPeriod{Name: "custom"}
+		if c.Query("date_from") != "" {
+			date_from, err := parseQueryDate(c.Query("date_from"))
+			if err != nil {
+				return nil, errors.New("invalid date_from")
+			}
+			period.DateFrom = &date_from
+		}
+		if c.Query("date_to") != "" {
+			date_to, err := parseQueryDate(c.Query("date_to"))
+			if err != nil {
+				return nil, errors.New("invalid date_to")
+			}
+			/* A bare date includes the whole day */
+			if len(c.Query("date_to")) == len("2006-01-02") {
+				date_to = date_to.AddDate(0, 0, 1)
+			}
+			period.DateTo = &date_to
+		}
+		periods = append(periods, period)
+	}
+	return periods, nil
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"flight_log_service/db"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/thedanisaur/jfl_platform/types"
+)
+
+// Validation error codes returned in the 422 body.
+const (
+	validationRequired     = "required"
+	validationNegative     = "negative"
+	validationTimeOrder    = "time_order"
+	validationLandingTotal = "landing_total"
+	validationTimeMismatch = "time_mismatch"
+	validationInvalid      = "invalid_value"
+	validationForeignChild = "not_in_flight_log"
+)
+
+// decimal_time_tolerance is how far total_time_decimal may drift from the
+// takeoff/land span, in hours. Times are logged in tenths, so anything within
+// a tenth is a rounding difference rather than a data error.
+const decimal_time_tolerance = 0.1
+
+type FieldError struct {
+	Path    string `json:"path"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type fieldErrors []FieldError
+
+func (errs *fieldErrors) add(path string, code string, message string) {
+	*errs = append(*errs, FieldError{Path: path, Code: code, Message: message})
+}
+
+func (errs *fieldErrors) required(path string, missing bool) {
+	if missing {
+		errs.add(path, validationRequired, path+" is required")
+	}
+}
+
+func (errs *fieldErrors) nonNegative(path string, value float64) {
+	if value < 0 {
+		errs.add(path, validationNegative, path+" must not be negative")
+	}
+}
+
+// validateFlightLog checks a full flight log body as sent to create or update
+// and returns every problem found rather than stopping at the first.
+func validateFlightLog(flight_log types.FlightLogDTO) []FieldError {
+	errs := fieldErrors{}
+	errs.required("mds", strings.TrimSpace(flight_log.MDS) == "")
+	errs.required("flight_log_date", flight_log.FlightLogDate.IsZero())
+	errs.required("serial_number", strings.TrimSpace(flight_log.SerialNumber) == "")
+	errs.required("unit_charged", strings.TrimSpace(flight_log.UnitCharged) == "")
+	errs.nonNegative("total_flight_decimal_time", flight_log.TotalFlightDecimalTime)
+	for i, mission := range flight_log.Missions {
+		validateMission(&errs, fmt.Sprintf("missions[%d]", i), mission)
+	}
+	for i, aircrew := range flight_log.Aircrew {
+		validateAircrew(&errs, fmt.Sprintf("aircrew[%d]", i), aircrew)
+	}
+	return errs
+}
+
+// validateFlightLogPatch checks the fields a merge patch touches. Rules that
+// span several fields need the merged row and are left to the full update.
+func validateFlightLogPatch(patch db.FlightLogPatchDTO) []FieldError {
+	errs := fieldErrors{}
+	errs.required("mds", patch.MDS.Set && (patch.MDS.Value == nil || strings.TrimSpace(*patch.MDS.Value) == ""))
+	errs.required("flight_log_date", patch.FlightLogDate.Set && patch.FlightLogDate.Value == nil)
+	errs.required("serial_number", patch.SerialNumber.Set && (patch.SerialNumber.Value == nil || strings.TrimSpace(*patch.SerialNumber.Value) == ""))
+	errs.required("unit_charged", patch.UnitCharged.Set && (patch.UnitCharged.Value == nil || strings.TrimSpace(*patch.UnitCharged.Value) == ""))
+	if patch.TotalFlightDecimalTime.Value != nil {
+		errs.nonNegative("total_flight_decimal_time", *patch.TotalFlightDecimalTime.Value)
+	}
+	for i, mission := range patch.Missions {
+		path := fmt.Sprintf("missions[%d]", i)
+		for _, field := range []struct {
+			name  string
+			value types.NullableString
+		}{
+			{"mission_from", mission.MissionFrom},
+			{"mission_to", mission.MissionTo},
+		} {
+			errs.required(path+"."+field.name, field.value.Set && (field.value.Value == nil || strings.TrimSpace(*field.value.Value) == ""))
+		}
+		errs.required(path+".takeoff_time", mission.TakeoffTime.Set && mission.TakeoffTime.Value == nil)
+		errs.required(path+".land_time", mission.LandTime.Set && mission.LandTime.Value == nil)
+		if mission.TakeoffTime.Value != nil && mission.LandTime.Value != nil && !mission.LandTime.Value.After(*mission.TakeoffTime.Value) {
+			errs.add(path+".land_time", validationTimeOrder, "land_time must be after takeoff_time")
+		}
+		if mission.TotalTimeDecimal.Value != nil {
+			errs.nonNegative(path+".total_time_decimal", *mission.TotalTimeDecimal.Value)
+		}
+		for _, field := range []struct {
+			name  string
+			value db.NullableInt
+		}{
+			{"touch_and_gos", mission.TouchAndGos},
+			{"full_stops", mission.FullStops},
+			{"total_landings", mission.TotalLandings},
+			{"sorties", mission.Sorties},
+		} {
+			if field.value.Value != nil {
+				errs.nonNegative(path+"."+field.name, float64(*field.value.Value))
+			}
+		}
+	}
+	for i, aircrew := range patch.Aircrew {
+		path := fmt.Sprintf("aircrew[%d]", i)
+		errs.required(path+".user_id", aircrew.UserID.Set && aircrew.UserID.Value == nil)
+		for _, field := range []struct {
+			name  string
+			value db.NullableFloat
+		}{
+			{"time_primary", aircrew.TimePrimary},
+			{"time_secondary", aircrew.TimeSecondary},
+			{"time_instructor", aircrew.TimeInstructor},
+			{"time_evaluator", aircrew.TimeEvaluator},
+			{"time_other", aircrew.TimeOther},
+			{"total_aircrew_duration_decimal", aircrew.TotalAircrewDurationDecimal},
+			{"cond_night_time", aircrew.CondNightTime},
+			{"cond_instrument_time", aircrew.CondInstrumentTime},
+			{"cond_sim_instrument_time", aircrew.CondSimInstrumentTime},
+			{"cond_nvg_time", aircrew.CondNvgTime},
+			{"cond_combat_time", aircrew.CondCombatTime},
+			{"cond_combat_support_time", aircrew.CondCombatSupportTime},
+		} {
+			if field.value.Value != nil {
+				errs.nonNegative(path+"."+field.name, *field.value.Value)
+			}
+		}
+		for _, field := range []struct {
+			name  string
+			value db.NullableInt
+		}{
+			{"total_aircrew_sorties", aircrew.TotalAircrewSorties},
+			{"cond_combat_sortie", aircrew.CondCombatSortie},
+			{"cond_combat_support_sortie", aircrew.CondCombatSupportSortie},
+		} {
+			if field.value.Value != nil {
+				errs.nonNegative(path+"."+field.name, float64(*field.value.Value))
+			}
+		}
+	}
+	return errs
+}
+
+func validateAircrew(errs *fieldErrors, path string, aircrew types.FlightLogAircrewDTO) {
+	errs.required(path+".user_id", aircrew.UserID == uuid.Nil)
+	errs.nonNegative(path+".time_primary", aircrew.TimePrimary)
+	errs.nonNegative(path+".time_secondary", aircrew.TimeSecondary)
+	errs.nonNegative(path+".time_instructor", aircrew.TimeInstructor)
+	errs.nonNegative(path+".time_evaluator", aircrew.TimeEvaluator)
+	errs.nonNegative(path+".time_other", aircrew.TimeOther)
+	errs.nonNegative(path+".total_aircrew_duration_decimal", aircrew.TotalAircrewDurationDecimal)
+	errs.nonNegative(path+".total_aircrew_sorties", float64(aircrew.TotalAircrewSorties))
+	errs.nonNegative(path+".cond_night_time", aircrew.CondNightTime)
+	errs.nonNegative(path+".cond_instrument_time", aircrew.CondInstrumentTime)
+	errs.nonNegative(path+".cond_sim_instrument_time", aircrew.CondSimInstrumentTime)
+	errs.nonNegative(path+".cond_nvg_time", aircrew.CondNvgTime)
+	errs.nonNegative(path+".cond_combat_time", aircrew.CondCombatTime)
+	errs.nonNegative(path+".cond_combat_sortie", float64(aircrew.CondCombatSortie))
+	errs.nonNegative(path+".cond_combat_support_time", aircrew.CondCombatSupportTime)
+	errs.nonNegative(path+".cond_combat_support_sortie", float64(aircrew.CondCombatSupportSortie))
+}
+
+func validateMission(errs *fieldErrors, path string, mission types.FlightLogMissionDTO) {
+	errs.required(path+".mission_from", strings.TrimSpace(mission.MissionFrom) == "")
+	errs.required(path+".mission_to", strings.TrimSpace(mission.MissionTo) == "")
+	errs.required(path+".takeoff_time", mission.TakeoffTime.IsZero())
+	errs.required(path+".land_time", mission.LandTime.IsZero())
+	errs.nonNegative(path+".total_time_decimal", mission.TotalTimeDecimal)
+	errs.nonNegative(path+".touch_and_gos", float64(mission.TouchAndGos))
+	errs.nonNegative(path+".full_stops", float64(mission.FullStops))
+	errs.nonNegative(path+".total_landings", float64(mission.TotalLandings))
+	errs.nonNegative(path+".sorties", float64(mission.Sorties))
+	if mission.TotalLandings != mission.TouchAndGos+mission.FullStops {
+		errs.add(path+".total_landings", validationLandingTotal, "total_landings must equal touch_and_gos + full_stops")
+	}
+	if mission.TakeoffTime.IsZero() || mission.LandTime.IsZero() {
+		return
+	}
+	if !mission.LandTime.After(mission.TakeoffTime) {
+		errs.add(path+".land_time", validationTimeOrder, "land_time must be after takeoff_time")
+		return
+	}
+	hours := mission.LandTime.Sub(mission.TakeoffTime).Hours()
+	if math.Abs(mission.TotalTimeDecimal-hours) > decimal_time_tolerance {
+		errs.add(path+".total_time_decimal", validationTimeMismatch, fmt.Sprintf("total_time_decimal %.1f does not match takeoff_time to land_time (%.1f)", mission.TotalTimeDecimal, hours))
+	}
+}
+
+func validationFailed(c *fiber.Ctx, txid uuid.UUID, errs []FieldError) error {
+	response := fiber.Map{
+		"txid":   txid.String(),
+		"errors": errs,
+	}
+	return c.Status(fiber.StatusUnprocessableEntity).JSON(response)
+}
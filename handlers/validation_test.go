@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"flight_log_service/db"
+
+	"github.com/google/uuid"
+	"github.com/thedanisaur/jfl_platform/types"
+)
+
+func validFlightLog() types.FlightLogDTO {
+	takeoff := time.Date(2024, 5, 1, 14, 0, 0, 0, time.UTC)
+	return types.FlightLogDTO{
+		MDS:           "T-38C",
+		FlightLogDate: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+		SerialNumber:  "68-8205",
+		UnitCharged:   "0016 TRS",
+		Missions: []types.FlightLogMissionDTO{{
+			MissionFrom:      "KRND",
+			MissionTo:        "KRND",
+			TakeoffTime:      takeoff,
+			LandTime:         takeoff.Add(90 * time.Minute),
+			TotalTimeDecimal: 1.5,
+			TouchAndGos:      2,
+			FullStops:        1,
+			TotalLandings:    3,
+			Sorties:          1,
+		}},
+		Aircrew: []types.FlightLogAircrewDTO{{UserID: uuid.New(), TimePrimary: 1.5}},
+	}
+}
+
+func TestValidateFlightLog(t *testing.T) {
+	cases := []struct {
+		name   string
+		modify func(flight_log *types.FlightLogDTO)
+		path   string
+		code   string
+	}{
+		{"valid", func(f *types.FlightLogDTO) {}, "", ""},
+		{"blank mds", func(f *types.FlightLogDTO) { f.MDS = " " }, "mds", validationRequired},
+		{"no date", func(f *types.FlightLogDTO) { f.FlightLogDate = time.Time{} }, "flight_log_date", validationRequired},
+		{"negative total", func(f *types.FlightLogDTO) { f.TotalFlightDecimalTime = -1 }, "total_flight_decimal_time", validationNegative},
+		{"blank mission_to", func(f *types.FlightLogDTO) { f.Missions[0].MissionTo = "" }, "missions[0].mission_to", validationRequired},
+		{"landing total", func(f *types.FlightLogDTO) { f.Missions[0].TotalLandings = 4 }, "missions[0].total_landings", validationLandingTotal},
+		{"land before takeoff", func(f *types.FlightLogDTO) { f.Missions[0].LandTime = f.Missions[0].TakeoffTime }, "missions[0].land_time", validationTimeOrder},
+		{"decimal mismatch", func(f *types.FlightLogDTO) { f.Missions[0].TotalTimeDecimal = 1.7 }, "missions[0].total_time_decimal", validationTimeMismatch},
+		{"no crew member", func(f *types.FlightLogDTO) { f.Aircrew[0].UserID = uuid.Nil }, "aircrew[0].user_id", validationRequired},
+		{"negative aircrew time", func(f *types.FlightLogDTO) { f.Aircrew[0].CondNvgTime = -0.1 }, "aircrew[0].cond_nvg_time", validationNegative},
+	}
+	for _, test := range cases {
+		flight_log := validFlightLog()
+		test.modify(&flight_log)
+		errs := validateFlightLog(flight_log)
+		if test.path == "" {
+			if len(errs) > 0 {
+				t.Errorf("%s: unexpected errors %+v", test.name, errs)
+			}
+			continue
+		}
+		if len(errs) != 1 || errs[0].Path != test.path || errs[0].Code != test.code {
+			t.Errorf("%s: errors = %+v, want %s on %s", test.name, errs, test.code, test.path)
+		}
+	}
+}
+
+func TestValidateFlightLogPatch(t *testing.T) {
+	blank := ""
+	mds := "T-6A"
+	negative := -1.0
+	takeoff := time.Date(2024, 5, 1, 14, 0, 0, 0, time.UTC)
+	earlier := takeoff.Add(-time.Minute)
+	cases := []struct {
+		name  string
+		patch db.FlightLogPatchDTO
+		path  string
+		code  string
+	}{
+		{"untouched", db.FlightLogPatchDTO{}, "", ""},
+		{"new mds", db.FlightLogPatchDTO{MDS: types.NullableString{Set: true, Value: &mds}}, "", ""},
+		{"null mds", db.FlightLogPatchDTO{MDS: types.NullableString{Set: true}}, "mds", validationRequired},
+		{"blank serial", db.FlightLogPatchDTO{SerialNumber: types.NullableString{Set: true, Value: &blank}}, "serial_number", validationRequired},
+		{"negative total", db.FlightLogPatchDTO{TotalFlightDecimalTime: db.NullableFloat{Set: true, Value: &negative}}, "total_flight_decimal_time", validationNegative},
+		{"land before takeoff", db.FlightLogPatchDTO{Missions: []db.MissionPatchDTO{{
+			TakeoffTime: types.NullableTime{Set: true, Value: &takeoff},
+			LandTime:    types.NullableTime{Set: true, Value: &earlier},
+		}}}, "missions[0].land_time", validationTimeOrder},
+		{"null crew member", db.FlightLogPatchDTO{Aircrew: []db.AircrewPatchDTO{{UserID: types.NullableString{Set: true}}}}, "aircrew[0].user_id", validationRequired},
+		{"negative aircrew time", db.FlightLogPatchDTO{Aircrew: []db.AircrewPatchDTO{{TimeOther: db.NullableFloat{Set: true, Value: &negative}}}}, "aircrew[0].time_other", validationNegative},
+	}
+	for _, test := range cases {
+		errs := validateFlightLogPatch(test.patch)
+		if test.path == "" {
+			if len(errs) > 0 {
+				t.Errorf("%s: unexpected errors %+v", test.name, errs)
+			}
+			continue
+		}
+		if len(errs) != 1 || errs[0].Path != test.path || errs[0].Code != test.code {
+			t.Errorf("%s: errors = %+v, want %s on %s", test.name, errs, test.code, test.path)
+		}
+	}
+}
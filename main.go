@@ -25,6 +25,11 @@ func main() {
 		log.Printf("Error opening config, cannot continue: %s\n", err.Error())
 		return
 	}
+	err = handlers.LoadSettings("./config.json")
+	if err != nil {
+		log.Printf("Error loading service settings, cannot continue: %s\n", err.Error())
+		return
+	}
 	app := fiber.New()
 	database, err := db.GetInstance()
 	if err != nil {
@@ -54,6 +59,7 @@ func main() {
 		AllowOrigins:     strings.Join(config.App.Cors.AllowOrigins, ","),
 		AllowHeaders:     strings.Join(config.App.Cors.AllowHeaders, ","),
 		AllowCredentials: config.App.Cors.AllowCredentials,
+		ExposeHeaders:    fiber.HeaderETag,
 	}))
 
 	// ==========================================
@@ -81,21 +87,48 @@ func main() {
 	// ==========================================
 	// JWT Authentication
 	// ==========================================
-	app.Get("/flight-logs", auth.AuthenticationMiddleware(config, public_key), handlers.GetFlightlogsAll(config))
-	app.Get("/flight-logs/:user_id", auth.AuthenticationMiddleware(config, public_key), handlers.GetFlightlogs(config))
-	app.Get("/flight-logs/:user_id/:flight_log_id", auth.AuthenticationMiddleware(config, public_key), handlers.GetFlightlog(config))
-	app.Get("/templates/:user_id", auth.AuthenticationMiddleware(config, public_key), handlers.GetTemplateFlightlogs(config))
-	app.Get("/templates/:user_id/:template_id", auth.AuthenticationMiddleware(config, public_key), handlers.GetTemplateFlightlog(config))
-
-	app.Post("/flight-logs/:user_id", auth.AuthenticationMiddleware(config, public_key), handlers.CreateFlightlog(config))
-	app.Post("/flight-logs/:user_id/:flight_log_id/comments", auth.AuthenticationMiddleware(config, public_key), handlers.CreateFlightlogComment(config))
-	app.Post("/templates/:user_id", auth.AuthenticationMiddleware(config, public_key), handlers.CreateTemplateFlightlog(config))
-
-	app.Put("/flight-logs/:user_id/:flight_log_id", auth.AuthenticationMiddleware(config, public_key), handlers.UpdateFlightlog(config))
-	app.Put("/templates/:user_id/:template_id", auth.AuthenticationMiddleware(config, public_key), handlers.UpdateTemplateFlightlog(config))
-
-	app.Delete("/flight-logs/:user_id/:flight_log_id", auth.AuthenticationMiddleware(config, public_key), handlers.DeleteFlightlog(config))
-	app.Delete("/templates/:user_id/:template_id", auth.AuthenticationMiddleware(config, public_key), handlers.DeleteTemplateFlightlog(config))
+	app.Get("/aircraft", auth.AuthenticationMiddleware(config, public_key), handlers.AuthorizationMiddleware(config, "flight-logs", "read"), handlers.GetAircraft(config))
+	app.Get("/aircraft/:serial_number/history", auth.AuthenticationMiddleware(config, public_key), handlers.AuthorizationMiddleware(config, "flight-logs", "read"), handlers.GetAircraftHistory(config))
+	app.Get("/airfields", auth.AuthenticationMiddleware(config, public_key), handlers.AuthorizationMiddleware(config, "airfields", "read"), handlers.GetAirfields(config))
+	app.Get("/airfields/:identifier", auth.AuthenticationMiddleware(config, public_key), handlers.AuthorizationMiddleware(config, "airfields", "read"), handlers.GetAirfield(config))
+	app.Get("/currency/overdue", auth.AuthenticationMiddleware(config, public_key), handlers.AuthorizationMiddleware(config, "currency", "read"), handlers.GetCurrencyOverdue(config))
+	app.Get("/flight-logs", auth.AuthenticationMiddleware(config, public_key), handlers.AuthorizationMiddleware(config, "flight-logs", "read"), handlers.GetFlightlogsAll(config))
+	app.Get("/flight-logs/export.csv", auth.AuthenticationMiddleware(config, public_key), handlers.AuthorizationMiddleware(config, "flight-logs", "read"), handlers.ExportFlightlogsAll(config))
+	app.Get("/flight-logs/:user_id", auth.AuthenticationMiddleware(config, public_key), handlers.AuthorizationMiddleware(config, "flight-logs", "read"), handlers.GetFlightlogs(config))
+	app.Get("/flight-logs/:user_id/currency", auth.AuthenticationMiddleware(config, public_key), handlers.AuthorizationMiddleware(config, "flight-logs", "read"), handlers.GetFlightlogCurrency(config))
+	app.Get("/flight-logs/:user_id/export.csv", auth.AuthenticationMiddleware(config, public_key), handlers.AuthorizationMiddleware(config, "flight-logs", "read"), handlers.ExportFlightlogs(config))
+	app.Get("/flight-logs/:user_id/totals", auth.AuthenticationMiddleware(config, public_key), handlers.AuthorizationMiddleware(config, "flight-logs", "read"), handlers.GetFlightlogTotals(config))
+	app.Get("/flight-logs/:user_id/:flight_log_id", auth.AuthenticationMiddleware(config, public_key), handlers.AuthorizationMiddleware(config, "flight-logs", "read"), handlers.GetFlightlog(config))
+	app.Get("/flight-logs/:user_id/:flight_log_id/form.pdf", auth.AuthenticationMiddleware(config, public_key), handlers.AuthorizationMiddleware(config, "flight-logs", "read"), handlers.GetFlightlogForm(config))
+	app.Get("/flight-logs/:user_id/:flight_log_id/reconciliation", auth.AuthenticationMiddleware(config, public_key), handlers.AuthorizationMiddleware(config, "flight-logs", "read"), handlers.GetFlightlogReconciliation(config))
+	app.Get("/flight-logs/:user_id/:flight_log_id/signatures", auth.AuthenticationMiddleware(config, public_key), handlers.AuthorizationMiddleware(config, "signatures", "read"), handlers.GetFlightlogSignatures(config))
+	app.Get("/flight-logs/:user_id/:flight_log_id/transitions", auth.AuthenticationMiddleware(config, public_key), handlers.AuthorizationMiddleware(config, "transitions", "read"), handlers.GetFlightlogTransitions(config))
+	app.Get("/flying-hour-program", auth.AuthenticationMiddleware(config, public_key), handlers.AuthorizationMiddleware(config, "flying-hour-program", "read"), handlers.GetFlyingHourProgram(config))
+	app.Get("/templates", auth.AuthenticationMiddleware(config, public_key), handlers.AuthorizationMiddleware(config, "templates", "read"), handlers.GetTemplateFlightlogsAll(config))
+	app.Get("/templates/:user_id", auth.AuthenticationMiddleware(config, public_key), handlers.AuthorizationMiddleware(config, "templates", "read"), handlers.GetTemplateFlightlogs(config))
+	app.Get("/templates/:user_id/:template_id", auth.AuthenticationMiddleware(config, public_key), handlers.AuthorizationMiddleware(config, "templates", "read"), handlers.GetTemplateFlightlog(config))
+	app.Get("/templates/:user_id/:template_id/versions", auth.AuthenticationMiddleware(config, public_key), handlers.AuthorizationMiddleware(config, "templates", "read"), handlers.GetTemplateFlightlogRevisions(config))
+	app.Get("/templates/:user_id/:template_id/versions/:version", auth.AuthenticationMiddleware(config, public_key), handlers.AuthorizationMiddleware(config, "templates", "read"), handlers.GetTemplateFlightlogRevision(config))
+
+	app.Post("/flight-logs/:user_id", auth.AuthenticationMiddleware(config, public_key), handlers.AuthorizationMiddleware(config, "flight-logs", "create"), handlers.CreateFlightlog(config))
+	app.Post("/flight-logs/:user_id/import", auth.AuthenticationMiddleware(config, public_key), handlers.AuthorizationMiddleware(config, "flight-logs", "create"), handlers.ImportFlightlogs(config))
+	app.Post("/flight-logs/:user_id/:flight_log_id/as-template", auth.AuthenticationMiddleware(config, public_key), handlers.AuthorizationMiddleware(config, "flight-logs", "read"), handlers.CreateTemplateFromFlightlog(config))
+	app.Post("/flight-logs/:user_id/:flight_log_id/comments", auth.AuthenticationMiddleware(config, public_key), handlers.AuthorizationMiddleware(config, "comments", "create"), handlers.CreateFlightlogComment(config))
+	app.Post("/flight-logs/:user_id/:flight_log_id/signatures/:role", auth.AuthenticationMiddleware(config, public_key), handlers.AuthorizationMiddleware(config, "signatures", "create"), handlers.CreateFlightlogSignature(config))
+	app.Post("/flight-logs/:user_id/:flight_log_id/transitions/:status", auth.AuthenticationMiddleware(config, public_key), handlers.AuthorizationMiddleware(config, "transitions", "create"), handlers.CreateFlightlogTransition(config))
+	app.Post("/templates/:user_id", auth.AuthenticationMiddleware(config, public_key), handlers.AuthorizationMiddleware(config, "templates", "create"), handlers.CreateTemplateFlightlog(config))
+	app.Post("/templates/:user_id/:template_id/instantiate", auth.AuthenticationMiddleware(config, public_key), handlers.AuthorizationMiddleware(config, "templates", "instantiate"), handlers.InstantiateTemplateFlightlog(config))
+	app.Post("/templates/:user_id/:template_id/versions/:version/restore", auth.AuthenticationMiddleware(config, public_key), handlers.AuthorizationMiddleware(config, "templates", "update"), handlers.RestoreTemplateFlightlogRevision(config))
+
+	app.Put("/flight-logs/:user_id/:flight_log_id", auth.AuthenticationMiddleware(config, public_key), handlers.AuthorizationMiddleware(config, "flight-logs", "update"), handlers.UpdateFlightlog(config))
+	app.Put("/templates/:user_id/:template_id", auth.AuthenticationMiddleware(config, public_key), handlers.AuthorizationMiddleware(config, "templates", "update"), handlers.UpdateTemplateFlightlog(config))
+	app.Put("/templates/:user_id/:template_id/sharing", auth.AuthenticationMiddleware(config, public_key), handlers.AuthorizationMiddleware(config, "templates", "share"), handlers.UpdateTemplateFlightlogSharing(config))
+
+	app.Patch("/flight-logs/:user_id/:flight_log_id", auth.AuthenticationMiddleware(config, public_key), handlers.AuthorizationMiddleware(config, "flight-logs", "update"), handlers.PatchFlightlog(config))
+	app.Patch("/templates/:user_id/:template_id", auth.AuthenticationMiddleware(config, public_key), handlers.AuthorizationMiddleware(config, "templates", "update"), handlers.PatchTemplateFlightlog(config))
+
+	app.Delete("/flight-logs/:user_id/:flight_log_id", auth.AuthenticationMiddleware(config, public_key), handlers.AuthorizationMiddleware(config, "flight-logs", "delete"), handlers.DeleteFlightlog(config))
+	app.Delete("/templates/:user_id/:template_id", auth.AuthenticationMiddleware(config, public_key), handlers.AuthorizationMiddleware(config, "templates", "delete"), handlers.DeleteTemplateFlightlog(config))
 
 	// ==========================================
 	// Start Service
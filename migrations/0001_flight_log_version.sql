@@ -0,0 +1,4 @@
+-- Row version backing the ETag / If-Match checks on flight logs and templates.
+-- Every committed write to a log or any of its children bumps the version.
+ALTER TABLE flight_logs ADD COLUMN version INT NOT NULL DEFAULT 1;
+ALTER TABLE template_flight_logs ADD COLUMN version INT NOT NULL DEFAULT 1;
@@ -0,0 +1,15 @@
+-- Signature records behind the *_signature_id columns on flight_logs.
+-- A row is never updated except to stamp invalidated_on when the log is edited
+-- or the role re-signs, so the table doubles as the signing history.
+CREATE TABLE flight_log_signatures (
+    id BINARY(16) NOT NULL PRIMARY KEY,
+    flight_log_id BINARY(16) NOT NULL,
+    role VARCHAR(32) NOT NULL,
+    user_id BINARY(16) NOT NULL,
+    role_name VARCHAR(64) NOT NULL,
+    content_hash CHAR(64) NOT NULL,
+    flight_log_version INT NOT NULL,
+    signed_on DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    invalidated_on DATETIME NULL,
+    INDEX flight_log_signatures_flight_log_id (flight_log_id)
+);
@@ -0,0 +1,15 @@
+-- Lifecycle state on flight logs (draft -> submitted -> signed -> closed) and
+-- the append-only history of every transition.
+ALTER TABLE flight_logs ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'draft';
+
+CREATE TABLE flight_log_transitions (
+    id BINARY(16) NOT NULL PRIMARY KEY,
+    flight_log_id BINARY(16) NOT NULL,
+    from_status VARCHAR(16) NOT NULL,
+    to_status VARCHAR(16) NOT NULL,
+    user_id BINARY(16) NOT NULL,
+    role_name VARCHAR(64) NOT NULL,
+    reason VARCHAR(1024) NOT NULL DEFAULT '',
+    created_on DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX flight_log_transitions_flight_log_id (flight_log_id)
+);
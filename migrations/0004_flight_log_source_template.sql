@@ -0,0 +1,4 @@
+-- The template, and the template version, a flight log was instantiated from.
+-- Both stay NULL for logs entered directly or imported.
+ALTER TABLE flight_logs ADD COLUMN source_template_id BINARY(16) NULL;
+ALTER TABLE flight_logs ADD COLUMN source_template_version INT NULL;
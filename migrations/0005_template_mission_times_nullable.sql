@@ -0,0 +1,4 @@
+-- Templates describe a profile rather than a flight that happened, so mission
+-- takeoff and land times are optional. Logs saved as templates store NULL.
+ALTER TABLE template_missions MODIFY takeoff_time DATETIME NULL;
+ALTER TABLE template_missions MODIFY land_time DATETIME NULL;
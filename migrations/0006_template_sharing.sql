@@ -0,0 +1,5 @@
+-- Who a template is shared with: personal (owner only), unit, wing or global.
+-- scope_owner names the unit or wing and is empty for the other scopes.
+ALTER TABLE template_flight_logs ADD COLUMN scope VARCHAR(16) NOT NULL DEFAULT 'personal';
+ALTER TABLE template_flight_logs ADD COLUMN scope_owner VARCHAR(64) NOT NULL DEFAULT '';
+CREATE INDEX template_flight_logs_scope ON template_flight_logs (scope, scope_owner);
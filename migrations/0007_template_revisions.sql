@@ -0,0 +1,11 @@
+-- Immutable history of every template version. Each committed template write
+-- stores the full template, missions and aircrew as JSON under the version
+-- it produced. user_id is NULL for states captured from before versioning.
+CREATE TABLE template_flight_log_revisions (
+    template_id BINARY(16) NOT NULL,
+    version INT NOT NULL,
+    user_id BINARY(16) NULL,
+    content JSON NOT NULL,
+    created_on DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (template_id, version)
+);
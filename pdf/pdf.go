@@ -0,0 +1,219 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"strings"
+)
+
+// Document is a minimal PDF 1.4 writer: lines, boxes and single-line text in
+// the standard Helvetica faces, which every reader carries, so no font data is
+// embedded. Coordinates are points from the top-left corner of the page.
+type Document struct {
+	title  string
+	width  float64
+	height float64
+	pages  []*bytes.Buffer
+}
+
+// Page sizes in points.
+const (
+	LetterWidth  = 612.0
+	LetterHeight = 792.0
+)
+
+// AddPage starts a new page; drawing always goes to the last page added.
+func (d *Document) AddPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+}
+
+// Bytes lays out the finished document. Objects 1 to 4 are the catalog, page
+// tree and the two fonts; each page then takes two objects, the page and its
+// compressed content stream.
+func (d *Document) Bytes() ([]byte, error) {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>",
+	}
+	kids := []string{}
+	for _, page := range d.pages {
+		var content bytes.Buffer
+		writer := zlib.NewWriter(&content)
+		_, err := writer.Write(page.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		err = writer.Close()
+		if err != nil {
+			return nil, err
+		}
+		page_object := len(objects) + 1
+		kids = append(kids, fmt.Sprintf("%d 0 R", page_object))
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+				number(d.width), number(d.height), page_object+1),
+			fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", content.Len(), content.String()),
+		)
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids))
+	objects = append(objects, fmt.Sprintf("<< /Title %s /Producer (flight_log_service) >>", literal(d.title)))
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, len(objects), xref)
+	return out.Bytes(), nil
+}
+
+// FillRect shades a box in the given gray level (0 black, 1 white).
+func (d *Document) FillRect(x float64, y float64, width float64, height float64, gray float64) {
+	fmt.Fprintf(d.page(), "q %s g %s %s %s %s re f Q\n", number(gray), number(x), number(d.height-y-height), number(width), number(height))
+}
+
+// Fit returns text cut down, with a trailing ellipsis, to fit width.
+func Fit(text string, size float64, bold bool, width float64) string {
+	if TextWidth(text, size, bold) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && TextWidth(string(runes)+"...", size, bold) > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "..."
+}
+
+// Line draws a line of the given width.
+func (d *Document) Line(x1 float64, y1 float64, x2 float64, y2 float64, width float64) {
+	fmt.Fprintf(d.page(), "%s w %s %s m %s %s l S\n", number(width), number(x1), number(d.height-y1), number(x2), number(d.height-y2))
+}
+
+// New starts an empty document with the given page size.
+func New(title string, width float64, height float64) *Document {
+	return &Document{title: title, width: width, height: height}
+}
+
+// PageCount is the number of pages added so far.
+func (d *Document) PageCount() int {
+	return len(d.pages)
+}
+
+// Rect outlines a box.
+func (d *Document) Rect(x float64, y float64, width float64, height float64, line_width float64) {
+	fmt.Fprintf(d.page(), "%s w %s %s %s %s re S\n", number(line_width), number(x), number(d.height-y-height), number(width), number(height))
+}
+
+// Text draws one line of text with its baseline at y.
+func (d *Document) Text(x float64, y float64, size float64, bold bool, text string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(d.page(), "BT /%s %s Tf %s %s Td %s Tj ET\n", font, number(size), number(x), number(d.height-y), literal(text))
+}
+
+// TextWidth measures text in points using the standard Helvetica metrics.
+func TextWidth(text string, size float64, bold bool) float64 {
+	widths := helvetica_widths
+	if bold {
+		widths = helvetica_bold_widths
+	}
+	units := 0
+	for _, r := range text {
+		if r >= 32 && r <= 126 {
+			units += widths[r-32]
+		} else {
+			units += 556
+		}
+	}
+	return float64(units) * size / 1000
+}
+
+// Wrap breaks text into lines no wider than width, splitting on spaces and
+// keeping the writer's own line breaks. Words too long for a line are cut.
+func Wrap(text string, size float64, bold bool, width float64) []string {
+	lines := []string{}
+	for _, paragraph := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if TextWidth(candidate, size, bold) <= width {
+				line = candidate
+				continue
+			}
+			if line != "" {
+				lines = append(lines, line)
+			}
+			line = Fit(word, size, bold, width)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// literal encodes text as a PDF string in WinAnsiEncoding, which matches
+// Latin-1 for everything printable. Anything outside it becomes '?'.
+func literal(text string) string {
+	var out strings.Builder
+	out.WriteByte('(')
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			out.WriteByte('\\')
+			out.WriteRune(r)
+		case r >= 32 && r <= 126:
+			out.WriteRune(r)
+		case r >= 160 && r <= 255:
+			fmt.Fprintf(&out, "\\%03o", r)
+		default:
+			out.WriteByte('?')
+		}
+	}
+	out.WriteByte(')')
+	return out.String()
+}
+
+func number(value float64) string {
+	return strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.2f", value), "0"), ".")
+}
+
+func (d *Document) page() *bytes.Buffer {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+	return d.pages[len(d.pages)-1]
+}
+
+// Glyph widths in thousandths of an em for characters 32 to 126, from the
+// Adobe core font metrics.
+var helvetica_widths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+var helvetica_bold_widths = [95]int{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+	975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+	333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+	611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+}